@@ -0,0 +1,389 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+//  --------------------------------------------------
+//  Backup.go keeps saves crash-safe.
+//
+//  Every file of a save is written to a temporary file,
+//  synced and renamed over the old one, so a crash never
+//  leaves a half-written file behind. Whole directories
+//  of regions are written next to the old one and swapped
+//  in once complete, the old one kept until the world
+//  file is written so a crash in between is undone when
+//  the world is next loaded. Before each save from the
+//  menu, and autosaves every AutosaveBackupInterval, the
+//  previous save is snapshotted into a timestamped
+//  backup, using hard links where possible so backups
+//  are nearly free. Only the newest MaxWorldBackups
+//  backups are kept.
+//  --------------------------------------------------
+
+// MaxWorldBackups is how many old saves are kept per world
+const MaxWorldBackups = 5
+
+const backupTimeFormat = "20060102-150405.000"
+
+//  --------------------------------------------------
+//  Atomic Writes
+//  --------------------------------------------------
+
+// writeFileAtomic writes a file through a synced temporary
+// file which is renamed over path once it is complete
+func writeFileAtomic(path string, write func(w io.Writer) error) error {
+	dir := filepath.Dir(path)
+
+	f, err := ioutil.TempFile(dir, filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+
+	err = write(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	// Make the rename itself durable
+	syncDir(dir)
+	return nil
+}
+
+// replaceDir swaps a complete directory in place of dest, keeping
+// the old one at oldDirFor(dest) until the caller removes it
+func replaceDir(src, dest string) error {
+	syncDir(src)
+
+	old := oldDirFor(dest)
+	if err := os.RemoveAll(old); err != nil {
+		return err
+	}
+	if err := os.Rename(dest, old); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Rename(src, dest); err != nil {
+		os.Rename(old, dest)
+		return err
+	}
+
+	syncDir(filepath.Dir(dest))
+	return nil
+}
+
+// recoverDir undoes a replaceDir that a crash interrupted before
+// dest was in place, and removes what it left behind otherwise
+func recoverDir(dest string) error {
+	old := oldDirFor(dest)
+	if _, err := os.Stat(dest); os.IsNotExist(err) {
+		if _, err := os.Stat(old); err == nil {
+			logInfo("Recovering " + dest + " from an interrupted save")
+			if err := os.Rename(old, dest); err != nil {
+				return err
+			}
+		}
+	}
+	os.RemoveAll(tempDirFor(dest))
+	return nil
+}
+
+// Directories replaceDir writes into and keeps the old directory in
+func tempDirFor(dir string) string {
+	return dir + ".tmp"
+}
+
+func oldDirFor(dir string) string {
+	return dir + ".old"
+}
+
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+//  --------------------------------------------------
+//  Backups
+//  --------------------------------------------------
+
+// backupDirFor returns the directory holding the backups of a world file
+func backupDirFor(path string) string {
+	return filepath.Join(filepath.Dir(path), "backups", filepath.Base(regionDirFor(path)))
+}
+
+// backupWorld snapshots the save at path, if there is one,
+// and removes the oldest backups past MaxWorldBackups
+func backupWorld(path string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+
+	dest := filepath.Join(backupDirFor(path), time.Now().Format(backupTimeFormat))
+	if err := os.MkdirAll(dest, 0700); err != nil {
+		return err
+	}
+
+	if err := linkOrCopy(path, filepath.Join(dest, filepath.Base(path))); err != nil {
+		return err
+	}
+
+	regions := regionDirFor(path)
+	destRegions := regionDirFor(filepath.Join(dest, filepath.Base(path)))
+	if files, err := ioutil.ReadDir(regions); err == nil {
+		if err := os.MkdirAll(destRegions, 0700); err != nil {
+			return err
+		}
+		for _, file := range files {
+			if file.IsDir() {
+				continue
+			}
+			if err := linkOrCopy(filepath.Join(regions, file.Name()), filepath.Join(destRegions, file.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	return pruneBackups(path)
+}
+
+// backupAge returns how long ago the newest backup of a world
+// was taken, or a very long time if it has none
+func backupAge(path string) time.Duration {
+	backups, err := listBackups(path)
+	if err != nil || len(backups) == 0 {
+		return math.MaxInt64
+	}
+	taken, err := time.ParseInLocation(backupTimeFormat, filepath.Base(filepath.Dir(backups[0])), time.Local)
+	if err != nil {
+		return math.MaxInt64
+	}
+	return time.Since(taken)
+}
+
+func pruneBackups(path string) error {
+	backups, err := listBackups(path)
+	if err != nil {
+		return err
+	}
+	for len(backups) > MaxWorldBackups {
+		if err := os.RemoveAll(filepath.Dir(backups[len(backups)-1])); err != nil {
+			return err
+		}
+		backups = backups[:len(backups)-1]
+	}
+	return nil
+}
+
+// listBackups returns the world files of every backup, newest first
+func listBackups(path string) ([]string, error) {
+	dirs, err := ioutil.ReadDir(backupDirFor(path))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []string
+	for _, dir := range dirs {
+		if _, err := time.Parse(backupTimeFormat, dir.Name()); err != nil || !dir.IsDir() {
+			continue
+		}
+		backups = append(backups, filepath.Join(backupDirFor(path), dir.Name(), filepath.Base(path)))
+	}
+
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	return backups, nil
+}
+
+// newestValidBackup returns the newest backup of a world
+// that passes checkWorldFile, or "" if there is none
+func newestValidBackup(path string) string {
+	backups, err := listBackups(path)
+	if err != nil {
+		logInfo("Failed to list backups: " + err.Error())
+		return ""
+	}
+	for _, backup := range backups {
+		if err := checkWorldFile(backup); err != nil {
+			logInfo(fmt.Sprintf("Skipping backup %s: %v", backup, err))
+			continue
+		}
+		return backup
+	}
+	return ""
+}
+
+// restoreBackup puts the save in a backup back in place of the world at path
+func restoreBackup(backup, path string) error {
+	regions := regionDirFor(path)
+	tmp := tempDirFor(regions)
+	if err := os.RemoveAll(tmp); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(tmp, 0700); err != nil {
+		return err
+	}
+
+	backupRegions := regionDirFor(backup)
+	if files, err := ioutil.ReadDir(backupRegions); err == nil {
+		for _, file := range files {
+			if err := copyFileAtomic(filepath.Join(backupRegions, file.Name()), filepath.Join(tmp, file.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	if err := replaceDir(tmp, regions); err != nil {
+		return err
+	}
+
+	// The world file goes last, so a crash while restoring
+	// leaves the slot as broken as it was before
+	if err := copyFileAtomic(backup, path); err != nil {
+		return err
+	}
+	return os.RemoveAll(oldDirFor(regions))
+}
+
+//  --------------------------------------------------
+//  Validation
+//  --------------------------------------------------
+
+// checkWorldFile makes sure a save is complete, without loading it
+func checkWorldFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	if magic, err := r.Peek(len(WorldFileMagic)); err != nil || string(magic) != string(WorldFileMagic[:]) {
+		return checkLegacyWorldFile(r)
+	}
+
+	var header WorldHeader
+	if err := binary.Read(r, byteOrder, &header); err != nil {
+		return err
+	}
+	if header.Version < 1 || header.Version > WorldFormatVersion {
+		return fmt.Errorf("unsupported world format version %d", header.Version)
+	}
+
+	for {
+		var tag [4]byte
+		if _, err := io.ReadFull(r, tag[:]); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		var length uint32
+		if err := binary.Read(r, byteOrder, &length); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(ioutil.Discard, r, int64(length)); err != nil {
+			return err
+		}
+	}
+
+	if header.Version >= 2 {
+		files, _ := ioutil.ReadDir(regionDirFor(path))
+		for _, file := range files {
+			if err := checkRegionFile(filepath.Join(regionDirFor(path), file.Name())); err != nil {
+				return fmt.Errorf("region %s: %v", file.Name(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkRegionFile reads every chunk of a region file, which checks
+// their offsets, lengths and compression, but not the blocks in them
+func checkRegionFile(path string) error {
+	var rpos ChunkPos
+	if _, err := fmt.Sscanf(filepath.Base(path), "r.%d.%d.hlr", &rpos.X, &rpos.Y); err != nil {
+		return errors.New("not a region file name")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	table, err := readRegionTable(f)
+	if err != nil {
+		return err
+	}
+	for i, entry := range table {
+		if entry.Length == 0 {
+			continue
+		}
+		pos := ChunkPos{rpos.X*RegionSize + i/RegionSize, rpos.Y*RegionSize + i%RegionSize}
+		if _, err := readRegionEntry(f, entry, pos); err != nil {
+			return fmt.Errorf("chunk %d,%d: %v", pos.X, pos.Y, err)
+		}
+	}
+	return nil
+}
+
+func checkLegacyWorldFile(r io.Reader) error {
+	lines := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines++
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if lines < LegacyWorldWidth+LegacyWorldWidth*LegacyWorldHeight {
+		return errors.New("world file is incomplete")
+	}
+	return nil
+}
+
+//  --------------------------------------------------
+//  Helpers
+//  --------------------------------------------------
+
+func linkOrCopy(src, dest string) error {
+	if err := os.Link(src, dest); err == nil {
+		return nil
+	}
+	return copyFileAtomic(src, dest)
+}
+
+func copyFileAtomic(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	return writeFileAtomic(dest, func(w io.Writer) error {
+		_, err := io.Copy(w, in)
+		return err
+	})
+}
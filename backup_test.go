@@ -0,0 +1,234 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestFile replaces a file, rather than writing into
+// it, since backups may hold hard links to the old one
+func writeTestFile(t *testing.T, path, text string) {
+	t.Helper()
+	os.Remove(path)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(text), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkTestFile(t *testing.T, path, want string) {
+	t.Helper()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if string(data) != want {
+		t.Errorf("%s holds %q, want %q", filepath.Base(path), data, want)
+	}
+}
+
+func checkMissing(t *testing.T, path string) {
+	t.Helper()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("%s is still there", filepath.Base(path))
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "world.hln")
+	writeTestFile(t, path, "old")
+
+	err := writeFileAtomic(path, func(w io.Writer) error {
+		io.WriteString(w, "half")
+		return errors.New("disk full")
+	})
+	if err == nil {
+		t.Fatal("failed write returned no error")
+	}
+	checkTestFile(t, path, "old")
+
+	if err := writeFileAtomic(path, func(w io.Writer) error {
+		_, err := io.WriteString(w, "new")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	checkTestFile(t, path, "new")
+
+	// Neither write leaves its temporary file behind
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("%d files next to the world, want just the world", len(files))
+	}
+}
+
+func TestReplaceDir(t *testing.T) {
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "world")
+	src := tempDirFor(dest)
+
+	// With nothing in place yet
+	writeTestFile(t, filepath.Join(src, "r.0.0.hlr"), "first")
+	if err := replaceDir(src, dest); err != nil {
+		t.Fatal(err)
+	}
+	checkTestFile(t, filepath.Join(dest, "r.0.0.hlr"), "first")
+	checkMissing(t, src)
+
+	writeTestFile(t, filepath.Join(src, "r.0.0.hlr"), "second")
+	if err := replaceDir(src, dest); err != nil {
+		t.Fatal(err)
+	}
+	checkTestFile(t, filepath.Join(dest, "r.0.0.hlr"), "second")
+	checkTestFile(t, filepath.Join(oldDirFor(dest), "r.0.0.hlr"), "first")
+	checkMissing(t, src)
+}
+
+func TestRecoverDir(t *testing.T) {
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "world")
+
+	// Interrupted after the old directory was moved aside
+	writeTestFile(t, filepath.Join(oldDirFor(dest), "r.0.0.hlr"), "old")
+	writeTestFile(t, filepath.Join(tempDirFor(dest), "r.0.0.hlr"), "half")
+	if err := recoverDir(dest); err != nil {
+		t.Fatal(err)
+	}
+	checkTestFile(t, filepath.Join(dest, "r.0.0.hlr"), "old")
+	checkMissing(t, oldDirFor(dest))
+	checkMissing(t, tempDirFor(dest))
+
+	// Interrupted while writing the new directory
+	writeTestFile(t, filepath.Join(tempDirFor(dest), "r.0.0.hlr"), "half")
+	if err := recoverDir(dest); err != nil {
+		t.Fatal(err)
+	}
+	checkTestFile(t, filepath.Join(dest, "r.0.0.hlr"), "old")
+	checkMissing(t, tempDirFor(dest))
+
+	// Interrupted after the swap, the new directory stays
+	writeTestFile(t, filepath.Join(dest, "r.0.0.hlr"), "new")
+	writeTestFile(t, filepath.Join(oldDirFor(dest), "r.0.0.hlr"), "old")
+	if err := recoverDir(dest); err != nil {
+		t.Fatal(err)
+	}
+	checkTestFile(t, filepath.Join(dest, "r.0.0.hlr"), "new")
+
+	// Nothing to recover
+	if err := recoverDir(filepath.Join(dir, "never saved")); err != nil {
+		t.Errorf("recovering a world that was never saved: %v", err)
+	}
+}
+
+func TestBackupRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "world.hln")
+
+	if err := backupWorld(path); err != nil {
+		t.Fatalf("backing up a world that was never saved: %v", err)
+	}
+	if backups, _ := listBackups(path); len(backups) != 0 {
+		t.Fatalf("%d backups of a world that was never saved", len(backups))
+	}
+
+	for i := 0; i < MaxWorldBackups+2; i++ {
+		writeTestFile(t, path, string(rune('a'+i)))
+		writeTestFile(t, filepath.Join(regionDirFor(path), "r.0.0.hlr"), string(rune('a'+i)))
+		if err := backupWorld(path); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	backups, err := listBackups(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != MaxWorldBackups {
+		t.Fatalf("%d backups kept, want %d", len(backups), MaxWorldBackups)
+	}
+
+	// The oldest ones are gone
+	for i, backup := range backups {
+		want := string(rune('a' + MaxWorldBackups + 1 - i))
+		checkTestFile(t, backup, want)
+		checkTestFile(t, filepath.Join(regionDirFor(backup), "r.0.0.hlr"), want)
+	}
+	if age := backupAge(path); age < 0 || age > time.Minute {
+		t.Errorf("newest backup is %v old", age)
+	}
+}
+
+func TestRestoreNewestValidBackup(t *testing.T) {
+	tree := newTestWorld(t)
+	path := filepath.Join(t.TempDir(), "world.hln")
+	stone := GetIDFromName("stone")
+
+	// Every save backs up the one before it, so the
+	// backups hold stone up to 1, 2 and 3 blocks high
+	for y := 0; y < 4; y++ {
+		tree.SetLayerBlock(LayerWorld, 10, y, Tile{Block: stone})
+		if err := tree.WriteToFile(path); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	backups, err := listBackups(path)
+	if err != nil || len(backups) != 3 {
+		t.Fatalf("%d backups, want 3 (%v)", len(backups), err)
+	}
+	if got := newestValidBackup(path); got != backups[0] {
+		t.Fatalf("newest valid backup is %s, want %s", got, backups[0])
+	}
+
+	// A region whose chunk lies past the end of the file
+	region := filepath.Join(regionDirFor(backups[0]), "r.0.0.hlr")
+	os.Remove(region)
+	if err := copyFileAtomic(filepath.Join(regionDirFor(path), "r.0.0.hlr"), region); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(region)
+	if err := os.Truncate(region, info.Size()-1); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkWorldFile(backups[0]); err == nil {
+		t.Errorf("truncated region passed the check")
+	}
+
+	// A world file cut short
+	writeTestFile(t, backups[1], string(WorldFileMagic[:])+"\x06")
+	if err := checkWorldFile(backups[1]); err == nil {
+		t.Errorf("truncated world file passed the check")
+	}
+
+	backup := newestValidBackup(path)
+	if backup != backups[2] {
+		t.Fatalf("newest valid backup is %s, want %s", backup, backups[2])
+	}
+	if err := restoreBackup(backup, path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewWorldTree()
+	if err := loaded.LoadFromFile(path, noProgress); err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < 4; y++ {
+		want := Tile{}
+		if y < 1 {
+			want.Block = stone
+		}
+		if got := loaded.GetLayerTile(LayerWorld, 10, y); got != want {
+			t.Errorf("restored block at 10,%d is %+v, want %+v", y, got, want)
+		}
+	}
+	checkMissing(t, oldDirFor(regionDirFor(path)))
+}
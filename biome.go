@@ -0,0 +1,385 @@
+package main
+
+import (
+	"math/rand"
+	"rapidengine/procedural"
+	"sort"
+)
+
+//  --------------------------------------------------
+//  Biome.go contains the biomes and the biome map.
+//
+//  The biome map gives the biome of every column of
+//  the world. It is generated from noise by the first
+//  pass of world generation, and every later pass asks
+//  it what to place through getBiome. It is kept in the
+//  save, so the biomes of a world never change.
+//
+//  Biomes are ordered by the noise that picks them, so
+//  neighbouring biomes in the list tend to be neighbours
+//  in the world as well.
+//  --------------------------------------------------
+
+// Biome is the terrain, decorations, enemies and backgrounds of a part of the world
+type Biome struct {
+	Name string
+
+	// Share of the world covered by the biome, relative to the others
+	Weight float64
+
+	// Height of the ground, as fractions of the world height
+	// (see GrassMinimum and Flatness)
+	GroundLevel float64
+	Flatness    float64
+
+	// Blocks of the ground. The surface block replaces the
+	// subsurface block wherever it is exposed to the sky, and
+	// the surface overlay is put on top of it in the grass layer.
+	SurfaceBlock    string
+	SurfaceOverlay  string
+	SubsurfaceBlock string
+
+	// Nature blocks placed on top of the ground
+	Nature []Decoration
+
+	// Trees growing in the biome, one of which is
+	// planted on a surface block with TreeChance
+	Trees      []*TreeSpecies
+	TreeChance float32
+
+	// Lakes per 1000 columns of the biome (see liquids.go)
+	Lakes float64
+
+	// Enemies spawned in the biome
+	Enemies []EnemySpawn
+
+	// Parallax background layers, from front to back
+	Parallax []string
+}
+
+// Decoration is a group of nature blocks, one of which is
+// placed on a surface block with the given chance
+type Decoration struct {
+	Blocks []string
+	Chance float32
+}
+
+// EnemySpawn is an enemy type (see enemyTypes) and how often it spawns
+type EnemySpawn struct {
+	Type   string
+	Weight int
+}
+
+var flowers = []string{"flower1", "flower2", "flower3", "pebble"}
+var topGrass = []string{"topGrass1", "topGrass2", "topGrass3"}
+
+// Biomes are all the biomes, in the order of the noise that picks them
+var Biomes = []Biome{
+	{
+		Name:            "plains",
+		Weight:          3,
+		GroundLevel:     GrassMinimum,
+		Flatness:        Flatness,
+		SurfaceBlock:    "dirt",
+		SurfaceOverlay:  "grasstop",
+		SubsurfaceBlock: "dirt",
+		Nature: []Decoration{
+			{flowers, 0.12},
+			{topGrass, 0.25},
+		},
+		Trees:      []*TreeSpecies{&oakTree},
+		TreeChance: 0.05,
+		Lakes:      4,
+		Enemies:    []EnemySpawn{{"goblin", 1}},
+		Parallax: []string{
+			"assets/backgrounds/og1/1.png",
+			"assets/backgrounds/og1/2.png",
+			"assets/backgrounds/og1/3.png",
+			"assets/backgrounds/og1/4.png",
+			"assets/backgrounds/og1/5.png",
+			"assets/backgrounds/og1/6.png",
+		},
+	},
+	{
+		Name:            "forest",
+		Weight:          2,
+		GroundLevel:     GrassMinimum,
+		Flatness:        0.2,
+		SurfaceBlock:    "dirt",
+		SurfaceOverlay:  "grasstop",
+		SubsurfaceBlock: "dirt",
+		Nature: []Decoration{
+			{flowers, 0.05},
+			{topGrass, 0.45},
+		},
+		Trees:      []*TreeSpecies{&oakTree, &tallOakTree, &tallOakTree},
+		TreeChance: 0.3,
+		Lakes:      3,
+		Enemies:    []EnemySpawn{{"goblin", 1}},
+		Parallax: []string{
+			"assets/backgrounds/forest/trees1.png",
+			"assets/backgrounds/forest/trees2.png",
+			"assets/backgrounds/forest/trees3.png",
+			"assets/backgrounds/og1/6.png",
+		},
+	},
+	{
+		Name:            "mountains",
+		Weight:          2,
+		GroundLevel:     0.77,
+		Flatness:        0.4,
+		SurfaceBlock:    "stone",
+		SubsurfaceBlock: "stone",
+		Nature: []Decoration{
+			{[]string{"pebble"}, 0.1},
+		},
+		Enemies: []EnemySpawn{{"goblin", 1}},
+		Parallax: []string{
+			"assets/backgrounds/mountain2/mountain1.png",
+			"assets/backgrounds/mountain2/mountain2.png",
+			"assets/backgrounds/mountain2/mountain3.png",
+			"assets/backgrounds/mountain2/mountain4.png",
+		},
+	},
+	{
+		Name:            "snow",
+		Weight:          2,
+		GroundLevel:     0.76,
+		Flatness:        0.3,
+		SurfaceBlock:    "snowGrass",
+		SubsurfaceBlock: "dirt",
+		Nature: []Decoration{
+			{[]string{"pebble"}, 0.05},
+		},
+		Trees:      []*TreeSpecies{&pineTree},
+		TreeChance: 0.1,
+		Lakes:      2,
+		Enemies:    []EnemySpawn{{"goblin", 1}},
+		Parallax: []string{
+			"assets/backgrounds/snow/snow1.png",
+			"assets/backgrounds/snow/snow2.png",
+			"assets/backgrounds/snow/snow3.png",
+			"assets/backgrounds/snow/snow4.png",
+		},
+	},
+}
+
+// Average width of a biome in blocks
+const BiomeSize = 700
+
+// Biomes narrower than this are merged into their neighbours
+const MinBiomeWidth = 200
+
+// Ground height is blended over this many blocks
+// on each side of a border, so biomes don't meet at cliffs
+const BiomeBlendWidth = 40
+
+// Parallax backgrounds blend over this many blocks on each side of a border
+const ParallaxBlendWidth = 30
+
+// The ground never gets closer than this to the top of the world
+const MinSkyHeight = 100
+
+// BiomeMap holds the index into Biomes of every column of the world
+var BiomeMap = make([]uint8, WorldWidth)
+
+// getBiome returns the biome of a column
+func getBiome(x int) *Biome {
+	if x < 0 {
+		x = 0
+	}
+	if x >= WorldWidth {
+		x = WorldWidth - 1
+	}
+	return &Biomes[BiomeMap[x]]
+}
+
+// getBiomeIndex returns the index into Biomes of the named biome, or -1
+func getBiomeIndex(name string) int {
+	for i := range Biomes {
+		if Biomes[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+//  --------------------------------------------------
+//  Generation
+//  --------------------------------------------------
+
+func generateBiomeMap(rng *rand.Rand) {
+	gen := procedural.NewSimplexGenerator(1.0/BiomeSize, 1, 0.5, 2, rng.Int63())
+
+	noise := make([]float64, WorldWidth)
+	for x := range noise {
+		noise[x] = gen.Noise1D(float64(x))
+	}
+
+	// Split the noise into one band per biome by weight, so every biome
+	// covers about its share of the world whatever the range of the noise
+	sorted := append([]float64(nil), noise...)
+	sort.Float64s(sorted)
+
+	totalWeight := float64(0)
+	for _, biome := range Biomes {
+		totalWeight += biome.Weight
+	}
+	thresholds := make([]float64, len(Biomes)-1)
+	weight := float64(0)
+	for i := range thresholds {
+		weight += Biomes[i].Weight
+		thresholds[i] = sorted[int(weight/totalWeight*float64(WorldWidth-1))]
+	}
+
+	for x := range noise {
+		biome := 0
+		for biome < len(thresholds) && noise[x] > thresholds[biome] {
+			biome++
+		}
+		BiomeMap[x] = uint8(biome)
+	}
+
+	mergeSmallBiomes()
+}
+
+type biomeRun struct {
+	biome  uint8
+	length int
+}
+
+// mergeSmallBiomes gives biomes narrower than MinBiomeWidth to their neighbours
+func mergeSmallBiomes() {
+	var runs []biomeRun
+	for x := 0; x < WorldWidth; x++ {
+		if len(runs) > 0 && runs[len(runs)-1].biome == BiomeMap[x] {
+			runs[len(runs)-1].length++
+		} else {
+			runs = append(runs, biomeRun{BiomeMap[x], 1})
+		}
+	}
+
+	for i := 0; i < len(runs) && len(runs) > 1; {
+		if runs[i].length >= MinBiomeWidth {
+			i++
+			continue
+		}
+
+		if i == 0 {
+			runs[1].length += runs[0].length
+			runs = runs[1:]
+			continue
+		}
+
+		runs[i-1].length += runs[i].length
+		runs = append(runs[:i], runs[i+1:]...)
+		if i < len(runs) && runs[i].biome == runs[i-1].biome {
+			runs[i-1].length += runs[i].length
+			runs = append(runs[:i], runs[i+1:]...)
+		}
+	}
+
+	x := 0
+	for _, run := range runs {
+		for end := x + run.length; x < end; x++ {
+			BiomeMap[x] = run.biome
+		}
+	}
+}
+
+// blendBiomes returns a value of every column's biome averaged over
+// BiomeBlendWidth blocks on each side, to smooth out borders
+func blendBiomes(value func(biome *Biome) float64) []float64 {
+	sums := make([]float64, WorldWidth+1)
+	for x := 0; x < WorldWidth; x++ {
+		sums[x+1] = sums[x] + value(getBiome(x))
+	}
+
+	blended := make([]float64, WorldWidth)
+	for x := range blended {
+		left := x - BiomeBlendWidth
+		if left < 0 {
+			left = 0
+		}
+		right := x + BiomeBlendWidth + 1
+		if right > WorldWidth {
+			right = WorldWidth
+		}
+		blended[x] = (sums[right] - sums[left]) / float64(right-left)
+	}
+	return blended
+}
+
+//  --------------------------------------------------
+//  Biome Helpers
+//  --------------------------------------------------
+
+// pickDecoration returns a nature block to place, or "" for none
+func (biome *Biome) pickDecoration(rng *rand.Rand) string {
+	r := rng.Float32()
+	for _, d := range biome.Nature {
+		if r < d.Chance {
+			return d.Blocks[rng.Intn(len(d.Blocks))]
+		}
+		r -= d.Chance
+	}
+	return ""
+}
+
+// pickEnemy returns the type of enemy to spawn, or "" for none
+func (biome *Biome) pickEnemy() string {
+	total := 0
+	for _, spawn := range biome.Enemies {
+		total += spawn.Weight
+	}
+	if total <= 0 {
+		return ""
+	}
+
+	r := rand.Intn(total)
+	for _, spawn := range biome.Enemies {
+		if r < spawn.Weight {
+			return spawn.Type
+		}
+		r -= spawn.Weight
+	}
+	return ""
+}
+
+// biomeBlendAt returns the biomes whose backgrounds show at a position
+// in blocks, and how far it is from the first to the second, from 0 to 1.
+// Away from borders both are the biome at the position.
+func biomeBlendAt(x float32) (from, to *Biome, t float32) {
+	bx := int(x)
+	if bx < 0 {
+		bx = 0
+	}
+	if bx >= WorldWidth {
+		bx = WorldWidth - 1
+	}
+	here := BiomeMap[bx]
+
+	// Border on the right
+	for r := bx + 1; r < WorldWidth && r <= bx+ParallaxBlendWidth; r++ {
+		if BiomeMap[r] != here {
+			t = 0.5 * (1 - (float32(r)-x)/ParallaxBlendWidth)
+			if t < 0 {
+				t = 0
+			}
+			return &Biomes[here], &Biomes[BiomeMap[r]], t
+		}
+	}
+
+	// Border on the left
+	for l := bx; l > 0 && l >= bx-ParallaxBlendWidth; l-- {
+		if BiomeMap[l-1] != here {
+			t = 0.5 + 0.5*(x-float32(l))/ParallaxBlendWidth
+			if t > 1 {
+				t = 1
+			}
+			return &Biomes[BiomeMap[l-1]], &Biomes[here], t
+		}
+	}
+
+	return &Biomes[here], &Biomes[here], 0
+}
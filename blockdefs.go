@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//  --------------------------------------------------
+//  Blockdefs.go reads the block definitions.
+//
+//  Every block is defined in assets/blocks/blocks.json,
+//  so adding one takes no code. A definition looks like:
+//
+//    {
+//      "name": "stone",
+//      "id": 3,
+//      "texture": "stone/stone1.png",
+//      "orientation": 0,
+//      "saveColor": [116, 116, 116],
+//      "lightBlock": 0.15,
+//      "durability": 2.5
+//    }
+//
+//  Only name, id and saveColor are required. Textures
+//  are relative to assets/blocks. Blocks without an
+//  orientation variant never get their edges cut, the
+//  layer defaults to "world", and a block drops itself
+//  unless "drops" names another block, or is "" to drop
+//  nothing. Falling blocks set "falls" (see falling.go),
+//  and blocks with a "tier" can only be mined with a tool
+//  of at least that tier (see mining.go).
+//
+//  The definitions are checked when they are read, and
+//  every problem found is reported at once.
+//  --------------------------------------------------
+
+// BlockDefinitionsPath is the file every block is defined in
+const BlockDefinitionsPath = "./assets/blocks/blocks.json"
+
+// Block textures are relative to this directory
+const BlockTextureDir = "./assets/blocks"
+
+// Number of sets of transparency maps for orientations
+const NumOrientVariants = 2
+
+// Block IDs are saved as 16 bit numbers (see worldfile.go)
+const MaxBlockID = math.MaxUint16
+
+// layerNames are the names of the block layers in the definitions
+var layerNames = map[string]int{
+	"world":  LayerWorld,
+	"back":   LayerBack,
+	"nature": LayerNature,
+	"grass":  LayerGrass,
+	"light":  LayerLight,
+}
+
+// requiredBlocks are blocks the game or the recipes use by
+// name, which every set of definitions must have
+var requiredBlocks = []string{
+	"sky", "dirt", "stone", "backdirt", "leaves", "torch", "sand", "gravel", "stoneBrick",
+	"grass", "grasstop", "snowGrass", "shithyril", "denseShithyril",
+	"treeTrunk", "treeBottomRoot", "treeLeftRoot", "treeRightRoot", "treeBranchL1", "treeBranchR1",
+	"topGrass1", "topGrass2", "topGrass3", "flower1", "flower2", "flower3", "pebble",
+	"wood", "workbench", "furnace",
+}
+
+type blockDefinitions struct {
+	Blocks []blockDefinition `json:"blocks"`
+}
+
+// blockDefinition is a block as written in the definitions file
+type blockDefinition struct {
+	Name        string  `json:"name"`
+	ID          *int    `json:"id"`
+	Texture     string  `json:"texture"`
+	Orientation *int32  `json:"orientation"`
+	SaveColor   []int   `json:"saveColor"`
+	LightBlock  float32 `json:"lightBlock"`
+	Durability  float64 `json:"durability"`
+	Tier        int     `json:"tier"`
+	Layer       string  `json:"layer"`
+	Transparent bool    `json:"transparent"`
+	Falls       bool    `json:"falls"`
+	Drops       *string `json:"drops"`
+}
+
+// readBlockDefinitions reads and checks the block definitions file
+func readBlockDefinitions(path string) ([]blockDefinition, error) {
+	var defs blockDefinitions
+	if err := decodeJSONFile(path, &defs); err != nil {
+		return nil, fmt.Errorf("reading block definitions: %v", err)
+	}
+
+	if errs := validateBlockDefinitions(defs.Blocks); len(errs) > 0 {
+		return nil, fmt.Errorf("%s has %d problems:\n  %s", path, len(errs), strings.Join(errs, "\n  "))
+	}
+	return defs.Blocks, nil
+}
+
+// validateBlockDefinitions returns a description of every problem with the definitions
+func validateBlockDefinitions(defs []blockDefinition) []string {
+	var errs []string
+	fail := func(i int, def blockDefinition, format string, args ...interface{}) {
+		name := def.Name
+		if name == "" {
+			name = "unnamed"
+		}
+		errs = append(errs, fmt.Sprintf("block %d (%s): %s", i+1, name, fmt.Sprintf(format, args...)))
+	}
+
+	names := make(map[string]bool)
+	ids := make(map[int]string)
+	for i, def := range defs {
+		if def.Name == "" {
+			fail(i, def, "missing name")
+		} else if names[def.Name] {
+			fail(i, def, "name is used by another block")
+		}
+		names[def.Name] = true
+
+		switch {
+		case def.ID == nil:
+			fail(i, def, "missing id")
+		case *def.ID < 0 || *def.ID > MaxBlockID:
+			fail(i, def, "id %d is outside of 0 to %d", *def.ID, MaxBlockID)
+		case ids[*def.ID] != "":
+			fail(i, def, "id %d is used by %s", *def.ID, ids[*def.ID])
+		case *def.ID == 0 && def.Name != "sky":
+			fail(i, def, "id 0 is reserved for sky")
+		default:
+			ids[*def.ID] = def.Name
+		}
+
+		if def.Texture != "" {
+			if _, err := os.Stat(blockTexturePath(def.Texture)); err != nil {
+				fail(i, def, "texture %s doesn't exist", def.Texture)
+			}
+		}
+		if def.Orientation != nil {
+			if *def.Orientation < 0 || *def.Orientation >= NumOrientVariants {
+				fail(i, def, "orientation %d is outside of 0 to %d", *def.Orientation, NumOrientVariants-1)
+			}
+			if def.Texture == "" {
+				fail(i, def, "orientation needs a texture")
+			}
+		}
+
+		if len(def.SaveColor) != 3 {
+			fail(i, def, "saveColor needs 3 components, has %d", len(def.SaveColor))
+		}
+		for _, c := range def.SaveColor {
+			if c < 0 || c > 255 {
+				fail(i, def, "saveColor component %d is outside of 0 to 255", c)
+				break
+			}
+		}
+
+		if def.LightBlock < 0 {
+			fail(i, def, "lightBlock can't be negative")
+		}
+		if def.Durability < 0 {
+			fail(i, def, "durability can't be negative")
+		}
+		if def.Tier < 0 {
+			fail(i, def, "tier can't be negative")
+		}
+		if _, ok := layerNames[def.Layer]; def.Layer != "" && !ok {
+			fail(i, def, "unknown layer %q", def.Layer)
+		}
+	}
+
+	// Drops can name blocks defined later in the file
+	for i, def := range defs {
+		if def.Drops != nil && *def.Drops != "" && !names[*def.Drops] {
+			fail(i, def, "drops unknown block %q", *def.Drops)
+		}
+	}
+
+	for _, name := range requiredBlocks {
+		if !names[name] {
+			errs = append(errs, fmt.Sprintf("missing block %s, which the game needs", name))
+		}
+	}
+	return errs
+}
+
+// toBlock creates the block of a checked definition, without its materials
+func (def blockDefinition) toBlock() *Block {
+	block := &Block{
+		Name:        def.Name,
+		ID:          BlockID(*def.ID),
+		Texture:     def.Texture,
+		LightBlock:  def.LightBlock,
+		Durability:  def.Durability,
+		Tier:        def.Tier,
+		Falls:       def.Falls,
+		Layer:       LayerWorld,
+		Transparent: def.Transparent,
+		Drops:       def.Name,
+	}
+	copy(block.SaveColor[:], def.SaveColor)
+	if def.Layer != "" {
+		block.Layer = layerNames[def.Layer]
+	}
+	if def.Drops != nil {
+		block.Drops = *def.Drops
+	}
+	if def.Orientation != nil {
+		block.OrientEnabled = true
+		block.OrientVariation = *def.Orientation
+	}
+	return block
+}
+
+func blockTexturePath(texture string) string {
+	return filepath.Join(BlockTextureDir, filepath.FromSlash(texture))
+}
+
+// decodeJSONFile decodes a JSON file into v, refusing fields v
+// doesn't have. Errors in the file name the line they are on.
+func decodeJSONFile(path string, v interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		if syntax, ok := err.(*json.SyntaxError); ok {
+			return fmt.Errorf("%s:%d: %v", path, lineAt(data, syntax.Offset), err)
+		}
+		if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+			return fmt.Errorf("%s:%d: %v", path, lineAt(data, typeErr.Offset), err)
+		}
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// lineAt returns the line of a byte offset into a file
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
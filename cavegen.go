@@ -4,9 +4,7 @@ import (
 	"math/rand"
 )
 
-func generateCaves() {
-	randomizeSeed()
-
+func generateCaves(world *WorldTree, rng *rand.Rand) {
 	CaveMap = make([][]bool, WorldWidth)
 	for x := range CaveMap {
 		CaveMap[x] = make([]bool, WorldHeight)
@@ -16,7 +14,7 @@ func generateCaves() {
 	for x := 0; x < WorldWidth; x++ {
 		thresh := float32(CaveStartingThreshold)
 		for y := HeightMap[x]; y >= 0; y-- {
-			if n := rand.Float32(); n < thresh {
+			if n := rng.Float32(); n < thresh {
 				CaveMap[x][y] = true
 			}
 			thresh += CaveThresholdDelta
@@ -33,7 +31,7 @@ func generateCaves() {
 
 	// Randomly kill some caves
 	for i := 0; i < 2000; i++ {
-		removeRandomCave()
+		removeRandomCave(rng)
 	}
 
 	// Do second round of cellular automata simulations (enlarge)
@@ -49,8 +47,8 @@ func generateCaves() {
 		for y := 0; y < WorldHeight; y++ {
 			if CaveMap[x][y] {
 				if y <= HeightMap[x] {
-					WorldMap.RemoveWorldBlock(x, y)
-					createBackBlock(x, y, "backdirt")
+					world.RemoveWorldBlock(x, y)
+					world.createBackBlock(x, y, "backdirt")
 				}
 			}
 		}
@@ -86,10 +84,10 @@ func caveSimulationStep(birthLim, deathLim int) {
 	CaveMap = newMap
 }
 
-func removeRandomCave() {
+func removeRandomCave(rng *rand.Rand) {
 	for i := 0; i < 100; i++ {
-		x := rand.Intn(WorldWidth)
-		y := rand.Intn(WorldHeight)
+		x := rng.Intn(WorldWidth)
+		y := rng.Intn(WorldHeight)
 		if CaveMap[x][y] {
 			killCave(x, y)
 			return
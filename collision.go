@@ -1,3 +1,6 @@
+//go:build !gen
+// +build !gen
+
 package main
 
 type Hitbox struct {
@@ -132,7 +135,8 @@ func CheckWorldCollision(hb Hitbox, vx, vy, selfx, selfy float32) (bool, bool, b
 	// Broad phase collision
 	for x := px - 3; x < pex+3; x++ {
 		for y := py - 3; y < pey+3; y++ {
-			if block := WorldMap.GetWorldBlock(x, y); block.ID != "00000" {
+			if WorldMap.GetWorldBlockID(x, y) != BlockEmpty {
+				block := WorldMap.GetWorldBlock(x, y)
 				l, r, u, d := hb.CheckCollisionAABB(AABB{block.X, block.Y, BlockSize, BlockSize, 0, 0}, vx, vy, selfx, selfy)
 				if l {
 					left = true
@@ -150,13 +154,15 @@ func CheckWorldCollision(hb Hitbox, vx, vy, selfx, selfy float32) (bool, bool, b
 		}
 	}
 
-	if block := WorldMap.GetWorldBlock(px-1, py+1); block.ID != "00000" {
+	if WorldMap.GetWorldBlockID(px-1, py+1) != BlockEmpty {
+		block := WorldMap.GetWorldBlock(px-1, py+1)
 		if l, _, _, _ := hb.CheckCollisionAABB(AABB{block.X, block.Y, BlockSize, BlockSize, 0, 0}, vx, vy, selfx, selfy); l {
 			topleft = true
 		}
 	}
 
-	if block := WorldMap.GetWorldBlock(pex+1, py+1); block.ID != "00000" {
+	if WorldMap.GetWorldBlockID(pex+1, py+1) != BlockEmpty {
+		block := WorldMap.GetWorldBlock(pex+1, py+1)
 		if _, r, _, _ := hb.CheckCollisionAABB(AABB{block.X, block.Y, BlockSize, BlockSize, 0, 0}, vx, vy, selfx, selfy); r {
 			topright = true
 		}
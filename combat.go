@@ -0,0 +1,224 @@
+//go:build !gen
+// +build !gen
+
+package main
+
+import (
+	"fmt"
+	"rapidengine/material"
+	"rapidengine/ui"
+)
+
+//  --------------------------------------------------
+//  Combat.go contains what happens when something gets
+//  hit, shared by the player and enemies.
+//
+//  A hit knocks its target away from the attacker and
+//  stuns it for a moment, so enemies stop chasing and
+//  attacking and the player stops taking input. Things
+//  that were hit flash while they are stunned or
+//  invincible, and the damage floats up from them as a
+//  number. Numbers are text boxes from a fixed pool in
+//  the hotbar scene, since text can't be removed from a
+//  scene once added.
+//  --------------------------------------------------
+
+// Seconds an enemy that was hit can't move or attack by itself
+const EnemyHitStun = 0.25
+
+// Seconds the player can't move or attack after being hit
+const PlayerHitStun = 0.2
+
+// Speed the player is knocked away at by enemy attacks
+const PlayerKnockback = 350
+
+// Speed enemies are knocked away at by punches
+const PunchKnockback = 150
+
+// Flashing switches this many times per second
+const FlashRate = 16
+
+// Color and strength of the flash
+var FlashHue = [4]float32{255, 255, 255, 255}
+
+const FlashDiffuse = 0.4
+
+// Seconds a damage number floats for, and how fast it rises
+const DamageNumberTime = 0.8
+const DamageNumberSpeed = 60
+
+// Damage numbers of each color shown at once
+const NumDamageNumbers = 12
+
+type damageNumberPool [NumDamageNumbers]*DamageNumber
+
+// DamageNumber is a number floating up from something that was hit
+type DamageNumber struct {
+	Text *ui.TextBox
+
+	// Position in pixels, in the world
+	X float32
+	Y float32
+
+	// Seconds since the hit, DamageNumberTime once gone
+	Age float64
+}
+
+// Pools of damage numbers, by who was hit
+var (
+	EnemyDamageNumbers  damageNumberPool
+	CritDamageNumbers   damageNumberPool
+	PlayerDamageNumbers damageNumberPool
+)
+
+var damageNumberPools = []*damageNumberPool{&EnemyDamageNumbers, &CritDamageNumbers, &PlayerDamageNumbers}
+
+func InitializeDamageNumbers() {
+	newPool := func(pool *damageNumberPool, color [3]float32, scale float32) {
+		for i := range pool {
+			text := Engine.TextControl.NewTextBox("", "pixel", 0, 0, scale, color)
+			HotbarScene.InstanceText(text)
+			pool[i] = &DamageNumber{Text: text, Age: DamageNumberTime}
+		}
+	}
+	newPool(&EnemyDamageNumbers, [3]float32{255, 255, 255}, 0.5)
+	newPool(&CritDamageNumbers, [3]float32{255, 210, 60}, 0.7)
+	newPool(&PlayerDamageNumbers, [3]float32{255, 70, 70}, 0.5)
+}
+
+//  --------------------------------------------------
+//  Hits
+//  --------------------------------------------------
+
+// hitEnemy damages an enemy, knocking it away from a position
+func hitEnemy(enemy Enemy, damage, fromX, knockback float32, crit bool) {
+	c := enemy.GetCommon()
+	enemy.Damage(damage)
+	knockBack(c, fromX, knockback)
+
+	// Getting hit interrupts an attack
+	if c.State == "attacking" {
+		c.State = "normal"
+		c.MonsterMaterial.PlayAnimation("idle")
+		c.CurrentAnim = "idle"
+	}
+
+	bounds := hitboxBounds(c.Hitbox1)
+	pool := &EnemyDamageNumbers
+	if crit {
+		pool = &CritDamageNumbers
+	}
+	showDamageNumber(pool, damage, bounds.X+bounds.Width/2, bounds.Y+bounds.Height)
+}
+
+// Enemies in lava are burned as often as the player is
+const EnemyBurnInterval = PlayerInvincibility
+
+// burnEnemy hits an enemy standing in a liquid that hurts,
+// once every EnemyBurnInterval seconds
+func burnEnemy(enemy Enemy, dt float64) {
+	c := enemy.GetCommon()
+	if c.BurnCooldown > 0 {
+		c.BurnCooldown -= dt
+		return
+	}
+	liquid := c.liquid()
+	if liquid == nil || liquid.Damage <= 0 {
+		return
+	}
+	c.BurnCooldown = EnemyBurnInterval
+	bounds := hitboxBounds(c.Hitbox1)
+	hitEnemy(enemy, liquid.Damage*EnemyBurnInterval, bounds.X+bounds.Width/2, 0, false)
+}
+
+// knockBack knocks an enemy away from a position, stunning it
+func knockBack(c *Common, fromX, speed float32) {
+	// Positive VX moves to the left
+	if c.Hitbox1.X+c.Hitbox1.DAABB.Width/2 > fromX {
+		speed = -speed
+	}
+	c.MonsterChild.VX = speed
+	c.MonsterChild.VY = BaseSpeedY / 2
+	c.HitStun = EnemyHitStun
+}
+
+// HitFrom hits the player with an attack from a position,
+// knocking them away from it
+func (p *Player) HitFrom(damage, fromX, knockback float32) {
+	if !p.Hit(damage) || p.Dead {
+		return
+	}
+
+	// Positive VX moves to the left
+	if p.CenterX+p.Hitbox1.DAABB.Width/2 > fromX {
+		knockback = -knockback
+	}
+	p.PlayerChild.VX = knockback
+	p.PlayerChild.VY = BaseSpeedY / 3
+	p.HitStun = PlayerHitStun
+}
+
+// flash makes a material flash while a timer runs
+func flash(mat *material.BasicMaterial, timer float64) {
+	if timer > 0 && int(timer*FlashRate)%2 == 0 {
+		mat.Hue = FlashHue
+		mat.DiffuseLevel = FlashDiffuse
+	} else {
+		mat.DiffuseLevel = 1
+	}
+}
+
+//  --------------------------------------------------
+//  Damage numbers
+//  --------------------------------------------------
+
+// showDamageNumber floats a number up from a position in pixels,
+// reusing the oldest number of the pool
+func showDamageNumber(pool *damageNumberPool, damage, x, y float32) {
+	var oldest *DamageNumber
+	for _, number := range pool {
+		if number == nil {
+			return
+		}
+		if oldest == nil || number.Age > oldest.Age {
+			oldest = number
+		}
+	}
+	oldest.Text.Text = fmt.Sprintf("%.0f", damage)
+	oldest.X = x
+	oldest.Y = y
+	oldest.Age = 0
+}
+
+// updateDamageNumbers floats every damage number up,
+// placing it on the screen over its spot in the world
+func updateDamageNumbers(dt float64) {
+	camX, camY, _ := Engine.Renderer.MainCamera.GetPosition()
+	for _, pool := range damageNumberPools {
+		for _, number := range pool {
+			if number == nil || number.Age >= DamageNumberTime {
+				continue
+			}
+			number.Age += dt
+			if number.Age >= DamageNumberTime {
+				number.Text.Text = ""
+				continue
+			}
+			number.Y += DamageNumberSpeed * float32(dt)
+			number.Text.X = number.X - camX + float32(Engine.Config.ScreenWidth)/2 - number.Text.GetLength()/2
+			number.Text.Y = number.Y - camY + float32(Engine.Config.ScreenHeight)/2
+		}
+	}
+}
+
+// clearDamageNumbers removes every damage number, for when a different world is loaded
+func clearDamageNumbers() {
+	for _, pool := range damageNumberPools {
+		for _, number := range pool {
+			if number != nil {
+				number.Text.Text = ""
+				number.Age = DamageNumberTime
+			}
+		}
+	}
+}
@@ -1,3 +1,6 @@
+//go:build !gen
+// +build !gen
+
 package main
 
 import (
@@ -40,6 +43,12 @@ type Common struct {
 	CurrentAnim   string
 	AttackTimeout float64
 
+	// Seconds left of being stunned by a hit, see knockBack
+	HitStun float64
+
+	// Seconds until lava can burn the monster again, see burnEnemy
+	BurnCooldown float64
+
 	// Health bar
 	HealthBar *ui.ProgressBar
 	HOffsetX  float32
@@ -52,6 +61,7 @@ func (c *Common) Update() {
 	c.UpdateState()
 	c.UpdateMovement()
 	c.UpdateAnimations()
+	flash(c.MonsterMaterial, c.HitStun)
 
 	c.HealthBar.SetPercentage(c.Health / c.MaxHealth * 100)
 	c.HealthBar.Update(nil)
@@ -87,13 +97,25 @@ func (c *Common) UpdateState() {
 	}
 }
 
+// liquid returns the liquid the monster is in, or nil
+func (c *Common) liquid() *LiquidType {
+	return liquidAt(c.Hitbox1.X+c.Hitbox1.DAABB.Width/2, c.Hitbox1.Y+c.Hitbox1.LAABB.Height/4)
+}
+
 // UpdateMovement handles world collision detection
 // and moving the mob to a target point, as well as
 // the health bar
 func (c *Common) UpdateMovement() {
+	// Liquids slow monsters down, lava burns them in burnEnemy
+	drag := float32(1)
+	liquid := c.liquid()
+	if liquid != nil {
+		drag = liquid.Drag
+	}
+
 	// Update position
-	c.MonsterChild.X += c.MonsterChild.VX * -float32(Engine.Renderer.DeltaFrameTime)
-	c.MonsterChild.Y += c.MonsterChild.VY * float32(Engine.Renderer.DeltaFrameTime)
+	c.MonsterChild.X += c.MonsterChild.VX * -float32(Engine.Renderer.DeltaFrameTime) * drag
+	c.MonsterChild.Y += c.MonsterChild.VY * float32(Engine.Renderer.DeltaFrameTime) * drag
 
 	// Update collision data
 	hx := c.MonsterChild.X + (c.MonsterChild.ScaleX / 2) - (c.Hitbox1.DAABB.Width / 2) + c.Hitbox1.OffX
@@ -116,10 +138,12 @@ func (c *Common) UpdateMovement() {
 	c.aHitbox.X = (hx + (c.Hitbox1.DAABB.Width / 2)) + (c.aHitbox.OffX+c.aHitbox.Width)*float32(flip-1)
 	c.aHitbox.Y = hy + c.aHitbox.OffY
 
-	// Move toward target
+	// Move toward target, unless stunned
 	dx := c.TargetX - c.MonsterChild.X
 	//dy := c.TargetY - c.MonsterChild.Y
-	if dx > 25 || dx < -25 {
+	if c.HitStun > 0 {
+		c.HitStun -= Engine.Renderer.DeltaFrameTime
+	} else if dx > 25 || dx < -25 {
 		if dx > 25 {
 			c.Direction = 1
 		} else {
@@ -135,6 +159,14 @@ func (c *Common) UpdateMovement() {
 	} else {
 		c.MonsterChild.VY -= BaseGravity * float32(Engine.Renderer.DeltaFrameTime) * c.VYMult
 	}
+
+	// Swimming
+	if liquid != nil {
+		c.NumJumps = 1
+		if c.MonsterChild.VY < -BaseSpeedY {
+			c.MonsterChild.VY = -BaseSpeedY
+		}
+	}
 	if top && c.MonsterChild.VY > 10 {
 		c.MonsterChild.VY = 0
 	}
@@ -183,7 +215,7 @@ func (c *Common) UpdateAttacking() {
 	dx := c.MonsterChild.X - Player1.PlayerChild.X
 	absdx := math.Abs(float64(dx))
 
-	if absdx < 100 && c.State == "normal" {
+	if absdx < 100 && c.State == "normal" && c.HitStun <= 0 {
 		if c.AttackTimeout < 0 {
 			c.FacePlayer()
 			c.Attack()
@@ -254,8 +286,12 @@ func (c *Common) Attack() {
 }
 
 func (c *Common) AttackHitFrame() {
+	// Attacks are interrupted by getting hit
+	if c.State != "attacking" || c.HitStun > 0 {
+		return
+	}
 	if c.CheckPlayerCollision() {
-		Player1.Hit(25)
+		Player1.HitFrom(25, c.Hitbox1.X+c.Hitbox1.DAABB.Width/2, PlayerKnockback)
 	}
 }
 
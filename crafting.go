@@ -0,0 +1,217 @@
+//go:build !gen
+// +build !gen
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+//  --------------------------------------------------
+//  Crafting.go contains recipes, which turn items into
+//  other items.
+//
+//  Every recipe is defined in assets/recipes.json:
+//
+//    {
+//      "output": "stoneBrick",
+//      "count": 1,
+//      "inputs": [{"item": "stone", "count": 2}],
+//      "station": "furnace"
+//    }
+//
+//  Count defaults to 1. A recipe with a station can only
+//  be crafted within StationRange blocks of a block of
+//  that name, so placing a workbench or furnace unlocks
+//  more recipes. Recipes are crafted from the inventory
+//  screen (see scene_inventory.go).
+//  --------------------------------------------------
+
+// RecipesPath is the file every recipe is defined in
+const RecipesPath = "./assets/recipes.json"
+
+// Blocks a station can be away from the player and still be used
+const StationRange = 5
+
+// Recipe turns a set of items into a stack of another
+type Recipe struct {
+	Output string
+	Count  int32
+	Inputs []ItemStack
+
+	// Block that has to be near the player, or "" for none
+	Station string
+}
+
+// Recipes are all the recipes, in the order they are defined
+var Recipes []*Recipe
+
+// stationBlocks are the blocks recipes use as stations
+var stationBlocks map[string]bool
+
+type recipeDefinitions struct {
+	Recipes []recipeDefinition `json:"recipes"`
+}
+
+// recipeDefinition is a recipe as written in the recipes file
+type recipeDefinition struct {
+	Output  string        `json:"output"`
+	Count   int32         `json:"count"`
+	Inputs  []recipeInput `json:"inputs"`
+	Station string        `json:"station"`
+}
+
+type recipeInput struct {
+	Item  string `json:"item"`
+	Count int32  `json:"count"`
+}
+
+// loadRecipes reads every recipe. Blocks and tools have to be loaded first.
+func loadRecipes() error {
+	defs, err := readRecipeDefinitions(RecipesPath)
+	if err != nil {
+		return err
+	}
+
+	Recipes = nil
+	stationBlocks = make(map[string]bool)
+	for _, def := range defs {
+		recipe := def.toRecipe()
+		Recipes = append(Recipes, recipe)
+		if recipe.Station != "" {
+			stationBlocks[recipe.Station] = true
+		}
+	}
+	return nil
+}
+
+// readRecipeDefinitions reads and checks the recipes file
+func readRecipeDefinitions(path string) ([]recipeDefinition, error) {
+	var defs recipeDefinitions
+	if err := decodeJSONFile(path, &defs); err != nil {
+		return nil, fmt.Errorf("reading recipes: %v", err)
+	}
+
+	if errs := validateRecipeDefinitions(defs.Recipes); len(errs) > 0 {
+		return nil, fmt.Errorf("%s has %d problems:\n  %s", path, len(errs), strings.Join(errs, "\n  "))
+	}
+	return defs.Recipes, nil
+}
+
+// validateRecipeDefinitions returns a description of every problem with the recipes
+func validateRecipeDefinitions(defs []recipeDefinition) []string {
+	var errs []string
+	fail := func(i int, def recipeDefinition, format string, args ...interface{}) {
+		name := def.Output
+		if name == "" {
+			name = "no output"
+		}
+		errs = append(errs, fmt.Sprintf("recipe %d (%s): %s", i+1, name, fmt.Sprintf(format, args...)))
+	}
+
+	for i, def := range defs {
+		switch {
+		case def.Output == "":
+			fail(i, def, "missing output")
+		case !isItem(def.Output):
+			fail(i, def, "unknown output %q", def.Output)
+		case def.Count < 0 || def.Count > maxStack(def.Output):
+			fail(i, def, "count %d is outside of 1 to %d", def.Count, maxStack(def.Output))
+		}
+
+		if len(def.Inputs) == 0 {
+			fail(i, def, "missing inputs")
+		}
+		inputs := make(map[string]bool)
+		for _, input := range def.Inputs {
+			if !isItem(input.Item) {
+				fail(i, def, "unknown input %q", input.Item)
+			}
+			if inputs[input.Item] {
+				fail(i, def, "input %s is listed twice", input.Item)
+			}
+			inputs[input.Item] = true
+			if input.Count < 1 {
+				fail(i, def, "input %s needs a count of at least 1", input.Item)
+			}
+		}
+
+		if def.Station != "" && GetBlock(def.Station) == nil {
+			fail(i, def, "unknown station block %q", def.Station)
+		}
+	}
+	return errs
+}
+
+// toRecipe creates the recipe of a checked definition
+func (def recipeDefinition) toRecipe() *Recipe {
+	recipe := &Recipe{
+		Output:  def.Output,
+		Count:   def.Count,
+		Station: def.Station,
+	}
+	if recipe.Count == 0 {
+		recipe.Count = 1
+	}
+	for _, input := range def.Inputs {
+		recipe.Inputs = append(recipe.Inputs, ItemStack{Name: input.Item, Count: input.Count})
+	}
+	return recipe
+}
+
+// nearbyStations returns the stations within StationRange blocks
+// of a position in pixels
+func nearbyStations(x, y float32) map[string]bool {
+	stations := make(map[string]bool)
+	bx, by := int(x/BlockSize), int(y/BlockSize)
+	for sx := bx - StationRange; sx <= bx+StationRange; sx++ {
+		for sy := by - StationRange; sy <= by+StationRange; sy++ {
+			if name := WorldMap.GetWorldBlockName(sx, sy); stationBlocks[name] {
+				stations[name] = true
+			}
+		}
+	}
+	return stations
+}
+
+// CanCraft returns whether an inventory holds the inputs of a
+// recipe, and its station is one of a set of stations
+func (r *Recipe) CanCraft(inv *Inventory, stations map[string]bool) bool {
+	if r.Station != "" && !stations[r.Station] {
+		return false
+	}
+	for _, input := range r.Inputs {
+		if !inv.Has(input.Name, input.Count) {
+			return false
+		}
+	}
+	return true
+}
+
+// craftableRecipes returns every recipe that can be crafted
+// from an inventory with a set of stations
+func craftableRecipes(inv *Inventory, stations map[string]bool) []*Recipe {
+	var recipes []*Recipe
+	for _, r := range Recipes {
+		if r.CanCraft(inv, stations) {
+			recipes = append(recipes, r)
+		}
+	}
+	return recipes
+}
+
+// Craft crafts a recipe from the player's inventory, dropping what
+// doesn't fit. Returns false if the recipe can't be crafted.
+func (p *Player) Craft(r *Recipe) bool {
+	if !r.CanCraft(p.Inventory, nearbyStations(p.CenterX, p.CenterY)) {
+		return false
+	}
+	for _, input := range r.Inputs {
+		p.Inventory.Remove(input.Name, input.Count)
+	}
+	if left := r.Count - p.Inventory.Add(r.Output, r.Count); left > 0 {
+		dropItem(r.Output, left, p.CenterX, p.CenterY)
+	}
+	return true
+}
@@ -0,0 +1,256 @@
+//go:build !gen
+// +build !gen
+
+package main
+
+import (
+	"math"
+	"math/rand"
+	"rapidengine/child"
+	"rapidengine/cmd"
+	"rapidengine/geometry"
+	"rapidengine/material"
+)
+
+//  --------------------------------------------------
+//  Drops.go contains items dropped into the world.
+//
+//  Destroyed blocks drop what their Drops names (see
+//  blockdefs.go) as a DroppedItem, which falls and
+//  collides with the world like everything else. Stacks
+//  of the same item close together merge, items near
+//  the player fly towards them and are picked up, and
+//  items nobody picks up despawn after a while.
+//  Dropped items are saved with the world.
+//  --------------------------------------------------
+
+// DroppedItem is a stack of items lying in the world
+type DroppedItem struct {
+	Name  string
+	Count int32
+
+	// Position of the bottom left corner in pixels
+	X  float32
+	Y  float32
+	VX float32
+	VY float32
+
+	// Seconds since the item was dropped
+	Age float64
+}
+
+// DroppedItems are all the items lying in the world
+var DroppedItems []*DroppedItem
+
+// Width and height of a dropped item in pixels
+const ItemSize = 16
+
+// Seconds before a dropped item disappears
+const ItemDespawnTime = 300
+
+// Stacks of the same item closer than this merge, in pixels
+const ItemMergeRadius = 24
+
+// Items closer to the player than ItemMagnetRadius fly towards
+// them at ItemMagnetSpeed, and are picked up within ItemPickupRadius
+const ItemMagnetRadius = 96
+const ItemMagnetSpeed = 400
+const ItemPickupRadius = 20
+
+// Dropped items slow down by this fraction per second on the ground
+const ItemFriction = 0.95
+
+var ItemChild *child.Child2D
+
+// itemHitbox is the hitbox of every dropped item
+var itemHitbox = Hitbox{
+	LAABB: AABB{X: 0, Y: 2, Width: 2, Height: ItemSize - 4},
+	RAABB: AABB{X: ItemSize - 2, Y: 2, Width: 2, Height: ItemSize - 4},
+	UAABB: AABB{X: 2, Y: ItemSize - 2, Width: ItemSize - 4, Height: 2},
+	DAABB: AABB{X: 2, Y: 0, Width: ItemSize - 4, Height: 2},
+}
+
+func initializeDroppedItems() {
+	ItemChild = Engine.ChildControl.NewChild2D()
+	ItemChild.AttachMesh(geometry.NewRectangle())
+	ItemChild.ScaleX = ItemSize
+	ItemChild.ScaleY = ItemSize
+	ItemChild.EnableCopying()
+}
+
+// itemMaterial returns the material an item is drawn with
+func itemMaterial(name string) *material.BasicMaterial {
+	switch itemKind(name) {
+	case ItemTool:
+		return GetTool(name).Material
+	case ItemWeapon:
+		return GetWeapon(name).Icon
+	case ItemWand:
+		return GetWand(name).Icon
+	case ItemArmor:
+		return GetArmor(name).Icon
+	case ItemBlock:
+		return GetBlock(name).GetMaterial(OrientNN)
+	}
+	return nil
+}
+
+// isItem returns whether a name is an item that can be held
+func isItem(name string) bool {
+	return itemKind(name) != ItemNone
+}
+
+// dropItem drops a stack of items at a position in pixels, popping up a little
+func dropItem(name string, count int32, x, y float32) {
+	DroppedItems = append(DroppedItems, &DroppedItem{
+		Name:  name,
+		Count: count,
+		X:     x - ItemSize/2,
+		Y:     y - ItemSize/2,
+		VX:    (rand.Float32() - 0.5) * 100,
+		VY:    200,
+	})
+}
+
+// dropBlockItems drops what a block leaves behind when
+// it is destroyed, in the middle of its tile
+func dropBlockItems(x, y int, name string) {
+	block := GetBlock(name)
+	if block == nil || block.Drops == "" {
+		return
+	}
+	dropItem(block.Drops, 1, float32(x*BlockSize+BlockSize/2), float32(y*BlockSize+BlockSize/2))
+}
+
+// updateDroppedItems moves, merges and picks up every dropped item
+func updateDroppedItems(dt float32) {
+	mergeDroppedItems()
+
+	items := DroppedItems[:0]
+	for _, item := range DroppedItems {
+		if !item.update(dt) {
+			items = append(items, item)
+		}
+	}
+	for i := len(items); i < len(DroppedItems); i++ {
+		DroppedItems[i] = nil
+	}
+	DroppedItems = items
+}
+
+// update moves a dropped item, returning true once it is gone
+func (item *DroppedItem) update(dt float32) bool {
+	item.Age += float64(dt)
+	if item.Age > ItemDespawnTime || item.Count <= 0 {
+		return true
+	}
+
+	// Fly towards the player when they are close
+	if Player1.PlayerChild != nil && !Player1.Dead {
+		dx := Player1.CenterX + Player1.Hitbox1.DAABB.Width/2 - (item.X + ItemSize/2)
+		dy := Player1.CenterY + Player1.Hitbox1.LAABB.Height/2 - (item.Y + ItemSize/2)
+		dist := float32(math.Sqrt(float64(dx*dx + dy*dy)))
+
+		if dist < ItemPickupRadius {
+			item.Count -= Player1.GiveItem(item.Name, item.Count)
+			return item.Count <= 0
+		}
+		if dist < ItemMagnetRadius {
+			item.VX = dx / dist * ItemMagnetSpeed
+			item.VY = dy / dist * ItemMagnetSpeed
+			item.collide(dt)
+			item.X += item.VX * dt
+			item.Y += item.VY * dt
+			return false
+		}
+	}
+
+	item.VY -= BaseGravity * dt
+	if item.VY < -MaxFallSpeed {
+		item.VY = -MaxFallSpeed
+	}
+	item.collide(dt)
+
+	item.X += item.VX * dt
+	item.Y += item.VY * dt
+	return false
+}
+
+// collide stops a dropped item from moving into blocks
+func (item *DroppedItem) collide(dt float32) {
+	top, left, bottom, right, _, _ := CheckWorldCollision(itemHitbox, item.VX, item.VY, item.X, item.Y)
+	if bottom && item.VY < 0 {
+		item.VY = 0
+		item.VX -= item.VX * ItemFriction * dt
+	}
+	if top && item.VY > 0 {
+		item.VY = 0
+	}
+	if (left && item.VX < 0) || (right && item.VX > 0) {
+		item.VX = 0
+	}
+}
+
+// mergeDroppedItems merges stacks of the same item close to each other
+func mergeDroppedItems() {
+	for i, item := range DroppedItems {
+		if item.Count <= 0 || item.Count >= MaxStackSize {
+			continue
+		}
+		for _, other := range DroppedItems[i+1:] {
+			if other.Name != item.Name || other.Count <= 0 {
+				continue
+			}
+			dx, dy := other.X-item.X, other.Y-item.Y
+			if dx*dx+dy*dy > ItemMergeRadius*ItemMergeRadius {
+				continue
+			}
+
+			moved := other.Count
+			if item.Count+moved > MaxStackSize {
+				moved = MaxStackSize - item.Count
+			}
+			item.Count += moved
+			other.Count -= moved
+			if item.Age > other.Age {
+				item.Age = other.Age
+			}
+			if item.Count >= MaxStackSize {
+				break
+			}
+		}
+	}
+}
+
+func renderDroppedItems(renderer *cmd.Renderer) {
+	for _, item := range DroppedItems {
+		renderer.RenderCopy(ItemChild, child.ChildCopy{
+			X:        item.X,
+			Y:        item.Y,
+			Material: itemMaterial(item.Name),
+			Darkness: WorldMap.GetDarkness(int((item.X+ItemSize/2)/BlockSize), int((item.Y+ItemSize/2)/BlockSize)),
+		})
+	}
+}
+
+// saveDroppedItems copies every dropped item, for saving
+func saveDroppedItems() []DroppedItem {
+	items := make([]DroppedItem, 0, len(DroppedItems))
+	for _, item := range DroppedItems {
+		items = append(items, *item)
+	}
+	return items
+}
+
+// loadDroppedItems replaces every dropped item with the ones from a save
+func loadDroppedItems(items []DroppedItem) {
+	DroppedItems = nil
+	for i := range items {
+		item := items[i]
+		if !isItem(item.Name) {
+			logInfo("Skipping unknown dropped item " + item.Name)
+			continue
+		}
+		DroppedItems = append(DroppedItems, &item)
+	}
+}
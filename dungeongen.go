@@ -26,7 +26,7 @@ type Corridor struct {
 	points []Point
 }
 
-func generateAllDungeons() {
+func generateAllDungeons(world *WorldTree, rng *rand.Rand) {
 	// Number of dungeons to be generated
 	numDungeons := 20
 
@@ -51,10 +51,10 @@ func generateAllDungeons() {
 		//Slice of Corridors
 		corridors := make([]Corridor, 0)
 
-		startx := rand.Intn(WorldWidth - dungeonWidth - maxRoomWidth)
-		starty := HeightMap[startx] - rand.Intn(HeightMap[startx]-dungeonHeight-maxRoomHeight)
+		startx := rng.Intn(WorldWidth - dungeonWidth - maxRoomWidth)
+		starty := HeightMap[startx] - rng.Intn(HeightMap[startx]-dungeonHeight-maxRoomHeight)
 
-		numRooms := 1 + rand.Intn(maxNumRooms)
+		numRooms := 1 + rng.Intn(maxNumRooms)
 
 		// for currRooms := 0; currRooms < numRooms; {
 		// 	tempRoom := generateRoom(dungeonWidth, dungeonHeight, maxRoomWidth, maxRoomHeight, startx, starty)
@@ -77,7 +77,7 @@ func generateAllDungeons() {
 
 		// Generates Rooms
 		for r := 0; r < numRooms; r++ {
-			tempRoom := generateRoom(dungeonWidth, dungeonHeight, maxRoomWidth, maxRoomHeight, minRoomWidth, minRoomHeight, startx, starty)
+			tempRoom := generateRoom(rng, dungeonWidth, dungeonHeight, maxRoomWidth, maxRoomHeight, minRoomWidth, minRoomHeight, startx, starty)
 			//Engine.Logger.Info("Starting to make a room")
 			//checks if intersecting with all rooms
 			//intersecting := true
@@ -85,7 +85,7 @@ func generateAllDungeons() {
 				if !roomIntersects(tempRoom, currentRoom) || len(rooms) == 0 {
 					break
 				} else {
-					tempRoom = generateRoom(dungeonWidth, dungeonHeight, maxRoomWidth, maxRoomHeight, minRoomWidth, minRoomHeight, startx, starty)
+					tempRoom = generateRoom(rng, dungeonWidth, dungeonHeight, maxRoomWidth, maxRoomHeight, minRoomWidth, minRoomHeight, startx, starty)
 					continue
 				}
 			}
@@ -100,21 +100,21 @@ func generateAllDungeons() {
 		}
 
 		// Places dungeon in world
-		generateDungeon(Dungeon{rooms, corridors})
+		generateDungeon(world, Dungeon{rooms, corridors})
 	}
 }
 
-func generateDungeon(dungeon Dungeon) {
+func generateDungeon(world *WorldTree, dungeon Dungeon) {
 	// Places rooms
 	for _, room := range dungeon.rooms {
 		for x := room.x; x < room.x+room.w; x++ {
 			for y := room.y; y < room.y+room.h; y++ {
 				if x == room.x || x == room.x+room.w-1 || y == room.y || y == room.y+room.h-1 {
-					WorldMap.RemoveWorldBlock(x, y)
-					createWorldBlock(x, y, "stoneBrick")
+					world.RemoveWorldBlock(x, y)
+					world.createWorldBlock(x, y, "stoneBrick")
 				} else {
-					WorldMap.RemoveWorldBlock(x, y)
-					createWorldBlock(x, y, "backdirt")
+					world.RemoveWorldBlock(x, y)
+					world.createWorldBlock(x, y, "backdirt")
 				}
 			}
 		}
@@ -137,16 +137,16 @@ func generateDungeon(dungeon Dungeon) {
 		//Creates Horizontal Corridors
 		for x := startx; x < endx; x++ {
 			y := corridor.points[0].y
-			WorldMap.RemoveWorldBlock(x, y-2)
-			WorldMap.RemoveWorldBlock(x, y-1)
-			WorldMap.RemoveWorldBlock(x, y)
-			WorldMap.RemoveWorldBlock(x, y+1)
-			WorldMap.RemoveWorldBlock(x, y+2)
-			createWorldBlock(x, y-2, "stoneBrick")
-			createWorldBlock(x, y-1, "backdirt")
-			createWorldBlock(x, y, "backdirt")
-			createWorldBlock(x, y+1, "backdirt")
-			createWorldBlock(x, y+2, "stoneBrick")
+			world.RemoveWorldBlock(x, y-2)
+			world.RemoveWorldBlock(x, y-1)
+			world.RemoveWorldBlock(x, y)
+			world.RemoveWorldBlock(x, y+1)
+			world.RemoveWorldBlock(x, y+2)
+			world.createWorldBlock(x, y-2, "stoneBrick")
+			world.createWorldBlock(x, y-1, "backdirt")
+			world.createWorldBlock(x, y, "backdirt")
+			world.createWorldBlock(x, y+1, "backdirt")
+			world.createWorldBlock(x, y+2, "stoneBrick")
 		}
 
 		// Makes sures looping from the lowest y to the highest y
@@ -163,21 +163,21 @@ func generateDungeon(dungeon Dungeon) {
 		// Creates Vertical Corridors
 		for y := starty; y < endy; y++ {
 			x := corridor.points[2].x
-			WorldMap.RemoveWorldBlock(x-1, y)
-			WorldMap.RemoveWorldBlock(x, y)
-			WorldMap.RemoveWorldBlock(x+1, y)
-			createWorldBlock(x-1, y, "stoneBrick")
-			createWorldBlock(x, y, "backdirt")
-			createWorldBlock(x+1, y, "stoneBrick")
+			world.RemoveWorldBlock(x-1, y)
+			world.RemoveWorldBlock(x, y)
+			world.RemoveWorldBlock(x+1, y)
+			world.createWorldBlock(x-1, y, "stoneBrick")
+			world.createWorldBlock(x, y, "backdirt")
+			world.createWorldBlock(x+1, y, "stoneBrick")
 		}
 	}
 }
 
-func generateRoom(dungeonWidth, dungeonHeight, maxRoomWidth, maxRoomHeight, minRoomWidth, minRoomHeight, startx, starty int) Room {
-	roomX := startx + rand.Intn(dungeonWidth)
-	roomY := starty - rand.Intn(dungeonHeight)
-	roomW := minRoomWidth + rand.Intn(maxRoomWidth-minRoomWidth+1)
-	roomH := minRoomHeight + rand.Intn(maxRoomHeight-minRoomHeight+1)
+func generateRoom(rng *rand.Rand, dungeonWidth, dungeonHeight, maxRoomWidth, maxRoomHeight, minRoomWidth, minRoomHeight, startx, starty int) Room {
+	roomX := startx + rng.Intn(dungeonWidth)
+	roomY := starty - rng.Intn(dungeonHeight)
+	roomW := minRoomWidth + rng.Intn(maxRoomWidth-minRoomWidth+1)
+	roomH := minRoomHeight + rng.Intn(maxRoomHeight-minRoomHeight+1)
 	return Room{roomX, roomY, roomW, roomH}
 }
 
@@ -1,3 +1,6 @@
+//go:build !gen
+// +build !gen
+
 package main
 
 import (
@@ -7,6 +10,37 @@ import (
 
 type EnemyManager struct {
 	AllEnemies map[int]Enemy
+	nextID     int
+}
+
+// EnemyState is the part of an enemy kept in a save
+type EnemyState struct {
+	Type string
+
+	X  float32
+	Y  float32
+	VX float32
+	VY float32
+
+	Health    float32
+	MaxHealth float32
+
+	// AI state
+	State         string
+	Direction     int32
+	TargetX       float32
+	TargetY       float32
+	AttackTimeout float64
+	NumJumps      int32
+	VXMult        float32
+	VYMult        float32
+}
+
+// enemyTypes creates an enemy of each type at a position, for loading saves
+var enemyTypes = map[string]func(em *EnemyManager, x, y float32) Enemy{
+	"goblin": func(em *EnemyManager, x, y float32) Enemy {
+		return em.newGoblinAt(x, y)
+	},
 }
 
 func InitializeEnemyManager() *EnemyManager {
@@ -23,6 +57,7 @@ func (em *EnemyManager) Update() {
 	for i, enemy := range em.AllEnemies {
 		if enemy.Activator().IsActive() {
 			enemy.Update()
+			burnEnemy(enemy, Engine.Renderer.DeltaFrameTime)
 		}
 		if enemy.GetCommon().Health <= 0 {
 			enemy.GetCommon().Kill()
@@ -52,7 +87,63 @@ func (em *EnemyManager) CheckPlayerCollision() Enemy {
 	return nil
 }
 
+// CheckPlayerCollisions is CheckPlayerCollision, returning every
+// enemy in the player's AttackBox instead of the first
+func (em *EnemyManager) CheckPlayerCollisions() []Enemy {
+	var hit []Enemy
+	for _, enemy := range em.AllEnemies {
+		pdist := Distance(
+			Player1.CenterX, Player1.CenterY,
+			enemy.GetChild().X, enemy.GetChild().Y,
+		)
+
+		if pdist > 200+Player1.AttackBox.Width {
+			continue
+		}
+
+		if Player1.CheckEnemyCollision(enemy) {
+			hit = append(hit, enemy)
+		}
+	}
+
+	return hit
+}
+
 func (em *EnemyManager) NewGoblin(radius float32) {
+	if x, y, ok := spawnPosition(radius); ok {
+		em.AddEnemy(em.newGoblinAt(x, y))
+	}
+}
+
+// SpawnEnemy spawns an enemy from the spawn table of the biome
+// just off screen, on a random side of the player
+func (em *EnemyManager) SpawnEnemy(radius float32) {
+	x, y, ok := spawnPosition(radius)
+	if !ok {
+		return
+	}
+
+	enemyType := getBiome(int(x) / BlockSize).pickEnemy()
+	if create, ok := enemyTypes[enemyType]; ok {
+		em.AddEnemy(create(em, x, y))
+	}
+}
+
+// spawnPosition picks a spot on the ground just off screen,
+// returning false when it is outside of the world
+func spawnPosition(radius float32) (float32, float32, bool) {
+	screenSide := (rand.Intn(2) * 2) - 1
+
+	x := Player1.PlayerChild.X + float32(screenSide)*((float32(ScreenWidth/2)+100)+radius)
+	if x < 0 || int(x)/BlockSize >= WorldWidth {
+		return 0, 0, false
+	}
+	y := float32(HeightMap[int(x)/BlockSize]*BlockSize) + 50
+
+	return x, y, true
+}
+
+func (em *EnemyManager) newGoblinAt(x, y float32) *Goblin {
 	mat := NewGoblinMaterial()
 
 	goblinChild := Engine.ChildControl.NewChild2D()
@@ -60,10 +151,8 @@ func (em *EnemyManager) NewGoblin(radius float32) {
 	goblinChild.ScaleX = 300
 	goblinChild.ScaleY = 300
 
-	screenSide := (rand.Intn(2) * 2) - 1
-
-	goblinChild.X = Player1.PlayerChild.X + float32(screenSide)*((float32(ScreenWidth/2)+100)+radius)
-	goblinChild.Y = float32(HeightMap[int(goblinChild.X)/BlockSize]*BlockSize) + 50
+	goblinChild.X = x
+	goblinChild.Y = y
 
 	var g = Goblin{
 		common: &Common{
@@ -114,12 +203,90 @@ func (em *EnemyManager) NewGoblin(radius float32) {
 	V.AddBox(&g.common.Hitbox1)
 	V.AddAABB(&g.common.aHitbox)
 
-	em.AllEnemies[len(em.AllEnemies)-1] = &g
+	return &g
+}
+
+func (em *EnemyManager) AddEnemy(enemy Enemy) {
+	em.AllEnemies[em.nextID] = enemy
+	em.nextID++
+}
+
+// Clear removes every enemy, for when a different world is loaded
+func (em *EnemyManager) Clear() {
+	em.AllEnemies = make(map[int]Enemy)
+}
+
+//  --------------------------------------------------
+//  Saving
+//  --------------------------------------------------
+
+func (em *EnemyManager) SaveState() []EnemyState {
+	var states []EnemyState
+	for _, enemy := range em.AllEnemies {
+		c := enemy.GetCommon()
+		if c.Dead {
+			continue
+		}
+		states = append(states, EnemyState{
+			Type: enemy.Type(),
+
+			X:  c.MonsterChild.X,
+			Y:  c.MonsterChild.Y,
+			VX: c.MonsterChild.VX,
+			VY: c.MonsterChild.VY,
+
+			Health:    c.Health,
+			MaxHealth: c.MaxHealth,
+
+			State:         c.State,
+			Direction:     int32(c.Direction),
+			TargetX:       c.TargetX,
+			TargetY:       c.TargetY,
+			AttackTimeout: c.AttackTimeout,
+			NumJumps:      int32(c.NumJumps),
+			VXMult:        c.VXMult,
+			VYMult:        c.VYMult,
+		})
+	}
+	return states
+}
+
+// LoadState replaces every enemy with the ones from a save
+func (em *EnemyManager) LoadState(states []EnemyState) {
+	em.Clear()
+
+	for _, state := range states {
+		create, ok := enemyTypes[state.Type]
+		if !ok {
+			Engine.Logger.Info("Skipping saved enemy of unknown type " + state.Type)
+			continue
+		}
+
+		enemy := create(em, state.X, state.Y)
+
+		c := enemy.GetCommon()
+		c.MonsterChild.VX = state.VX
+		c.MonsterChild.VY = state.VY
+		c.Health = state.Health
+		c.MaxHealth = state.MaxHealth
+		c.State = state.State
+		c.Direction = int(state.Direction)
+		c.TargetX = state.TargetX
+		c.TargetY = state.TargetY
+		c.AttackTimeout = state.AttackTimeout
+		c.NumJumps = int(state.NumJumps)
+		c.VXMult = state.VXMult
+		c.VYMult = state.VYMult
+
+		em.AddEnemy(enemy)
+	}
 }
 
 type Enemy interface {
 	Update()
 
+	Type() string
+
 	Damage(amount float32)
 
 	GetChild() *child.Child2D
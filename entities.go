@@ -0,0 +1,247 @@
+//go:build !gen
+// +build !gen
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+//  --------------------------------------------------
+//  Entities.go contains the player, enemies, dropped
+//  items and falling blocks a world file holds next to
+//  the world itself, in its PLYR, ENTS, ITEM and FALL
+//  sections (see worldfile.go). hellion-gen builds without them,
+//  since generated worlds have none yet.
+//  --------------------------------------------------
+
+// worldEntities are the player, enemies and dropped items of a save
+type worldEntities struct {
+	// nil if there is no player, or the save didn't have one
+	player *PlayerState
+
+	// nil if there are no enemies, or the save didn't have them
+	enemies []EnemyState
+
+	items []DroppedItem
+
+	// Blocks that had left the world but not landed yet
+	falling []FallingBlock
+}
+
+// copyEntities copies the player, enemies, dropped items and falling blocks out of the game
+func copyEntities() worldEntities {
+	var e worldEntities
+	if Player1.PlayerChild != nil {
+		state := Player1.SaveState()
+		e.player = &state
+	}
+	if EM != nil {
+		e.enemies = EM.SaveState()
+	}
+	e.items = saveDroppedItems()
+	e.falling = saveFallingBlocks()
+	return e
+}
+
+// encode writes the sections of the entities
+func (e *worldEntities) encode(w io.Writer) error {
+	var payload bytes.Buffer
+
+	if e.player != nil {
+		encodePlayer(&payload, *e.player)
+		if err := writeSection(w, sectionPlayer, payload.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	if e.enemies != nil {
+		payload.Reset()
+		encodeEnemies(&payload, e.enemies)
+		if err := writeSection(w, sectionEnemies, payload.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	payload.Reset()
+	encodeItems(&payload, e.items)
+	if err := writeSection(w, sectionItems, payload.Bytes()); err != nil {
+		return err
+	}
+
+	payload.Reset()
+	encodeFalling(&payload, e.falling)
+	return writeSection(w, sectionFalling, payload.Bytes())
+}
+
+// decodeSection reads a section of a world file written in the
+// given format version into the entities, if it is one of theirs
+func (e *worldEntities) decodeSection(tag [4]byte, payload []byte, version uint16) error {
+	switch tag {
+	case sectionPlayer:
+		state, err := decodePlayer(payload, version)
+		if err != nil {
+			return fmt.Errorf("player section: %v", err)
+		}
+		e.player = &state
+	case sectionEnemies:
+		states, err := decodeEnemies(payload)
+		if err != nil {
+			return fmt.Errorf("enemies section: %v", err)
+		}
+		e.enemies = states
+	case sectionItems:
+		items, err := decodeItems(payload)
+		if err != nil {
+			return fmt.Errorf("items section: %v", err)
+		}
+		e.items = items
+	case sectionFalling:
+		falling, err := decodeFalling(payload)
+		if err != nil {
+			return fmt.Errorf("falling blocks section: %v", err)
+		}
+		e.falling = falling
+	}
+	return nil
+}
+
+//  --------------------------------------------------
+//  Sections
+//  --------------------------------------------------
+
+func encodePlayer(buf *bytes.Buffer, state PlayerState) {
+	w := fieldWriter{buf}
+	w.write(state.X, state.Y, state.VX, state.VY)
+	w.write(state.Health, state.MaxHealth, state.Money, state.Dead)
+	w.write(uint16(len(state.Inventory)))
+	for _, stack := range state.Inventory {
+		w.writeString(stack.Name)
+		w.write(stack.Count, stack.Meta)
+	}
+	w.write(uint16(len(state.Equipment)))
+	for _, stack := range state.Equipment {
+		w.writeString(stack.Name)
+		w.write(stack.Count, stack.Meta)
+	}
+}
+
+// decodePlayer reads the player section of a world file written in
+// the given format version. Players from before version 5 only had
+// a hotbar of block names, which are given to them as full stacks,
+// and players from before version 6 wear nothing.
+func decodePlayer(payload []byte, version uint16) (PlayerState, error) {
+	var state PlayerState
+	r := fieldReader{r: bytes.NewReader(payload)}
+	r.read(&state.X, &state.Y, &state.VX, &state.VY)
+	r.read(&state.Health, &state.MaxHealth, &state.Money, &state.Dead)
+
+	var slots uint16
+	r.read(&slots)
+	for i := 0; i < int(slots) && r.err == nil; i++ {
+		var stack ItemStack
+		stack.Name = r.readString()
+		if version >= 5 {
+			r.read(&stack.Count, &stack.Meta)
+		} else if stack.Name != "" {
+			stack.Count = maxStack(stack.Name)
+		}
+		state.Inventory = append(state.Inventory, stack)
+	}
+
+	if version >= 6 {
+		r.read(&slots)
+		for i := 0; i < int(slots) && r.err == nil; i++ {
+			var stack ItemStack
+			stack.Name = r.readString()
+			r.read(&stack.Count, &stack.Meta)
+			state.Equipment = append(state.Equipment, stack)
+		}
+	}
+	return state, r.err
+}
+
+func encodeEnemies(buf *bytes.Buffer, states []EnemyState) {
+	w := fieldWriter{buf}
+	w.write(uint32(len(states)))
+	for _, state := range states {
+		w.writeString(state.Type)
+		w.write(state.X, state.Y, state.VX, state.VY)
+		w.write(state.Health, state.MaxHealth)
+		w.writeString(state.State)
+		w.write(state.Direction, state.TargetX, state.TargetY)
+		w.write(state.AttackTimeout, state.NumJumps, state.VXMult, state.VYMult)
+	}
+}
+
+func decodeEnemies(payload []byte) ([]EnemyState, error) {
+	r := fieldReader{r: bytes.NewReader(payload)}
+
+	var count uint32
+	r.read(&count)
+
+	var states []EnemyState
+	for i := 0; i < int(count) && r.err == nil; i++ {
+		var state EnemyState
+		state.Type = r.readString()
+		r.read(&state.X, &state.Y, &state.VX, &state.VY)
+		r.read(&state.Health, &state.MaxHealth)
+		state.State = r.readString()
+		r.read(&state.Direction, &state.TargetX, &state.TargetY)
+		r.read(&state.AttackTimeout, &state.NumJumps, &state.VXMult, &state.VYMult)
+		states = append(states, state)
+	}
+	return states, r.err
+}
+
+func encodeItems(buf *bytes.Buffer, items []DroppedItem) {
+	w := fieldWriter{buf}
+	w.write(uint32(len(items)))
+	for _, item := range items {
+		w.writeString(item.Name)
+		w.write(item.Count, item.X, item.Y, item.VX, item.VY, item.Age)
+	}
+}
+
+func decodeItems(payload []byte) ([]DroppedItem, error) {
+	r := fieldReader{r: bytes.NewReader(payload)}
+
+	var count uint32
+	r.read(&count)
+
+	var items []DroppedItem
+	for i := 0; i < int(count) && r.err == nil; i++ {
+		var item DroppedItem
+		item.Name = r.readString()
+		r.read(&item.Count, &item.X, &item.Y, &item.VX, &item.VY, &item.Age)
+		items = append(items, item)
+	}
+	return items, r.err
+}
+
+func encodeFalling(buf *bytes.Buffer, blocks []FallingBlock) {
+	w := fieldWriter{buf}
+	w.write(uint32(len(blocks)))
+	for _, block := range blocks {
+		w.writeString(block.Name)
+		w.write(block.X, block.Y, block.VY, block.Hurt)
+	}
+}
+
+func decodeFalling(payload []byte) ([]FallingBlock, error) {
+	r := fieldReader{r: bytes.NewReader(payload)}
+
+	var count uint32
+	r.read(&count)
+
+	var blocks []FallingBlock
+	for i := 0; i < int(count) && r.err == nil; i++ {
+		var block FallingBlock
+		block.Name = r.readString()
+		r.read(&block.X, &block.Y, &block.VY, &block.Hurt)
+		blocks = append(blocks, block)
+	}
+	return blocks, r.err
+}
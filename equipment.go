@@ -0,0 +1,80 @@
+//go:build !gen
+// +build !gen
+
+package main
+
+import "path/filepath"
+
+//  --------------------------------------------------
+//  Equipment.go contains armor and accessories, the
+//  items the player wears.
+//
+//  Every piece of armor goes in one kind of equipment
+//  slot, and accessories go in either accessory slot.
+//  What the player wears adds up to their Stats, which
+//  are worked out again every frame and replace their
+//  max health, speed, jump and damage. Defense takes
+//  that much off every hit, down to MinHitDamage.
+//  --------------------------------------------------
+
+// Armor textures are relative to this directory
+const ArmorTextureDir = "./assets/armor"
+
+// Player stats without any equipment
+const BaseMaxHealth = 100
+const BasePunchDamage = 10
+
+// Hits always do at least this much damage, however much defense the player has
+const MinHitDamage = 1
+
+func loadArmor() {
+	for name, armor := range Armors {
+		Engine.TextureControl.NewTexture(filepath.Join(ArmorTextureDir, name+".png"), name, "pixel")
+		armor.Icon = Engine.MaterialControl.NewBasicMaterial()
+		armor.Icon.DiffuseLevel = 1
+		armor.Icon.DiffuseMap = Engine.TextureControl.GetTexture(name)
+	}
+}
+
+// equipmentStats adds up the stats of everything in an equipment inventory
+func equipmentStats(equipment *Inventory) Stats {
+	var stats Stats
+	for _, stack := range equipment.Slots {
+		if armor := GetArmor(stack.Name); armor != nil && !stack.Empty() {
+			stats = stats.Add(armor.Stats)
+		}
+	}
+	return stats
+}
+
+// updateStats applies the stats of the player's equipment
+func (p *Player) updateStats() {
+	p.Stats = equipmentStats(p.Equipment)
+
+	p.MaxHealth = BaseMaxHealth + p.Stats.MaxHealth
+	if p.Health > p.MaxHealth {
+		p.Health = p.MaxHealth
+	}
+
+	p.SpeedX = BaseSpeedX * (1 + p.Stats.Speed)
+	p.SpeedY = BaseSpeedY * (1 + p.Stats.Jump)
+	if p.God {
+		p.SpeedX = 600
+	}
+
+	p.PunchDamage = BasePunchDamage * (1 + p.Stats.Damage)
+}
+
+// damageDealt returns the damage of an attack after the player's damage bonus
+func (p *Player) damageDealt(damage float32) float32 {
+	return damage * (1 + p.Stats.Damage)
+}
+
+// damageTaken returns the damage of a hit after the player's defense
+func (p *Player) damageTaken(damage float32) float32 {
+	damage -= p.Stats.Defense
+	if damage < MinHitDamage {
+		damage = MinHitDamage
+	}
+	return damage
+}
@@ -0,0 +1,169 @@
+//go:build !gen
+// +build !gen
+
+package main
+
+import (
+	"rapidengine/child"
+	"rapidengine/cmd"
+)
+
+//  --------------------------------------------------
+//  Falling.go contains falling blocks.
+//
+//  Blocks with Falls set, like sand and gravel, stay
+//  put until the block under them is gone. Then they
+//  leave the world and drop as a FallingBlock, pulled
+//  down by BaseGravity, until they land and are placed
+//  back into the world with placeBlock, or dropped as
+//  an item if there is no room for them. Whoever they
+//  fall on gets hurt. Blocks still falling are saved
+//  with the world (see entities.go).
+//  --------------------------------------------------
+
+// FallingBlock is a block dropping through the air
+type FallingBlock struct {
+	Name string
+
+	// Position in pixels, X never changes
+	X  float32
+	Y  float32
+	VY float32
+
+	// Set once it has hurt something, so it only does so once
+	Hurt bool
+}
+
+// FallingBlocks are all the blocks currently falling
+var FallingBlocks []*FallingBlock
+
+// Damage dealt to the player or an enemy a falling block falls on
+const FallingBlockDamage = 15
+
+// Falling blocks never drop faster than this, in pixels per second
+const MaxFallSpeed = 1500
+
+// dropUnsupportedBlocks starts the column of falling blocks
+// from a position up falling, if there is nothing under it
+func dropUnsupportedBlocks(x, y int) {
+	for y > 0 && isInWorld(x, y) && GetBlock(WorldMap.GetWorldBlockName(x, y)).Falls && WorldMap.GetWorldBlockID(x, y-1) == BlockEmpty {
+		name := WorldMap.GetWorldBlockName(x, y)
+
+		WorldMap.RemoveWorldBlock(x, y)
+		WorldMap.RemoveGrassBlock(x, y)
+
+		WorldMap.FixLightingAt(x, y)
+		WorldMap.WakeLiquids(x, y)
+		fixBlock(x+1, y)
+		fixBlock(x-1, y)
+
+		FallingBlocks = append(FallingBlocks, &FallingBlock{
+			Name: name,
+			X:    float32(x * BlockSize),
+			Y:    float32(y * BlockSize),
+		})
+		y++
+	}
+}
+
+// updateFallingBlocks moves every falling block, landing the ones that hit something
+func updateFallingBlocks(dt float32) {
+	falling := FallingBlocks[:0]
+	for _, block := range FallingBlocks {
+		if !block.update(dt) {
+			falling = append(falling, block)
+		}
+	}
+	FallingBlocks = falling
+}
+
+// update moves a falling block, returning true once it has landed
+func (block *FallingBlock) update(dt float32) bool {
+	block.VY -= BaseGravity * dt
+	if block.VY < -MaxFallSpeed {
+		block.VY = -MaxFallSpeed
+	}
+
+	x := int(block.X / BlockSize)
+	from := int(block.Y / BlockSize)
+	block.Y += block.VY * dt
+
+	// Check every tile passed this frame, so nothing is skipped over
+	for y := from; y >= int(block.Y/BlockSize); y-- {
+		if y <= 0 || WorldMap.GetWorldBlockID(x, y-1) != BlockEmpty {
+			if float32(y*BlockSize) >= block.Y {
+				block.land(x, y)
+				return true
+			}
+		}
+	}
+
+	// Hurt whoever is under it, once
+	if block.Hurt {
+		return false
+	}
+	box := AABB{X: block.X, Y: block.Y, Width: BlockSize, Height: BlockSize}
+	if Player1.PlayerChild != nil && box.CheckCollision(hitboxBounds(Player1.Hitbox1), 0, 0) {
+		Player1.Hit(FallingBlockDamage)
+		block.Hurt = true
+	}
+	if EM != nil {
+		for _, enemy := range EM.AllEnemies {
+			if box.CheckCollision(hitboxBounds(enemy.GetCommon().Hitbox1), 0, 0) {
+				hitEnemy(enemy, FallingBlockDamage, block.X+BlockSize/2, 0, false)
+				block.Hurt = true
+			}
+		}
+	}
+	return false
+}
+
+// land puts a falling block back into the world, on top of anything
+// that landed in the same spot first. A block that can't be placed
+// there drops what mining it would have instead.
+func (block *FallingBlock) land(x, y int) {
+	for WorldMap.GetWorldBlockID(x, y) != BlockEmpty && y < WorldHeight-1 {
+		y++
+	}
+	if !placeBlock(x, y, block.Name) {
+		dropBlockItems(x, y, block.Name)
+	}
+}
+
+// saveFallingBlocks copies every falling block, for saving
+func saveFallingBlocks() []FallingBlock {
+	blocks := make([]FallingBlock, 0, len(FallingBlocks))
+	for _, block := range FallingBlocks {
+		blocks = append(blocks, *block)
+	}
+	return blocks
+}
+
+// loadFallingBlocks replaces every falling block with the ones from a save
+func loadFallingBlocks(blocks []FallingBlock) {
+	FallingBlocks = nil
+	for i := range blocks {
+		block := blocks[i]
+		if GetBlock(block.Name) == nil {
+			logInfo("Skipping unknown falling block " + block.Name)
+			continue
+		}
+		FallingBlocks = append(FallingBlocks, &block)
+	}
+}
+
+// hitboxBounds returns the box around a hitbox, in pixels
+func hitboxBounds(hb Hitbox) AABB {
+	return AABB{X: hb.X, Y: hb.Y, Width: hb.DAABB.Width, Height: hb.LAABB.Height}
+}
+
+func renderFallingBlocks(renderer *cmd.Renderer) {
+	for _, block := range FallingBlocks {
+		renderer.RenderCopy(NoCollisionChild, child.ChildCopy{
+			X:        block.X,
+			Y:        block.Y,
+			Material: GetBlock(block.Name).GetMaterial(OrientNN),
+			Darkness: WorldMap.GetDarkness(int(block.X/BlockSize), int(block.Y/BlockSize)),
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+//  --------------------------------------------------
+//  Genpass.go contains the world generation pipeline.
+//
+//  World generation is a list of named passes run in
+//  order. Each pass gets its own random stream derived
+//  from the seed and its name (see passRand), so adding,
+//  removing or reordering passes doesn't change what
+//  the other passes generate. Progress is reported from
+//  the weights of the passes, which should roughly
+//  match how long each one takes.
+//
+//  Extra passes, like crystals or ruins, are added to
+//  WorldGenPasses before a world is generated:
+//    WorldGenPasses.InsertAfter("caves", NewGenPass("crystals", 1, generateCrystals))
+//  --------------------------------------------------
+
+// GenPass is one step of world generation
+type GenPass interface {
+	Name() string
+
+	// Relative amount of time the pass takes, for progress
+	Weight() float32
+
+	Run(ctx *GenContext, world *WorldTree, rng *rand.Rand)
+}
+
+// GenContext is shared by every pass of one world generation
+type GenContext struct {
+	Seed int64
+
+	// Size of the world being generated
+	Width  int
+	Height int
+}
+
+// NewGenPass creates a pass from a function
+func NewGenPass(name string, weight float32, run func(ctx *GenContext, world *WorldTree, rng *rand.Rand)) GenPass {
+	return &funcPass{name, weight, run}
+}
+
+type funcPass struct {
+	name   string
+	weight float32
+	run    func(ctx *GenContext, world *WorldTree, rng *rand.Rand)
+}
+
+func (p *funcPass) Name() string {
+	return p.name
+}
+
+func (p *funcPass) Weight() float32 {
+	return p.weight
+}
+
+func (p *funcPass) Run(ctx *GenContext, world *WorldTree, rng *rand.Rand) {
+	p.run(ctx, world, rng)
+}
+
+//  --------------------------------------------------
+//  Pipeline
+//  --------------------------------------------------
+
+// GenPipeline is an ordered list of passes, any of which can be disabled
+type GenPipeline struct {
+	passes   []GenPass
+	disabled map[string]bool
+}
+
+// NewGenPipeline creates a pipeline running the given passes in order
+func NewGenPipeline(passes ...GenPass) *GenPipeline {
+	return &GenPipeline{
+		passes:   passes,
+		disabled: make(map[string]bool),
+	}
+}
+
+// Passes returns every pass in order, including disabled ones
+func (p *GenPipeline) Passes() []GenPass {
+	return append([]GenPass(nil), p.passes...)
+}
+
+// Add puts a pass at the end of the pipeline
+func (p *GenPipeline) Add(pass GenPass) error {
+	if p.index(pass.Name()) >= 0 {
+		return fmt.Errorf("generation pass %q already exists", pass.Name())
+	}
+	p.passes = append(p.passes, pass)
+	return nil
+}
+
+// InsertBefore puts a pass right before the named one
+func (p *GenPipeline) InsertBefore(name string, pass GenPass) error {
+	return p.insert(name, 0, pass)
+}
+
+// InsertAfter puts a pass right after the named one
+func (p *GenPipeline) InsertAfter(name string, pass GenPass) error {
+	return p.insert(name, 1, pass)
+}
+
+func (p *GenPipeline) insert(name string, offset int, pass GenPass) error {
+	if p.index(pass.Name()) >= 0 {
+		return fmt.Errorf("generation pass %q already exists", pass.Name())
+	}
+	i := p.index(name)
+	if i < 0 {
+		return fmt.Errorf("no generation pass named %q", name)
+	}
+	i += offset
+
+	p.passes = append(p.passes, nil)
+	copy(p.passes[i+1:], p.passes[i:])
+	p.passes[i] = pass
+	return nil
+}
+
+// Remove takes a pass out of the pipeline
+func (p *GenPipeline) Remove(name string) {
+	if i := p.index(name); i >= 0 {
+		p.passes = append(p.passes[:i], p.passes[i+1:]...)
+	}
+}
+
+// Enable turns a disabled pass back on
+func (p *GenPipeline) Enable(name string) {
+	delete(p.disabled, name)
+}
+
+// Disable skips a pass without removing it
+func (p *GenPipeline) Disable(name string) error {
+	if p.index(name) < 0 {
+		return fmt.Errorf("no generation pass named %q", name)
+	}
+	p.disabled[name] = true
+	return nil
+}
+
+// IsEnabled returns whether a pass is in the pipeline and not disabled
+func (p *GenPipeline) IsEnabled(name string) bool {
+	return p.index(name) >= 0 && !p.disabled[name]
+}
+
+// Run runs every enabled pass in order, reporting
+// the progress before each one from 0 to 100
+func (p *GenPipeline) Run(ctx *GenContext, world *WorldTree, progress func(text string, percent float32)) {
+	total := float32(0)
+	for _, pass := range p.passes {
+		if !p.disabled[pass.Name()] {
+			total += pass.Weight()
+		}
+	}
+
+	done := float32(0)
+	for _, pass := range p.passes {
+		if p.disabled[pass.Name()] {
+			continue
+		}
+
+		percent := float32(0)
+		if total > 0 {
+			percent = 100 * done / total
+		}
+		progress(fmt.Sprintf("Generating %s...", pass.Name()), percent)
+
+		pass.Run(ctx, world, passRand(pass.Name()))
+		done += pass.Weight()
+	}
+}
+
+func (p *GenPipeline) index(name string) int {
+	for i, pass := range p.passes {
+		if pass.Name() == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -1,25 +1,15 @@
 package main
 
-import (
-	"rapidengine/child"
-	"rapidengine/cmd"
-	"rapidengine/configuration"
-	"rapidengine/input"
-	"rapidengine/lighting"
-	"rapidengine/material"
-)
+import "time"
 
 //  --------------------------------------------------
 //  Globals.go contains all the global variables in the project.
 //  This is not good practice.
+//
+//  The ones holding engine objects are in globals_game.go,
+//  since hellion-gen builds without the engine.
 //  --------------------------------------------------
 
-// Rapid Engine
-var Engine *cmd.Engine
-var Config configuration.EngineConfig
-
-var Inputs *input.Input
-
 // Screen Size
 var ScreenWidth = 1920
 var ScreenHeight = 1080
@@ -27,6 +17,13 @@ var ScreenHeight = 1080
 // Mouse Settings
 var MouseSensitivity = 9.0
 
+// Time between autosaves, 0 turns autosaving off (see settings.go)
+var AutosaveInterval = 5 * time.Minute
+
+// Autosaves only back up the previous save if the newest backup
+// is older than this, saves from the menu always do (see settings.go)
+var AutosaveBackupInterval = time.Hour
+
 //  --------------------------------------------------
 //  Children
 //  --------------------------------------------------
@@ -35,26 +32,6 @@ var BaseGravity = float32(1710.0)
 var BaseSpeedX = float32(150.0)
 var BaseSpeedY = float32(600.0)
 
-var BlockSelect *child.Child2D
-
-// World
-var WorldChild *child.Child2D
-var SkyChild *child.Child2D
-var NoCollisionChild *child.Child2D
-var NatureChild *child.Child2D
-var GrassChild *child.Child2D
-var CloudChild *child.Child2D
-var SunChild *child.Child2D
-
-var Back1Child *child.Child2D
-var Back2Child *child.Child2D
-var Back3Child *child.Child2D
-var Back4Child *child.Child2D
-var Back5Child *child.Child2D
-var Back6Child *child.Child2D
-
-var l lighting.PointLight
-
 //  --------------------------------------------------
 //  General
 //  --------------------------------------------------
@@ -69,14 +46,17 @@ var CurrentWorld int
 
 var Seed = int64(0)
 
-// Size
-const WorldWidth = 3000
-const WorldHeight = 2000
+// Size of the current world in blocks, see SetWorldSize
+var WorldWidth = DefaultWorldSize.Width
+var WorldHeight = DefaultWorldSize.Height
+
 const BlockSize = 32
 
-// Height
+// Height of the plains, other biomes set their own (see biome.go)
 const Flatness = 0.25
-const GrassMinimum = 1500
+
+// Height of the lowest grass, as a fraction of the world height
+const GrassMinimum = 0.75
 
 // Cave generation
 const CaveStartingThreshold = 0.27
@@ -99,35 +79,11 @@ const StoneTopDeviation = 10
 
 // Data
 var WorldMap WorldTree
-var HeightMap [WorldWidth]int
+var HeightMap = make([]int, WorldWidth)
 var CaveMap [][]bool
 
-//  --------------------------------------------------
-//  Scenes
-//  --------------------------------------------------
-
-var TitleScene *cmd.Scene
-var ChooseScene *cmd.Scene
-var LoadingScene *cmd.Scene
-var WorldScene *cmd.Scene
-var MenuScene *cmd.Scene
-var SaveScene *cmd.Scene
-var HotbarScene *cmd.Scene
-var InventoryScene *cmd.Scene
-var RespawnScene *cmd.Scene
-
-var EM *EnemyManager
-
-//  --------------------------------------------------
-//  Theme Materials
-//  --------------------------------------------------
-
-var ButtonMaterial *material.BasicMaterial
-
 //  --------------------------------------------------
 //  Data
 //  --------------------------------------------------
 
-var TransparentBlocks = []string{"backdirt", "torch"} //"topGrass1", "topGrass2", "topGrass3", "treeRightRoot", "treeLeftRoot", "treeTrunk", "treeBottomRoot", "treeBranchR1", "treeBranchL1", "flower1", "flower2", "flower3", "pebble"}
 var natureBlocks = []string{"leaves", "treeRightRoot", "treeLeftRoot", "treeTrunk", "treeBottomRoot", "treeBranchR1", "treeBranchL1", "topGrass1", "topGrass2", "topGrass3", "flower1", "flower2", "flower3", "pebble"}
-var cloudMaterial *material.BasicMaterial
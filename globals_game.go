@@ -0,0 +1,78 @@
+//go:build !gen
+// +build !gen
+
+package main
+
+import (
+	"rapidengine/child"
+	"rapidengine/cmd"
+	"rapidengine/configuration"
+	"rapidengine/input"
+	"rapidengine/lighting"
+	"rapidengine/material"
+)
+
+//  --------------------------------------------------
+//  Globals_game.go contains the global variables that
+//  hold engine objects. hellion-gen builds without the
+//  engine, and without this file.
+//  --------------------------------------------------
+
+// Rapid Engine
+var Engine *cmd.Engine
+var Config configuration.EngineConfig
+
+var Inputs *input.Input
+
+//  --------------------------------------------------
+//  Children
+//  --------------------------------------------------
+
+var BlockSelect *child.Child2D
+
+// World
+var WorldChild *child.Child2D
+var SkyChild *child.Child2D
+var NoCollisionChild *child.Child2D
+var NatureChild *child.Child2D
+var GrassChild *child.Child2D
+var CloudChild *child.Child2D
+var SunChild *child.Child2D
+
+var Back1Child *child.Child2D
+var Back2Child *child.Child2D
+var Back3Child *child.Child2D
+var Back4Child *child.Child2D
+var Back5Child *child.Child2D
+var Back6Child *child.Child2D
+
+var l lighting.PointLight
+
+//  --------------------------------------------------
+//  Scenes
+//  --------------------------------------------------
+
+var TitleScene *cmd.Scene
+var ChooseScene *cmd.Scene
+var LoadingScene *cmd.Scene
+var WorldScene *cmd.Scene
+var MenuScene *cmd.Scene
+var SaveScene *cmd.Scene
+var HotbarScene *cmd.Scene
+var InventoryScene *cmd.Scene
+var RespawnScene *cmd.Scene
+var RestoreScene *cmd.Scene
+
+var EM *EnemyManager
+
+//  --------------------------------------------------
+//  Theme Materials
+//  --------------------------------------------------
+
+var ButtonMaterial *material.BasicMaterial
+
+//  --------------------------------------------------
+//  Data
+//  --------------------------------------------------
+
+var cloudMaterial *material.BasicMaterial
@@ -1,3 +1,6 @@
+//go:build !gen
+// +build !gen
+
 package main
 
 //"rapidengine/child"
@@ -20,6 +23,10 @@ func (g *Goblin) Update() {
 	Engine.Renderer.RenderChild(g.common.MonsterChild)
 }
 
+func (g *Goblin) Type() string {
+	return "goblin"
+}
+
 func (g *Goblin) GetChild() *child.Child2D {
 	return g.common.MonsterChild
 }
@@ -0,0 +1,133 @@
+//go:build gen
+// +build gen
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+//  --------------------------------------------------
+//  Hellion_gen.go is the hellion-gen command, which
+//  runs world generation without opening a window and
+//  writes a save plus a PNG preview of the world.
+//
+//  Build it in place of the game with the gen tag:
+//    go build -tags gen -o hellion-gen
+//
+//  hellion-gen -seed 42 -size large -out worlds/42.hln
+//
+//  The gen tag leaves out every file that uses the
+//  engine, so hellion-gen doesn't need a window, GL or
+//  GLFW. The few engine names the world code refers to
+//  are defined at the bottom of this file instead.
+//  --------------------------------------------------
+
+func main() {
+	seedFlag := flag.String("seed", "", "world seed, random when empty")
+	sizeFlag := flag.String("size", DefaultWorldSize.Name, "world size, small, medium, large or WIDTHxHEIGHT")
+	out := flag.String("out", "world.hln", "path of the save to write")
+	preview := flag.String("image", "", "path of the PNG preview, defaults to the save path with a .png extension")
+	disable := flag.String("disable", "", "comma separated generation passes to skip")
+	list := flag.Bool("list", false, "list the generation passes and exit")
+	flag.Parse()
+
+	if *list {
+		for _, pass := range WorldGenPasses.Passes() {
+			fmt.Println(pass.Name())
+		}
+		return
+	}
+
+	if *disable != "" {
+		for _, name := range strings.Split(*disable, ",") {
+			if err := WorldGenPasses.Disable(strings.TrimSpace(name)); err != nil {
+				fmt.Fprintf(os.Stderr, "hellion-gen: %v\n", err)
+				os.Exit(2)
+			}
+		}
+	}
+
+	seed := randomSeed()
+	if *seedFlag != "" {
+		var err error
+		if seed, err = strconv.ParseInt(*seedFlag, 10, 64); err != nil {
+			fmt.Fprintf(os.Stderr, "hellion-gen: invalid seed %q\n", *seedFlag)
+			os.Exit(2)
+		}
+	}
+	size, err := ParseWorldSize(*sizeFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "hellion-gen: %v\n", err)
+		os.Exit(2)
+	}
+	if *preview == "" {
+		*preview = strings.TrimSuffix(*out, ".hln") + ".png"
+	}
+
+	for _, path := range []string{*out, *preview} {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if err := loadBlocks(); err != nil {
+		log.Fatal(err)
+	}
+	WorldMap = NewWorldTree()
+
+	if err := generateWorld(seed, size, func(text string, percent float32) {}); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := WorldMap.writeToImage(*preview); err != nil {
+		log.Fatalf("writing preview: %v", err)
+	}
+	if err := WorldMap.WriteToFile(*out); err != nil {
+		log.Fatalf("writing save: %v", err)
+	}
+
+	log.Printf("Generated seed %d into %s", seed, *out)
+}
+
+//  --------------------------------------------------
+//  In Place of the Game
+//  --------------------------------------------------
+
+// renderMaterial is empty, since nothing is drawn (see render.go)
+type renderMaterial struct{}
+
+// blockCopy keeps the fields of a render copy the world uses
+type blockCopy struct {
+	X        float32
+	Y        float32
+	Material *renderMaterial
+	Darkness float32
+}
+
+// worldEntities is empty, since generated worlds have no
+// player, enemies or dropped items yet (see entities.go)
+type worldEntities struct{}
+
+func copyEntities() worldEntities {
+	return worldEntities{}
+}
+
+func (e *worldEntities) encode(w io.Writer) error {
+	return nil
+}
+
+func (e *worldEntities) decodeSection(tag [4]byte, payload []byte, version uint16) error {
+	return nil
+}
+
+func logInfo(text string) {
+	log.Println(text)
+}
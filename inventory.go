@@ -0,0 +1,241 @@
+package main
+
+//  --------------------------------------------------
+//  Inventory.go contains the Inventory, the stacks of
+//  items the player carries.
+//
+//  An inventory is a fixed number of slots, each empty
+//  or holding one ItemStack. The first NumSlots slots of
+//  the player's inventory are the hotbar. Items are
+//  blocks, tools (see mining.go), weapons (see
+//  weapons.go), wands (see magic.go) or armor (see
+//  equipment.go), and stack up to their max stack
+//  size. Stacks only merge when their metadata is the
+//  same. What the player wears is kept in a second
+//  inventory, one slot per EquipHead etc.
+//
+//  Nothing here touches the engine, so inventories can
+//  be used and tested without a renderer.
+//  --------------------------------------------------
+
+// ItemStack is a number of the same item in one slot
+type ItemStack struct {
+	Name  string
+	Count int32
+
+	// Free for the item to keep state in
+	Meta uint8
+}
+
+// Inventory is a set of slots holding item stacks
+type Inventory struct {
+	Slots []ItemStack
+}
+
+// Number of slots in the player's inventory, including the hotbar
+const InventorySize = 40
+
+// Items never stack higher than this
+const MaxStackSize = 99
+
+// Slots of the player's equipment, which is an inventory too
+const (
+	EquipHead = iota
+	EquipChest
+	EquipLegs
+	EquipAccessory1
+	EquipAccessory2
+	NumEquipSlots
+)
+
+// ItemKind is the sort of thing an item is
+type ItemKind int
+
+const (
+	ItemNone ItemKind = iota
+	ItemBlock
+	ItemTool
+	ItemWeapon
+	ItemWand
+	ItemArmor
+)
+
+// itemKind returns the sort of thing an item is, or ItemNone if it isn't one
+func itemKind(name string) ItemKind {
+	switch {
+	case GetTool(name) != nil:
+		return ItemTool
+	case GetWeapon(name) != nil:
+		return ItemWeapon
+	case GetWand(name) != nil:
+		return ItemWand
+	case GetArmor(name) != nil:
+		return ItemArmor
+	case GetBlock(name) != nil:
+		return ItemBlock
+	}
+	return ItemNone
+}
+
+// NewInventory returns an empty inventory with a number of slots
+func NewInventory(size int) *Inventory {
+	return &Inventory{Slots: make([]ItemStack, size)}
+}
+
+// maxStack returns how many of an item fit in one slot
+func maxStack(name string) int32 {
+	switch itemKind(name) {
+	case ItemTool, ItemWeapon, ItemWand, ItemArmor:
+		return 1
+	}
+	return MaxStackSize
+}
+
+// canEquip returns whether an item can be worn in an equipment slot
+func canEquip(slot int, name string) bool {
+	armor := GetArmor(name)
+	if armor == nil {
+		return false
+	}
+	for _, s := range ArmorSlots[armor.Slot] {
+		if s == slot {
+			return true
+		}
+	}
+	return false
+}
+
+// Empty returns whether the stack holds nothing
+func (stack ItemStack) Empty() bool {
+	return stack.Count <= 0 || stack.Name == ""
+}
+
+// stacksWith returns whether another stack can merge into this one
+func (stack ItemStack) stacksWith(other ItemStack) bool {
+	return stack.Name == other.Name && stack.Meta == other.Meta
+}
+
+// Add puts items into the inventory, topping up stacks of the
+// same item before using empty slots. Returns how many fit.
+func (inv *Inventory) Add(name string, count int32) int32 {
+	return inv.AddStack(ItemStack{Name: name, Count: count})
+}
+
+// AddStack is Add for a stack with metadata
+func (inv *Inventory) AddStack(stack ItemStack) int32 {
+	return inv.AddStackBetween(stack, 0, len(inv.Slots))
+}
+
+// AddStackBetween is AddStack using only the slots from
+// first up to but not including end
+func (inv *Inventory) AddStackBetween(stack ItemStack, first, end int) int32 {
+	if stack.Empty() {
+		return 0
+	}
+	max := maxStack(stack.Name)
+	left := stack.Count
+	slots := inv.Slots[first:end]
+
+	for i := range slots {
+		slot := &slots[i]
+		if left == 0 {
+			break
+		}
+		if slot.Empty() || !slot.stacksWith(stack) || slot.Count >= max {
+			continue
+		}
+		moved := max - slot.Count
+		if moved > left {
+			moved = left
+		}
+		slot.Count += moved
+		left -= moved
+	}
+
+	for i := range slots {
+		slot := &slots[i]
+		if left == 0 {
+			break
+		}
+		if !slot.Empty() {
+			continue
+		}
+		moved := max
+		if moved > left {
+			moved = left
+		}
+		*slot = ItemStack{Name: stack.Name, Count: moved, Meta: stack.Meta}
+		left -= moved
+	}
+
+	return stack.Count - left
+}
+
+// Remove takes items out of the inventory, from the last
+// slots first so the hotbar runs out last. Returns how
+// many were taken.
+func (inv *Inventory) Remove(name string, count int32) int32 {
+	taken := int32(0)
+	for i := len(inv.Slots) - 1; i >= 0 && taken < count; i-- {
+		if inv.Slots[i].Name == name {
+			taken += inv.TakeFromSlot(i, count-taken).Count
+		}
+	}
+	return taken
+}
+
+// TakeFromSlot takes up to a number of items out of a slot, returning them
+func (inv *Inventory) TakeFromSlot(slot int, count int32) ItemStack {
+	stack := &inv.Slots[slot]
+	if stack.Empty() || count <= 0 {
+		return ItemStack{}
+	}
+	if count > stack.Count {
+		count = stack.Count
+	}
+
+	taken := *stack
+	taken.Count = count
+	stack.Count -= count
+	if stack.Count <= 0 {
+		*stack = ItemStack{}
+	}
+	return taken
+}
+
+// Count returns how many of an item the inventory holds
+func (inv *Inventory) Count(name string) int32 {
+	count := int32(0)
+	for _, stack := range inv.Slots {
+		if stack.Name == name {
+			count += stack.Count
+		}
+	}
+	return count
+}
+
+// Has returns whether the inventory holds at least a number of an item
+func (inv *Inventory) Has(name string, count int32) bool {
+	return inv.Count(name) >= count
+}
+
+// Slot returns the stack in a slot, or an empty stack
+// if the slot is outside of the inventory
+func (inv *Inventory) Slot(slot int) ItemStack {
+	if slot < 0 || slot >= len(inv.Slots) {
+		return ItemStack{}
+	}
+	return inv.Slots[slot]
+}
+
+// Swap swaps the stacks in two slots
+func (inv *Inventory) Swap(a, b int) {
+	inv.Slots[a], inv.Slots[b] = inv.Slots[b], inv.Slots[a]
+}
+
+// Clear empties every slot
+func (inv *Inventory) Clear() {
+	for i := range inv.Slots {
+		inv.Slots[i] = ItemStack{}
+	}
+}
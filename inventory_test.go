@@ -0,0 +1,134 @@
+package main
+
+import "testing"
+
+func checkSlots(t *testing.T, inv *Inventory, want ...ItemStack) {
+	t.Helper()
+	for i, stack := range inv.Slots {
+		var w ItemStack
+		if i < len(want) {
+			w = want[i]
+		}
+		if stack != w {
+			t.Errorf("slot %d holds %+v, want %+v", i, stack, w)
+		}
+	}
+}
+
+func TestInventoryAdd(t *testing.T) {
+	inv := NewInventory(3)
+	if n := inv.Add("stone", 10); n != 10 {
+		t.Fatalf("added %d stone, want 10", n)
+	}
+	if n := inv.Add("dirt", 5); n != 5 {
+		t.Fatalf("added %d dirt, want 5", n)
+	}
+	if n := inv.Add("stone", 4); n != 4 {
+		t.Fatalf("added %d more stone, want 4", n)
+	}
+	checkSlots(t, inv, ItemStack{Name: "stone", Count: 14}, ItemStack{Name: "dirt", Count: 5})
+
+	if n := inv.Add("stone", 0); n != 0 {
+		t.Errorf("added %d of nothing", n)
+	}
+	if n := inv.Add("", 5); n != 0 {
+		t.Errorf("added %d unnamed items", n)
+	}
+}
+
+func TestInventoryMaxStack(t *testing.T) {
+	inv := NewInventory(3)
+	if n := inv.Add("stone", MaxStackSize+10); n != MaxStackSize+10 {
+		t.Fatalf("added %d stone, want %d", n, MaxStackSize+10)
+	}
+	checkSlots(t, inv, ItemStack{Name: "stone", Count: MaxStackSize}, ItemStack{Name: "stone", Count: 10})
+
+	// Overflow past the last slot is left out
+	if n := inv.Add("stone", 2*MaxStackSize); n != 2*MaxStackSize-10 {
+		t.Errorf("added %d stone to a nearly full inventory, want %d", n, 2*MaxStackSize-10)
+	}
+	if n := inv.Add("dirt", 1); n != 0 {
+		t.Errorf("added %d dirt to a full inventory", n)
+	}
+
+	// Tools don't stack
+	inv = NewInventory(3)
+	if n := inv.Add("woodPickaxe", 2); n != 2 {
+		t.Fatalf("added %d pickaxes, want 2", n)
+	}
+	checkSlots(t, inv, ItemStack{Name: "woodPickaxe", Count: 1}, ItemStack{Name: "woodPickaxe", Count: 1})
+}
+
+func TestInventoryMetaMismatch(t *testing.T) {
+	inv := NewInventory(3)
+	inv.AddStack(ItemStack{Name: "stone", Count: 5, Meta: 1})
+	inv.AddStack(ItemStack{Name: "stone", Count: 3, Meta: 2})
+	inv.AddStack(ItemStack{Name: "stone", Count: 2, Meta: 1})
+	checkSlots(t, inv,
+		ItemStack{Name: "stone", Count: 7, Meta: 1},
+		ItemStack{Name: "stone", Count: 3, Meta: 2},
+	)
+}
+
+func TestInventoryAddStackBetween(t *testing.T) {
+	inv := NewInventory(4)
+	inv.Slots[0] = ItemStack{Name: "stone", Count: 1}
+
+	// Only the slots in range are topped up or filled
+	if n := inv.AddStackBetween(ItemStack{Name: "stone", Count: 5}, 2, 4); n != 5 {
+		t.Fatalf("added %d stone, want 5", n)
+	}
+	checkSlots(t, inv, ItemStack{Name: "stone", Count: 1}, ItemStack{}, ItemStack{Name: "stone", Count: 5})
+
+	if n := inv.AddStackBetween(ItemStack{Name: "woodPickaxe", Count: 3}, 2, 4); n != 1 {
+		t.Errorf("added %d pickaxes to one free slot, want 1", n)
+	}
+	if !inv.Slot(1).Empty() {
+		t.Errorf("slot 1 outside the range was filled with %+v", inv.Slot(1))
+	}
+}
+
+func TestInventoryRemove(t *testing.T) {
+	inv := NewInventory(4)
+	inv.Slots[0] = ItemStack{Name: "stone", Count: 5}
+	inv.Slots[1] = ItemStack{Name: "dirt", Count: 5}
+	inv.Slots[3] = ItemStack{Name: "stone", Count: 3}
+
+	// The last slots run out first
+	if n := inv.Remove("stone", 4); n != 4 {
+		t.Fatalf("removed %d stone, want 4", n)
+	}
+	checkSlots(t, inv, ItemStack{Name: "stone", Count: 4}, ItemStack{Name: "dirt", Count: 5})
+
+	if n := inv.Remove("stone", 10); n != 4 {
+		t.Errorf("removed %d stone, want the 4 left", n)
+	}
+	if inv.Has("stone", 1) {
+		t.Errorf("stone left after removing all of it")
+	}
+	if n := inv.Count("dirt"); n != 5 {
+		t.Errorf("%d dirt left, want 5", n)
+	}
+}
+
+func TestInventoryTakeFromSlot(t *testing.T) {
+	inv := NewInventory(2)
+	inv.Slots[0] = ItemStack{Name: "stone", Count: 5, Meta: 3}
+
+	taken := inv.TakeFromSlot(0, 2)
+	if taken != (ItemStack{Name: "stone", Count: 2, Meta: 3}) {
+		t.Errorf("took %+v, want 2 stone with meta 3", taken)
+	}
+	if taken = inv.TakeFromSlot(0, 10); taken.Count != 3 {
+		t.Errorf("took %d stone, want the 3 left", taken.Count)
+	}
+	checkSlots(t, inv)
+
+	if taken = inv.TakeFromSlot(1, 1); !taken.Empty() {
+		t.Errorf("took %+v from an empty slot", taken)
+	}
+	inv.Slots[1] = ItemStack{Name: "dirt", Count: 1}
+	if taken = inv.TakeFromSlot(1, 0); !taken.Empty() {
+		t.Errorf("took %+v when asking for none", taken)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+//  --------------------------------------------------
+//  Items.go contains every kind of item that isn't a
+//  block, by name. Tools are used in mining.go,
+//  weapons in weapons.go, wands in magic.go and armor
+//  in equipment.go.
+//
+//  Nothing here touches the engine. The textures and
+//  materials of items are created by their loaders
+//  (loadTools etc.), which only the game runs.
+//  --------------------------------------------------
+
+//  --------------------------------------------------
+//  Tools
+//  --------------------------------------------------
+
+// Tool is an item that mines blocks
+type Tool struct {
+	Name    string
+	Texture string
+
+	// Blocks with a higher tier can't be mined with the tool
+	Tier int
+
+	// Mining time is divided by this
+	Speed float64
+
+	Material *renderMaterial
+}
+
+// Hand mines when no tool is held
+var Hand = &Tool{Name: "hand", Tier: 0, Speed: 1}
+
+// Tools are all the tools, by name
+var Tools = map[string]*Tool{
+	"woodPickaxe":      {Name: "woodPickaxe", Texture: "woodPickaxe.png", Tier: 1, Speed: 1.5},
+	"stonePickaxe":     {Name: "stonePickaxe", Texture: "stonePickaxe.png", Tier: 2, Speed: 2.5},
+	"shithyrilPickaxe": {Name: "shithyrilPickaxe", Texture: "shithyrilPickaxe.png", Tier: 3, Speed: 4},
+}
+
+// GetTool returns the tool with a name, or nil if there is none
+func GetTool(name string) *Tool {
+	return Tools[name]
+}
+
+//  --------------------------------------------------
+//  Weapons
+//  --------------------------------------------------
+
+// Weapon is an item that hits enemies
+type Weapon struct {
+	Name string
+
+	Damage float32
+
+	// How far in front of the player the weapon hits, in pixels
+	Reach float32
+
+	// Swings per second
+	SwingSpeed float64
+
+	// Speed enemies are knocked away at when hit
+	Knockback float32
+
+	// Chance from 0 to 1 of a hit doing CritMultiplier times the damage
+	CritChance float64
+
+	// How the weapon is swung, see SwingStyles
+	Style string
+
+	Icon     *renderMaterial
+	Material *renderMaterial
+}
+
+// Weapons are all the weapons, by name
+var Weapons = map[string]*Weapon{
+	"basicSword":     {Name: "basicSword", Damage: 14, Reach: 60, SwingSpeed: 2.5, Knockback: 250, CritChance: 0.05, Style: "slash"},
+	"goldSword":      {Name: "goldSword", Damage: 22, Reach: 85, SwingSpeed: 2.2, Knockback: 300, CritChance: 0.08, Style: "slash"},
+	"blueSword":      {Name: "blueSword", Damage: 28, Reach: 85, SwingSpeed: 2.4, Knockback: 320, CritChance: 0.1, Style: "slash"},
+	"lightningSword": {Name: "lightningSword", Damage: 34, Reach: 75, SwingSpeed: 3.5, Knockback: 200, CritChance: 0.15, Style: "slash"},
+	"spiralSword":    {Name: "spiralSword", Damage: 40, Reach: 85, SwingSpeed: 2, Knockback: 450, CritChance: 0.1, Style: "slash"},
+	"spear":          {Name: "spear", Damage: 30, Reach: 130, SwingSpeed: 1.5, Knockback: 380, CritChance: 0.05, Style: "stab"},
+}
+
+// GetWeapon returns the weapon with a name, or nil if there is none
+func GetWeapon(name string) *Weapon {
+	return Weapons[name]
+}
+
+//  --------------------------------------------------
+//  Wands
+//  --------------------------------------------------
+
+// Wand is an item that fires projectiles for mana
+type Wand struct {
+	Name string
+
+	// Kind of projectile fired, see ProjectileKinds
+	Projectile string
+
+	ManaCost float32
+
+	// Seconds between casts
+	Cooldown float64
+
+	Icon *renderMaterial
+}
+
+// Wands are all the wands, by name
+var Wands = map[string]*Wand{
+	"iceWand1": {Name: "iceWand1", Projectile: "iceShard", ManaCost: 5, Cooldown: 0.35},
+	"iceWand2": {Name: "iceWand2", Projectile: "frostBolt", ManaCost: 8, Cooldown: 0.45},
+	"iceWand3": {Name: "iceWand3", Projectile: "iceLance", ManaCost: 15, Cooldown: 0.6},
+}
+
+// GetWand returns the wand with a name, or nil if there is none
+func GetWand(name string) *Wand {
+	return Wands[name]
+}
+
+//  --------------------------------------------------
+//  Armor
+//  --------------------------------------------------
+
+// Stats are the parts of the player that equipment changes
+type Stats struct {
+	Defense   float32
+	MaxHealth float32
+
+	// Fractions added to the base values, so 0.1 is 10% more
+	Speed  float32
+	Jump   float32
+	Damage float32
+}
+
+// Add returns the sum of two sets of stats
+func (s Stats) Add(other Stats) Stats {
+	return Stats{
+		Defense:   s.Defense + other.Defense,
+		MaxHealth: s.MaxHealth + other.MaxHealth,
+		Speed:     s.Speed + other.Speed,
+		Jump:      s.Jump + other.Jump,
+		Damage:    s.Damage + other.Damage,
+	}
+}
+
+// Armor is an item that can be worn
+type Armor struct {
+	Name string
+
+	// Kind of slot the armor goes in, see ArmorSlots
+	Slot string
+
+	Stats Stats
+
+	Icon *renderMaterial
+}
+
+// ArmorSlots are the equipment slots each kind of armor goes in
+var ArmorSlots = map[string][]int{
+	"head":      {EquipHead},
+	"chest":     {EquipChest},
+	"legs":      {EquipLegs},
+	"accessory": {EquipAccessory1, EquipAccessory2},
+}
+
+// Armors are all the armor and accessories, by name
+var Armors = map[string]*Armor{
+	"woodHelmet":     {Name: "woodHelmet", Slot: "head", Stats: Stats{Defense: 1}},
+	"woodChestplate": {Name: "woodChestplate", Slot: "chest", Stats: Stats{Defense: 2}},
+	"woodGreaves":    {Name: "woodGreaves", Slot: "legs", Stats: Stats{Defense: 1}},
+
+	"shithyrilHelmet":     {Name: "shithyrilHelmet", Slot: "head", Stats: Stats{Defense: 3, MaxHealth: 10}},
+	"shithyrilChestplate": {Name: "shithyrilChestplate", Slot: "chest", Stats: Stats{Defense: 5, MaxHealth: 20}},
+	"shithyrilGreaves":    {Name: "shithyrilGreaves", Slot: "legs", Stats: Stats{Defense: 3, Speed: 0.05}},
+
+	"featherCharm": {Name: "featherCharm", Slot: "accessory", Stats: Stats{Jump: 0.2}},
+	"swiftCharm":   {Name: "swiftCharm", Slot: "accessory", Stats: Stats{Speed: 0.15}},
+	"powerRing":    {Name: "powerRing", Slot: "accessory", Stats: Stats{Damage: 0.15}},
+	"heartAmulet":  {Name: "heartAmulet", Slot: "accessory", Stats: Stats{MaxHealth: 25}},
+}
+
+// GetArmor returns the armor with a name, or nil if there is none
+func GetArmor(name string) *Armor {
+	return Armors[name]
+}
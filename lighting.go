@@ -4,67 +4,71 @@ package main
 //   Lighting
 //   --------------------------------------------------
 
-func CreateLighting(x, y int, light float32) {
+func (tree *WorldTree) CreateLighting(x, y int, light float32) {
 	if !IsValidPosition(x, y) {
 		return
 	}
-	newLight := light - GetLightBlockAmount(x, y)
-	if newLight <= WorldMap.GetDarkness(x, y) {
+	newLight := light - tree.GetLightBlockAmount(x, y)
+	if newLight <= tree.GetDarkness(x, y) {
 		return
 	}
 
-	WorldMap.SetDarkness(x, y, newLight)
+	tree.SetDarkness(x, y, newLight)
 
-	CreateLighting(x+1, y, newLight)
-	CreateLighting(x, y+1, newLight)
-	CreateLighting(x-1, y, newLight)
-	CreateLighting(x, y-1, newLight)
+	tree.CreateLighting(x+1, y, newLight)
+	tree.CreateLighting(x, y+1, newLight)
+	tree.CreateLighting(x-1, y, newLight)
+	tree.CreateLighting(x, y-1, newLight)
 }
 
-func CreateLightingLimit(x, y int, light float32, limit int) {
+func (tree *WorldTree) CreateLightingLimit(x, y int, light float32, limit int) {
 	if limit < 1 {
 		return
 	}
 	if !IsValidPosition(x, y) {
 		return
 	}
-	newLight := light - GetLightBlockAmount(x, y)
-	if newLight <= WorldMap.GetDarkness(x, y) {
+	newLight := light - tree.GetLightBlockAmount(x, y)
+	if newLight <= tree.GetDarkness(x, y) {
 		return
 	}
 
-	WorldMap.SetDarkness(x, y, newLight)
+	tree.SetDarkness(x, y, newLight)
 
-	CreateLightingLimit(x+1, y, newLight, limit-1)
-	CreateLightingLimit(x, y+1, newLight, limit-1)
-	CreateLightingLimit(x-1, y, newLight, limit-1)
-	CreateLightingLimit(x, y-1, newLight, limit-1)
+	tree.CreateLightingLimit(x+1, y, newLight, limit-1)
+	tree.CreateLightingLimit(x, y+1, newLight, limit-1)
+	tree.CreateLightingLimit(x-1, y, newLight, limit-1)
+	tree.CreateLightingLimit(x, y-1, newLight, limit-1)
 }
 
-func FixLightingAt(x, y int) {
+func (tree *WorldTree) FixLightingAt(x, y int) {
 	maxLight := float32(0)
-	if l := WorldMap.GetDarkness(x+1, y); l > maxLight {
-		maxLight = l - GetLightBlockAmount(x+1, y)
+	if l := tree.GetDarkness(x+1, y); l > maxLight {
+		maxLight = l - tree.GetLightBlockAmount(x+1, y)
 	}
-	if l := WorldMap.GetDarkness(x, y+1); l > maxLight {
-		maxLight = l - GetLightBlockAmount(x, y+1)
+	if l := tree.GetDarkness(x, y+1); l > maxLight {
+		maxLight = l - tree.GetLightBlockAmount(x, y+1)
 	}
-	if l := WorldMap.GetDarkness(x-1, y); l > maxLight {
-		maxLight = l - GetLightBlockAmount(x-1, y)
+	if l := tree.GetDarkness(x-1, y); l > maxLight {
+		maxLight = l - tree.GetLightBlockAmount(x-1, y)
 	}
-	if l := WorldMap.GetDarkness(x, y-1); l > maxLight {
-		maxLight = l - GetLightBlockAmount(x, y-1)
+	if l := tree.GetDarkness(x, y-1); l > maxLight {
+		maxLight = l - tree.GetLightBlockAmount(x, y-1)
 	}
-	WorldMap.SetDarkness(x, y, maxLight-GetLightBlockAmount(x, y))
-	WorldMap.UpdateBackBlockMaterial(x, y)
-	WorldMap.UpdateWorldBlockMaterial(x, y)
+	tree.SetDarkness(x, y, maxLight-tree.GetLightBlockAmount(x, y))
+	tree.UpdateBackBlockMaterial(x, y)
+	tree.UpdateWorldBlockMaterial(x, y)
 }
 
-func GetLightBlockAmount(x, y int) float32 {
-	if WorldMap.GetWorldBlockID(x, y) == "00000" {
-		return GetBlock(WorldMap.GetBackBlockName(x, y)).LightBlock
+func (tree *WorldTree) GetLightBlockAmount(x, y int) float32 {
+	if tree.GetWorldBlockID(x, y) == BlockEmpty {
+		amount := GetBlock(tree.GetBackBlockName(x, y)).LightBlock
+		if liquid := tree.GetLiquid(x, y); liquid.Level > 0 {
+			amount += LiquidTypes[liquid.Type].LightBlock * float32(liquid.Level) / MaxLiquidLevel
+		}
+		return amount
 	}
-	return GetBlock(WorldMap.GetWorldBlockName(x, y)).LightBlock
+	return GetBlock(tree.GetWorldBlockName(x, y)).LightBlock
 }
 
 func IsValidPosition(x, y int) bool {
@@ -0,0 +1,192 @@
+//go:build !gen
+// +build !gen
+
+package main
+
+import (
+	"rapidengine/child"
+	"rapidengine/cmd"
+	"rapidengine/geometry"
+)
+
+//  --------------------------------------------------
+//  Liquidflow.go contains drawing liquids and letting
+//  them flow while the game runs (see liquids.go).
+//  --------------------------------------------------
+
+// liquidChildren render liquids, one per level so
+// a partly filled tile is drawn at its height
+var liquidChildren [MaxLiquidLevel + 1]*child.Child2D
+
+func initializeLiquids() {
+	for i := range LiquidTypes {
+		m := Engine.MaterialControl.NewBasicMaterial()
+		m.Hue = LiquidTypes[i].Hue
+		m.Blending = true
+		LiquidTypes[i].Material = m
+	}
+
+	for level := range liquidChildren {
+		liquidChildren[level] = Engine.ChildControl.NewChild2D()
+		liquidChildren[level].AttachMesh(geometry.NewRectangle())
+		liquidChildren[level].ScaleX = BlockSize
+		liquidChildren[level].ScaleY = float32(BlockSize*level) / MaxLiquidLevel
+		liquidChildren[level].EnableCopying()
+	}
+}
+
+func renderLiquid(renderer *cmd.Renderer, x, y int, liquid Liquid) {
+	renderer.RenderCopy(liquidChildren[liquid.Level], child.ChildCopy{
+		X:        float32(x * BlockSize),
+		Y:        float32(y * BlockSize),
+		Material: LiquidTypes[liquid.Type].Material,
+		Darkness: WorldMap.GetDarkness(x, y),
+	})
+}
+
+//  --------------------------------------------------
+//  Simulation
+//  --------------------------------------------------
+
+// UpdateLiquids steps the liquids in the active chunks around a position
+func (tree *WorldTree) UpdateLiquids(px, py float32, dt float64) {
+	tree.liquidTimer += dt
+	if tree.liquidTimer < LiquidTickTime {
+		return
+	}
+	tree.liquidTimer -= LiquidTickTime
+
+	// Don't try to catch up after a long frame
+	if tree.liquidTimer > LiquidTickTime {
+		tree.liquidTimer = 0
+	}
+
+	// Alternate the direction liquids spread first, so they don't drift
+	tree.liquidTick++
+	dir := 1
+	if tree.liquidTick%2 == 0 {
+		dir = -1
+	}
+	tree.liquidArrived = make(map[[2]int]uint8)
+
+	pcx := int(px/BlockSize) / ChunkSize
+	pcy := int(py/BlockSize) / ChunkSize
+
+	// Bottom up, like the rows in each chunk. Liquid only moves one
+	// tile per step either way, see flowLiquid.
+	for cy := pcy - LiquidSimRadius; cy <= pcy+LiquidSimRadius; cy++ {
+		for cx := pcx - LiquidSimRadius; cx <= pcx+LiquidSimRadius; cx++ {
+			c, ok := tree.chunks[ChunkPos{cx, cy}]
+			if !ok || !c.liquidActive {
+				continue
+			}
+
+			// Anything that moves wakes the chunk back up
+			c.liquidActive = false
+			tree.stepChunkLiquids(cx, cy, dir)
+		}
+	}
+}
+
+func (tree *WorldTree) stepChunkLiquids(cx, cy, dir int) {
+	x0, y0, x1, y1 := chunkBounds(cx, cy)
+	for y := y0; y < y1; y++ {
+		if dir > 0 {
+			for x := x0; x < x1; x++ {
+				tree.flowLiquid(x, y, dir)
+			}
+		} else {
+			for x := x1 - 1; x >= x0; x-- {
+				tree.flowLiquid(x, y, dir)
+			}
+		}
+	}
+}
+
+// flowLiquid moves the liquid of a tile down, then sideways. Liquid
+// that arrived in this tick stays until the next one, so it moves as
+// fast whichever way the tiles are stepped.
+func (tree *WorldTree) flowLiquid(x, y, dir int) {
+	liquid := tree.GetLiquid(x, y)
+	arrived := tree.liquidArrived[[2]int{x, y}]
+	if liquid.Level <= arrived {
+		return
+	}
+	free := liquid.Level - arrived
+	rate := LiquidTypes[liquid.Type].FlowRate
+
+	// Fall
+	if tree.canHoldLiquid(x, y-1) {
+		below := tree.GetLiquid(x, y-1)
+		if below.Level > 0 && below.Type != liquid.Type {
+			mixLiquids(x, y, x, y-1)
+			return
+		}
+		if below.Level < MaxLiquidLevel {
+			moved := minLevel(free, MaxLiquidLevel-below.Level, rate)
+			tree.moveLiquid(x, y, x, y-1, moved)
+			liquid.Level -= moved
+			free -= moved
+			if free == 0 {
+				return
+			}
+		}
+	}
+
+	// Spread, evening out with each side
+	for _, nx := range [2]int{x + dir, x - dir} {
+		if !tree.canHoldLiquid(nx, y) {
+			continue
+		}
+		side := tree.GetLiquid(nx, y)
+		if side.Level > 0 && side.Type != liquid.Type {
+			mixLiquids(x, y, nx, y)
+			return
+		}
+
+		// Liquid pours over edges, even the last level of it
+		var moved uint8
+		if side.Level == 0 && tree.canHoldLiquid(nx, y-1) && tree.GetLiquid(nx, y-1).Level < MaxLiquidLevel {
+			moved = minLevel((liquid.Level+1)/2, rate, free)
+		} else if liquid.Level >= side.Level+2 {
+			moved = minLevel((liquid.Level-side.Level)/2, rate, free)
+		}
+		if moved == 0 {
+			continue
+		}
+
+		tree.moveLiquid(x, y, nx, y, moved)
+		liquid.Level -= moved
+		free -= moved
+		if free == 0 {
+			return
+		}
+	}
+}
+
+// moveLiquid moves levels of liquid from one tile into another
+func (tree *WorldTree) moveLiquid(x1, y1, x2, y2 int, levels uint8) {
+	from, to := tree.GetLiquid(x1, y1), tree.GetLiquid(x2, y2)
+	tree.SetLiquid(x2, y2, Liquid{from.Type, to.Level + levels})
+	tree.SetLiquid(x1, y1, Liquid{from.Type, from.Level - levels})
+	tree.liquidArrived[[2]int{x2, y2}] += levels
+}
+
+// mixLiquids hardens whichever of two touching tiles holds lava into stone
+func mixLiquids(x1, y1, x2, y2 int) {
+	for _, p := range [2][2]int{{x1, y1}, {x2, y2}} {
+		if WorldMap.GetLiquid(p[0], p[1]).Type == LiquidLava {
+			placeBlock(p[0], p[1], "stone")
+		}
+	}
+}
+
+func minLevel(a, b, c uint8) uint8 {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+)
+
+//  --------------------------------------------------
+//  Liquids.go contains water and lava.
+//
+//  Every tile of the world holds a liquid level, from
+//  0 for dry to MaxLiquidLevel for a full tile. Liquids
+//  fall first and then even out with their neighbours,
+//  a few times a second, so they settle into caves and
+//  basins. Only chunks near the player where something
+//  changed are simulated; a chunk goes back to sleep
+//  once nothing in it moves.
+//
+//  Water touching lava turns the lava into stone.
+//
+//  Flowing and drawing are part of the game only, see
+//  liquidflow.go.
+//  --------------------------------------------------
+
+// Liquid is the liquid in a tile
+type Liquid struct {
+	Type  uint8
+	Level uint8
+}
+
+// Liquid types, indices into LiquidTypes
+const (
+	LiquidNone = iota
+	LiquidWater
+	LiquidLava
+)
+
+// Level of a full tile of liquid
+const MaxLiquidLevel = 16
+
+// Seconds between steps of the simulation
+const LiquidTickTime = 0.05
+
+// Liquids are simulated this many chunks around the player
+const LiquidSimRadius = 2
+
+// LiquidType is how a liquid looks, flows and
+// affects the things inside of it
+type LiquidType struct {
+	Name string
+
+	// Most levels that flow out of a tile per step, lower is thicker
+	FlowRate uint8
+
+	// Speed multiplier of anything inside
+	Drag float32
+
+	// Light blocked by a full tile (see GetLightBlockAmount)
+	LightBlock float32
+
+	// Damage per second to anything inside
+	Damage float32
+
+	Hue       [4]float32
+	SaveColor [3]int
+
+	Material *renderMaterial
+}
+
+// LiquidTypes are all the liquids, indexed by Liquid.Type
+var LiquidTypes = []LiquidType{
+	{
+		Name: "none",
+	},
+	{
+		Name:       "water",
+		FlowRate:   8,
+		Drag:       0.5,
+		LightBlock: 0.05,
+		Hue:        [4]float32{40, 110, 220, 0.6},
+		SaveColor:  [3]int{40, 110, 220},
+	},
+	{
+		Name:       "lava",
+		FlowRate:   2,
+		Drag:       0.3,
+		LightBlock: 0.1,
+		Damage:     40,
+		Hue:        [4]float32{230, 90, 20, 0.9},
+		SaveColor:  [3]int{230, 90, 20},
+	},
+}
+
+// liquidAt returns the liquid at a position in pixels, or nil if it is dry
+func liquidAt(px, py float32) *LiquidType {
+	x, y := int(px/BlockSize), int(py/BlockSize)
+	liquid := WorldMap.GetLiquid(x, y)
+	if liquid.Level == 0 || py-float32(y*BlockSize) > float32(BlockSize*int(liquid.Level))/MaxLiquidLevel {
+		return nil
+	}
+	return &LiquidTypes[liquid.Type]
+}
+
+// canHoldLiquid returns whether liquid can flow into a tile
+func (tree *WorldTree) canHoldLiquid(x, y int) bool {
+	return isInWorld(x, y) && tree.GetWorldBlockID(x, y) == BlockEmpty
+}
+
+//  --------------------------------------------------
+//  Generation
+//  --------------------------------------------------
+
+// Lake sizes in blocks, the width being half the lake
+const MinLakeWidth = 4
+const MaxLakeWidth = 10
+const MinLakeDepth = 3
+const MaxLakeDepth = 7
+
+// Blocks of dry ground between lakes
+const LakeSpacing = 20
+
+// LiquidPool is a liquid left in pools on the floor of caves
+type LiquidPool struct {
+	Liquid uint8
+
+	// Depth range of the pools, from 0 at the surface to 1 at the bottom
+	MinDepth float64
+	MaxDepth float64
+
+	// Tries per 1000 columns of the world
+	Rarity float64
+
+	// Pools never get deeper than this many blocks,
+	// and are left out if they would fill more tiles
+	MaxDepthBlocks int
+	MaxTiles       int
+}
+
+// LiquidPools are all the pools generated in caves
+var LiquidPools = []LiquidPool{
+	{
+		Liquid:         LiquidWater,
+		MinDepth:       0.1,
+		MaxDepth:       0.6,
+		Rarity:         80,
+		MaxDepthBlocks: 3,
+		MaxTiles:       120,
+	},
+	{
+		Liquid:         LiquidLava,
+		MinDepth:       0.7,
+		MaxDepth:       1,
+		Rarity:         60,
+		MaxDepthBlocks: 2,
+		MaxTiles:       160,
+	},
+}
+
+// generateLakes digs lakes into flat ground, as often as each biome asks for
+func generateLakes(world *WorldTree, rng *rand.Rand) {
+	for x := MaxLakeWidth + 1; x < WorldWidth-MaxLakeWidth-1; x++ {
+		if rng.Float64()*1000 >= getBiome(x).Lakes {
+			continue
+		}
+
+		width := MinLakeWidth + rng.Intn(MaxLakeWidth-MinLakeWidth+1)
+		depth := MinLakeDepth + rng.Intn(MaxLakeDepth-MinLakeDepth+1)
+		if digLake(world, x, width, depth) {
+			x += 2*width + LakeSpacing
+		}
+	}
+}
+
+// digLake digs a bowl around a column, lines it with sand and fills it
+// with water up to the lower of its banks. Returns false if the ground
+// is too uneven.
+func digLake(world *WorldTree, cx, width, depth int) bool {
+	left, right := cx-width-1, cx+width+1
+
+	surface := HeightMap[left]
+	if HeightMap[right] < surface {
+		surface = HeightMap[right]
+	}
+	for x := left; x <= right; x++ {
+		if abs(HeightMap[x]-surface) > 2 || getBiome(x).Lakes == 0 {
+			return false
+		}
+	}
+
+	for x := left + 1; x < right; x++ {
+		d := float64(x-cx) / float64(width+1)
+		bottom := surface - int(math.Round(float64(depth)*(1-d*d)))
+		if bottom >= surface {
+			bottom = surface - 1
+		}
+
+		for y := bottom + 1; y <= HeightMap[x]; y++ {
+			world.RemoveWorldBlock(x, y)
+			world.RemoveGrassBlock(x, y)
+			world.RemoveNatureBlock(x, y)
+		}
+		for y := bottom + 1; y <= surface; y++ {
+			world.SetLiquid(x, y, Liquid{LiquidWater, MaxLiquidLevel})
+		}
+		if world.GetWorldBlockID(x, bottom) != BlockEmpty {
+			world.createWorldBlock(x, bottom, "sand")
+		}
+		HeightMap[x] = bottom
+	}
+	return true
+}
+
+// generatePools fills dips in the floor of caves with liquid
+func generatePools(world *WorldTree, rng *rand.Rand) {
+	for _, pool := range LiquidPools {
+		tries := int(pool.Rarity * float64(WorldWidth) / 1000)
+		for i := 0; i < tries; i++ {
+			x := rng.Intn(WorldWidth)
+
+			// Depth is relative to the ground of the column
+			top := int(float64(HeightMap[x]) * (1 - pool.MinDepth))
+			bottom := int(float64(HeightMap[x]) * (1 - pool.MaxDepth))
+			if top <= bottom {
+				continue
+			}
+			y := bottom + rng.Intn(top-bottom)
+			if !world.canHoldLiquid(x, y) || world.GetLiquid(x, y).Level > 0 {
+				continue
+			}
+
+			// Drop down to the floor of the cave
+			for y > 0 && world.canHoldLiquid(x, y-1) {
+				y--
+			}
+			if y == 0 {
+				continue
+			}
+			fillPool(world, pool, x, y)
+		}
+	}
+}
+
+// fillPool fills the tiles connected to a cave floor up to the depth of a
+// pool. Nothing is filled if that would be too many tiles, since the pool
+// would spill into the rest of the cave.
+func fillPool(world *WorldTree, pool LiquidPool, x, y int) {
+	type pos struct{ x, y int }
+
+	maxY := y + pool.MaxDepthBlocks - 1
+	visited := map[pos]bool{{x, y}: true}
+	queue := []pos{{x, y}}
+
+	for i := 0; i < len(queue); i++ {
+		if len(queue) > pool.MaxTiles {
+			return
+		}
+		p := queue[i]
+		for _, n := range []pos{{p.x + 1, p.y}, {p.x - 1, p.y}, {p.x, p.y + 1}, {p.x, p.y - 1}} {
+			if visited[n] || n.y > maxY || !world.canHoldLiquid(n.x, n.y) || world.GetLiquid(n.x, n.y).Level > 0 {
+				continue
+			}
+			visited[n] = true
+			queue = append(queue, n)
+		}
+	}
+
+	for _, p := range queue {
+		world.SetLiquid(p.x, p.y, Liquid{pool.Liquid, MaxLiquidLevel})
+	}
+}
@@ -0,0 +1,73 @@
+//go:build !gen
+// +build !gen
+
+package main
+
+import "path/filepath"
+
+//  --------------------------------------------------
+//  Magic.go contains wands and the mana they use.
+//
+//  A wand fires a projectile (see projectiles.go) from
+//  the player's hand towards the cursor on left click,
+//  as long as the player has the mana to pay for it.
+//  Mana comes back by itself, starting a moment after
+//  the last cast.
+//  --------------------------------------------------
+
+// Mana the player starts with
+const BaseMaxMana = 100
+
+// Mana regenerated per second, after ManaRegenDelay seconds without casting
+const ManaRegen = 12
+const ManaRegenDelay = 1
+
+func loadWands() {
+	for name, wand := range Wands {
+		Engine.TextureControl.NewTexture(filepath.Join(WeaponTextureDir, name+".png"), name, "pixel")
+		wand.Icon = Engine.MaterialControl.NewBasicMaterial()
+		wand.Icon.DiffuseLevel = 1
+		wand.Icon.DiffuseMap = Engine.TextureControl.GetTexture(name)
+	}
+}
+
+// heldWand returns the wand in the active hotbar slot, or nil
+func (p *Player) heldWand() *Wand {
+	return GetWand(p.HeldItem().Name)
+}
+
+// CastWand fires the held wand towards a position in pixels,
+// returning false if nothing was fired
+func (p *Player) CastWand(targetX, targetY float32) bool {
+	wand := p.heldWand()
+	if wand == nil || p.AttackCooldown > 0 || p.HitStun > 0 || p.Mana < wand.ManaCost || p.Dead {
+		return false
+	}
+
+	p.Mana -= wand.ManaCost
+	p.ManaRegenTimer = ManaRegenDelay
+	p.AttackCooldown = wand.Cooldown
+
+	handX := p.CenterX + p.Hitbox1.DAABB.Width/2
+	handY := p.CenterY + WeaponHandY
+	if targetX < handX {
+		p.PlayerMaterial.Flipped = 1
+	} else {
+		p.PlayerMaterial.Flipped = 0
+	}
+	projectile := spawnProjectile(GetProjectileKind(wand.Projectile), handX, handY, targetX, targetY)
+	projectile.Damage = p.damageDealt(projectile.Damage)
+	return true
+}
+
+// updateMana regenerates mana once the player hasn't cast for a while
+func (p *Player) updateMana(dt float64) {
+	if p.ManaRegenTimer > 0 {
+		p.ManaRegenTimer -= dt
+		return
+	}
+	p.Mana += ManaRegen * float32(dt)
+	if p.Mana > p.MaxMana {
+		p.Mana = p.MaxMana
+	}
+}
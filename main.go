@@ -1,6 +1,10 @@
+//go:build !gen
+// +build !gen
+
 package main
 
 import (
+	"log"
 	"math"
 	_ "net/http/pprof"
 	"rapidengine/child"
@@ -49,6 +53,22 @@ func main() {
 
 	Engine.TextControl.LoadFont("./assets/vermin.ttf", "pixel", 32, 15)
 
+	if err := loadSettings(); err != nil {
+		log.Fatal(err)
+	}
+	if err := loadBlocks(); err != nil {
+		log.Fatal(err)
+	}
+	loadBlockMaterials()
+	loadTools()
+	loadWeapons()
+	loadWands()
+	loadArmor()
+	loadProjectiles()
+	if err := loadRecipes(); err != nil {
+		log.Fatal(err)
+	}
+
 	InitializeHitboxViewer()
 	V.Mat.Hue = [4]float32{200, 100, 0, 255}
 	V.Mat.DiffuseLevel = 0
@@ -58,9 +78,12 @@ func main() {
 	InitializeMenuScene()
 	InitializeSaveScene()
 	InitializeHotbarScene()
+	InitializeDamageNumbers()
+	InitializeInventoryScene()
 	InitializeChooseScene()
 	InitializeTitleScene()
 	InitializeRespawnScene()
+	InitializeRestoreScene()
 
 	EM = InitializeEnemyManager()
 
@@ -68,11 +91,14 @@ func main() {
 	Engine.SceneControl.InstanceScene(ChooseScene)
 	Engine.SceneControl.InstanceScene(LoadingScene)
 	Engine.SceneControl.InstanceScene(WorldScene)
-	Engine.SceneControl.InstanceScene(SaveScene)
 
 	WorldScene.InstanceSubscene(MenuScene)
 	WorldScene.InstanceSubscene(HotbarScene)
+	WorldScene.InstanceSubscene(InventoryScene)
 	WorldScene.InstanceSubscene(RespawnScene)
+	WorldScene.InstanceSubscene(SaveScene)
+
+	ChooseScene.InstanceSubscene(RestoreScene)
 
 	Engine.SceneControl.SetCurrentScene(TitleScene)
 
@@ -117,7 +143,7 @@ func render(renderer *cmd.Renderer, inputs *input.Input) {
 
 	if inputs.Keys["e"] {
 		if !JustEnemy {
-			EM.NewGoblin(50)
+			EM.SpawnEnemy(50)
 			JustEnemy = true
 		}
 	} else {
@@ -130,12 +156,6 @@ func render(renderer *cmd.Renderer, inputs *input.Input) {
 
 	//println(Engine.PostControl.BloomOffsetX, Engine.PostControl.BloomOffsetY)
 
-	if inputs.Keys["h"] {
-		ChangeParallax()
-	} else {
-		JustParallax = false
-	}
-
 	if inputs.Keys["q"] {
 		if !JustKnock {
 			for _, e := range EM.AllEnemies {
@@ -155,6 +175,10 @@ func render(renderer *cmd.Renderer, inputs *input.Input) {
 		updateTitleScreen()
 	}
 
+	if Engine.SceneControl.GetCurrentScene().ID == "choose" {
+		updateSeedInput(inputs)
+	}
+
 	if MenuScene.IsActive() {
 		renderer.RenderChild(MenuBackChild)
 	}
@@ -162,17 +186,26 @@ func render(renderer *cmd.Renderer, inputs *input.Input) {
 	if HotbarScene.IsActive() {
 		renderer.RenderChild(Player1Health.BackChild)
 		renderer.RenderChild(Player1Health.BarChild)
+		renderer.RenderChild(Player1Mana.BackChild)
+		renderer.RenderChild(Player1Mana.BarChild)
+	}
+
+	if InventoryScene.IsActive() {
+		renderInventoryScene(renderer)
 	}
 
 	if WorldScene.IsActive() {
 		renderer.RenderChild(ActiveChild)
 		for i := 0; i < NumSlots; i++ {
-			renderer.RenderChild(BarChildren[i])
+			if !Player1.Inventory.Slot(i).Empty() {
+				renderer.RenderChild(BarChildren[i])
+			}
 		}
 	}
 
 	ActiveItem = int(math.Abs(inputs.Scroll*MouseSensitivity)) % NumSlots
 	UpdateActiveItem()
+	UpdateHotBar()
 
 	if inputs.Keys["b"] {
 		Engine.PostControl.BloomIntensity += 0.01
@@ -202,32 +235,56 @@ func renderWorldScene(renderer *cmd.Renderer, inputs *input.Input) {
 
 	renderer.RenderChild(SunChild)
 
-	renderer.RenderChild(Back6Child)
-	renderer.RenderChild(Back5Child)
-	renderer.RenderChild(Back4Child)
-	renderer.RenderChild(Back3Child)
-	renderer.RenderChild(Back2Child)
-	renderer.RenderChild(Back1Child)
+	renderParallax(renderer)
+
+	// Stream in the chunks around the player
+	WorldMap.UpdateChunks(Player1.CenterX, Player1.CenterY)
+	if WorldMap.corrupt != nil {
+		leaveDamagedWorld()
+		return
+	}
+
+	// Finish and start background saves
+	WorldMap.UpdateSave(worldPath(CurrentWorld), !Player1.Dead)
+	updateSaveIndicator()
 
 	renderWorldInBounds(renderer)
+	renderFallingBlocks(renderer)
+	renderDroppedItems(renderer)
+	renderProjectiles(renderer)
 
 	//renderer.RenderChild(colChild)
 	renderer.RenderChild(Player1.PlayerChild)
+	renderWeaponSwing(renderer)
 
 	// Update and render enemies
 	EM.Update()
 
 	renderFrontWorldInBounds(renderer)
 
-	if inputs.Keys["escape"] && !GamePaused {
+	updateInventoryScene(inputs)
+
+	if inputs.Keys["escape"] && !GamePaused && !InventoryScene.IsActive() && !escapeClosedInventory {
 		GamePaused = true
 		MenuScene.Activate()
 	}
 
+	// The inventory screen takes the keys and mouse while it is open
+	if InventoryScene.IsActive() {
+		inputs = &input.Input{Keys: map[string]bool{}, MouseX: inputs.MouseX, MouseY: inputs.MouseY}
+	}
+
 	if !GamePaused {
 		// Update player
 		Player1.Update(inputs)
 
+		// Let water and lava flow around the player
+		WorldMap.UpdateLiquids(Player1.CenterX, Player1.CenterY, renderer.DeltaFrameTime)
+		updateFallingBlocks(float32(renderer.DeltaFrameTime))
+		updateDroppedItems(float32(renderer.DeltaFrameTime))
+		updateProjectiles(float32(renderer.DeltaFrameTime))
+		updateDamageNumbers(renderer.DeltaFrameTime)
+
 		cx, cy, _ := renderer.MainCamera.GetPosition()
 		bx, by := Engine.CollisionControl.ScaleMouseCoords(inputs.MouseX, inputs.MouseY, cx, cy)
 		snapx, snapy := int(bx/BlockSize), int(-by/BlockSize)
@@ -243,31 +300,18 @@ func renderWorldScene(renderer *cmd.Renderer, inputs *input.Input) {
 			int(Player1.CenterY/BlockSize)+1,
 		)
 
-		if Player1.CurrentMiningTimer <= 0 {
-			Player1.Lastsnapx, Player1.Lastsnapy = snapx, snapy
+		if inputs.LeftMouseButton && !Player1.Dead {
+			Player1.CastWand(bx, -by)
 		}
 
-		if inputs.LeftMouseButton && blockDist < 5 {
-			if Player1.Lastsnapx == snapx && Player1.Lastsnapy == snapy {
-				Player1.CurrentMiningTimer += renderer.DeltaFrameTime
-			} else {
-				Player1.CurrentMiningTimer = 0
-			}
-			if Player1.CurrentMiningTimer > 0.5 { //GetBlock(WorldMap.GetBackBlockName(snapx, snapy)).Durability {
-				destroyBlock(snapx, snapy)
-				Player1.CurrentMiningTimer = 0
-			}
-		} else {
-			Player1.CurrentMiningTimer = 0
-		}
+		mining := inputs.LeftMouseButton && blockDist < 5 && Player1.heldWeapon() == nil && Player1.heldWand() == nil
+		Player1.updateMining(snapx, snapy, mining, renderer.DeltaFrameTime)
+		renderMiningCrack(renderer)
 
 		if inputs.RightMouseButton {
-			if WorldMap.GetWorldBlockID(snapx, snapy) == "00000" {
-				placeBlock(snapx, snapy, HotBarItems[ActiveItem])
-
-				if HotBarItems[ActiveItem] == "torch" {
-					CreateLightingLimit(snapx, snapy, 0.72, 18)
-				}
+			held := Player1.HeldItem()
+			if Player1.PlaceHeldBlock(snapx, snapy) && held.Name == "torch" {
+				WorldMap.CreateLightingLimit(snapx, snapy, 0.72, 18)
 			}
 		}
 
@@ -283,12 +327,15 @@ func renderWorldScene(renderer *cmd.Renderer, inputs *input.Input) {
 		Back6Child.Y = cy - float32(ScreenHeight/2)
 
 		// Parallax: Higher divisor = faster movement = appears closer
-		Back1Child.X = (cx / (WorldWidth * BlockSize / 10000)) / 0.8
-		Back2Child.X = (cx / (WorldWidth * BlockSize / 10000)) / 0.6
-		Back3Child.X = (cx / (WorldWidth * BlockSize / 10000)) / 0.3
-		Back4Child.X = (cx / (WorldWidth * BlockSize / 10000)) / 0.2
-		Back5Child.X = (cx / (WorldWidth * BlockSize / 10000)) / 0.1
-		Back6Child.X = (cx / (WorldWidth * BlockSize / 10000)) / 0.05
+		Back1Child.X = (cx / parallaxScale()) / 0.8
+		Back2Child.X = (cx / parallaxScale()) / 0.6
+		Back3Child.X = (cx / parallaxScale()) / 0.3
+		Back4Child.X = (cx / parallaxScale()) / 0.2
+		Back5Child.X = (cx / parallaxScale()) / 0.1
+		Back6Child.X = (cx / parallaxScale()) / 0.05
+
+		// Blend the backgrounds of the biomes around the player
+		updateParallax()
 	}
 
 	if ViewerEnabled {
@@ -308,20 +355,24 @@ func renderWorldScene(renderer *cmd.Renderer, inputs *input.Input) {
 func renderWorldInBounds(renderer *cmd.Renderer) {
 	for x := int(Player1.CenterX) - 50 - ScreenWidth/2; x < int(Player1.CenterX)+50+ScreenWidth/2; x += BlockSize {
 		for y := int(Player1.CenterY) - 50 - ScreenHeight/2; y < int(Player1.CenterY)+50+ScreenHeight/2; y += BlockSize {
-			if cpy := WorldMap.GetBackBlock(int(x/BlockSize), int(y/BlockSize)); cpy.ID != "00000" {
-				renderer.RenderCopy(NoCollisionChild, *cpy)
+			bx, by := int(x/BlockSize), int(y/BlockSize)
+			if WorldMap.GetBackBlockID(bx, by) != BlockEmpty {
+				renderer.RenderCopy(NoCollisionChild, *WorldMap.GetBackBlock(bx, by))
+			}
+			if WorldMap.GetNatureBlockID(bx, by) != BlockEmpty {
+				renderer.RenderCopy(NatureChild, *WorldMap.GetNatureBlock(bx, by))
 			}
-			if cpy := WorldMap.GetNatureBlock(int(x/BlockSize), int(y/BlockSize)); cpy.ID != "00000" {
-				renderer.RenderCopy(NatureChild, *cpy)
+			if liquid := WorldMap.GetLiquid(bx, by); liquid.Level > 0 {
+				renderLiquid(renderer, bx, by, liquid)
 			}
-			if cpy := WorldMap.GetWorldBlock(int(x/BlockSize), int(y/BlockSize)); cpy.ID != "00000" {
-				renderer.RenderCopy(WorldChild, *cpy)
+			if WorldMap.GetWorldBlockID(bx, by) != BlockEmpty {
+				renderer.RenderCopy(WorldChild, *WorldMap.GetWorldBlock(bx, by))
 			}
-			if cpy := WorldMap.GetGrassBlock(int(x/BlockSize), int(y/BlockSize)); cpy.ID != "00000" {
-				renderer.RenderCopy(GrassChild, *cpy)
+			if WorldMap.GetGrassBlockID(bx, by) != BlockEmpty {
+				renderer.RenderCopy(GrassChild, *WorldMap.GetGrassBlock(bx, by))
 			}
-			if cpy := WorldMap.GetLightBlock(int(x/BlockSize), int(y/BlockSize)); cpy.ID != "00000" {
-				renderer.RenderCopy(NoCollisionChild, *cpy)
+			if WorldMap.GetLightBlockID(bx, by) != BlockEmpty {
+				renderer.RenderCopy(NoCollisionChild, *WorldMap.GetLightBlock(bx, by))
 			}
 		}
 	}
@@ -330,8 +381,9 @@ func renderWorldInBounds(renderer *cmd.Renderer) {
 func renderFrontWorldInBounds(renderer *cmd.Renderer) {
 	for x := int(Player1.CenterX) - 50 - ScreenWidth/2; x < int(Player1.CenterX)+50+ScreenWidth/2; x += BlockSize {
 		for y := int(Player1.CenterY) - 50 - ScreenHeight/2; y < int(Player1.CenterY)+50+ScreenHeight/2; y += BlockSize {
-			if cpy := WorldMap.GetGrassBlock(int(x/BlockSize), int(y/BlockSize)); cpy.ID != "00000" {
-				renderer.RenderCopy(GrassChild, *cpy)
+			bx, by := int(x/BlockSize), int(y/BlockSize)
+			if WorldMap.GetGrassBlockID(bx, by) != BlockEmpty {
+				renderer.RenderCopy(GrassChild, *WorldMap.GetGrassBlock(bx, by))
 			}
 		}
 	}
@@ -342,3 +394,13 @@ func BlockDistance(x1, y1, x2, y2 float32) int {
 	dy := ((y1) - (y2)) * ((y1) - (y2))
 	return int(math.Sqrt(float64(dx+dy))) / BlockSize
 }
+
+// logInfo logs through the engine, or to stdout before it
+// is started, like in tests. hellion-gen always logs to stdout.
+func logInfo(text string) {
+	if Engine == nil {
+		log.Println(text)
+		return
+	}
+	Engine.Logger.Info(text)
+}
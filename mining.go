@@ -0,0 +1,141 @@
+//go:build !gen
+// +build !gen
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"rapidengine/child"
+	"rapidengine/cmd"
+	"rapidengine/geometry"
+	"rapidengine/material"
+)
+
+//  --------------------------------------------------
+//  Mining.go contains mining blocks, and the tools
+//  that mine them.
+//
+//  A block takes Durability * MiningTimeScale seconds
+//  to mine, divided by the Speed of the tool held. A
+//  block with a Tier above the tool's can't be mined
+//  with it at all. Progress belongs to the block under
+//  the cursor and starts over when the cursor moves to
+//  another one, while a crack overlay shows how far
+//  along it is.
+//  --------------------------------------------------
+
+// Tool textures are relative to this directory
+const ToolTextureDir = "./assets/tools"
+
+// Seconds per point of durability when mining by hand
+const MiningTimeScale = 0.25
+
+// Number of crack overlays, from a scratch to nearly broken
+const NumCrackStages = 4
+
+var CrackChild *child.Child2D
+var crackMaterials [NumCrackStages]*material.BasicMaterial
+
+func loadTools() {
+	for name, tool := range Tools {
+		Engine.TextureControl.NewTexture(filepath.Join(ToolTextureDir, tool.Texture), name, "pixel")
+		tool.Material = Engine.MaterialControl.NewBasicMaterial()
+		tool.Material.DiffuseLevel = 1
+		tool.Material.DiffuseMap = Engine.TextureControl.GetTexture(name)
+	}
+}
+
+func initializeCracks() {
+	for i := range crackMaterials {
+		name := fmt.Sprintf("crack%d", i+1)
+		Engine.TextureControl.NewTexture(fmt.Sprintf("./assets/blocks/cracks/%s.png", name), name, "pixel")
+		crackMaterials[i] = Engine.MaterialControl.NewBasicMaterial()
+		crackMaterials[i].DiffuseLevel = 1
+		crackMaterials[i].DiffuseMap = Engine.TextureControl.GetTexture(name)
+	}
+
+	CrackChild = Engine.ChildControl.NewChild2D()
+	CrackChild.AttachMesh(geometry.NewRectangle())
+	CrackChild.ScaleX = BlockSize
+	CrackChild.ScaleY = BlockSize
+	CrackChild.EnableCopying()
+}
+
+// heldTool returns the tool in the active hotbar slot, or the hand
+func (p *Player) heldTool() *Tool {
+	if tool := GetTool(p.HeldItem().Name); tool != nil {
+		return tool
+	}
+	return Hand
+}
+
+// minedBlock returns the block that mining a position
+// breaks, or nil if there is nothing to mine there
+func minedBlock(x, y int) *Block {
+	if id := WorldMap.GetWorldBlockID(x, y); id != BlockEmpty {
+		return GetBlockByID(id)
+	}
+	if name := WorldMap.GetNatureBlockName(x, y); treeBlocks[name] {
+		return GetBlock(name)
+	}
+	return nil
+}
+
+// miningTime returns the seconds a tool takes to mine a
+// block, and false if the tool can't mine it at all
+func miningTime(block *Block, tool *Tool) (float64, bool) {
+	if block.Tier > tool.Tier {
+		return 0, false
+	}
+	return block.Durability * MiningTimeScale / tool.Speed, true
+}
+
+// updateMining mines the block at a position while mining
+// is held, starting over whenever the position changes
+func (p *Player) updateMining(x, y int, mining bool, dt float64) {
+	if !mining || x != p.Lastsnapx || y != p.Lastsnapy {
+		p.CurrentMiningTimer = 0
+		p.Lastsnapx, p.Lastsnapy = x, y
+	}
+	p.MiningProgress = 0
+	if !mining {
+		return
+	}
+
+	block := minedBlock(x, y)
+	if block == nil {
+		p.CurrentMiningTimer = 0
+		return
+	}
+	duration, ok := miningTime(block, p.heldTool())
+	if !ok {
+		return
+	}
+
+	p.CurrentMiningTimer += dt
+	if p.CurrentMiningTimer >= duration {
+		destroyBlock(x, y)
+		p.CurrentMiningTimer = 0
+		return
+	}
+	p.MiningProgress = p.CurrentMiningTimer / duration
+}
+
+// renderMiningCrack draws the crack over the block being mined
+func renderMiningCrack(renderer *cmd.Renderer) {
+	if Player1.MiningProgress <= 0 {
+		return
+	}
+	stage := int(Player1.MiningProgress * NumCrackStages)
+	if stage >= NumCrackStages {
+		stage = NumCrackStages - 1
+	}
+	x, y := Player1.Lastsnapx, Player1.Lastsnapy
+	renderer.RenderCopy(CrackChild, child.ChildCopy{
+		X:        float32(x * BlockSize),
+		Y:        float32(y * BlockSize),
+		Material: crackMaterials[stage],
+		Darkness: WorldMap.GetDarkness(x, y),
+	})
+}
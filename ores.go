@@ -0,0 +1,108 @@
+package main
+
+import (
+	"math/rand"
+)
+
+//  --------------------------------------------------
+//  Ores.go contains the ore types and the ore pass.
+//
+//  Ores are placed as veins, each one a random walk
+//  that turns host blocks into ore. Depth is measured
+//  from the ground down, from 0 at the surface to 1 at
+//  the bottom of the world, so the richest ores only
+//  show up deep underground.
+//  --------------------------------------------------
+
+// OreType is an ore block and where its veins are placed
+type OreType struct {
+	Block string
+
+	// Block the ore replaces, other blocks are left alone
+	Host string
+
+	// Depth range of the veins, from 0 at the surface to 1 at the bottom
+	MinDepth float64
+	MaxDepth float64
+
+	// Number of blocks a vein walks over
+	MinVeinSize int
+	MaxVeinSize int
+
+	// Veins per 1000 columns of the world
+	Rarity float64
+}
+
+// OreTypes are all the ores generated in the world
+var OreTypes = []OreType{
+	{
+		Block:       "shithyril",
+		Host:        "stone",
+		MinDepth:    0.15,
+		MaxDepth:    1,
+		MinVeinSize: 8,
+		MaxVeinSize: 24,
+		Rarity:      120,
+	},
+	{
+		Block:       "denseShithyril",
+		Host:        "stone",
+		MinDepth:    0.6,
+		MaxDepth:    1,
+		MinVeinSize: 4,
+		MaxVeinSize: 12,
+		Rarity:      40,
+	},
+	{
+		Block:       "gravel",
+		Host:        "dirt",
+		MinDepth:    0,
+		MaxDepth:    0.3,
+		MinVeinSize: 10,
+		MaxVeinSize: 30,
+		Rarity:      60,
+	},
+}
+
+func generateOres(world *WorldTree, rng *rand.Rand) {
+	for _, ore := range OreTypes {
+		veins := int(ore.Rarity * float64(WorldWidth) / 1000)
+		for i := 0; i < veins; i++ {
+			x := rng.Intn(WorldWidth)
+
+			// Depth is relative to the ground of the column
+			top := int(float64(HeightMap[x]) * (1 - ore.MinDepth))
+			bottom := int(float64(HeightMap[x]) * (1 - ore.MaxDepth))
+			if top <= bottom {
+				continue
+			}
+			y := bottom + rng.Intn(top-bottom)
+
+			size := ore.MinVeinSize
+			if ore.MaxVeinSize > ore.MinVeinSize {
+				size += rng.Intn(ore.MaxVeinSize - ore.MinVeinSize + 1)
+			}
+			generateVein(world, rng, ore, x, y, size)
+		}
+	}
+}
+
+// generateVein walks from a block, turning the host blocks it passes into ore
+func generateVein(world *WorldTree, rng *rand.Rand, ore OreType, x, y, size int) {
+	for i := 0; i < size; i++ {
+		if isInWorld(x, y) && world.GetWorldBlockName(x, y) == ore.Host {
+			world.createWorldBlock(x, y, ore.Block)
+		}
+
+		switch rng.Intn(4) {
+		case 0:
+			x++
+		case 1:
+			x--
+		case 2:
+			y++
+		case 3:
+			y--
+		}
+	}
+}
@@ -1,3 +1,6 @@
+//go:build !gen
+// +build !gen
+
 package main
 
 import (
@@ -12,6 +15,15 @@ import (
 var Player1 Player
 
 var Player1Health *ui.ProgressBar
+var Player1Mana *ui.ProgressBar
+
+// PunchBox is the player's AttackBox without a weapon
+var PunchBox = AABB{
+	OffX:   0,
+	OffY:   45,
+	Width:  55,
+	Height: 60,
+}
 
 type Player struct {
 	God bool
@@ -38,24 +50,63 @@ type Player struct {
 	MaxHealth   float32
 	CurrentAnim string
 
+	// Spent by wands, see magic.go
+	Mana    float32
+	MaxMana float32
+
 	// Attack info
 	PunchDamage float32
 
+	// Added up from Equipment, see equipment.go
+	Stats Stats
+
+	// Weapon being swung, see weapons.go
+	Swing *Weapon
+
 	// Timers
-	Invincibility float64
-	PunchCooldown float64
+	Invincibility  float64
+	AttackCooldown float64
+	HitStun        float64
+	ManaRegenTimer float64
 
 	// Collision
 	Hitbox1   Hitbox
 	FullBox   AABB
 	AttackBox AABB
 
+	// Items carried, the first NumSlots are the hotbar
+	Inventory *Inventory
+
+	// Items worn, one slot per EquipHead etc.
+	Equipment *Inventory
+
+	// Mining (see mining.go)
 	CurrentMiningTimer float64
+	MiningProgress     float64
 	Lastsnapx          int
 	Lastsnapy          int
 	Dead               bool
 }
 
+// PlayerState is the part of the player kept in a save
+type PlayerState struct {
+	X  float32
+	Y  float32
+	VX float32
+	VY float32
+
+	Health    float32
+	MaxHealth float32
+	Money     int32
+	Dead      bool
+
+	// Every slot of the inventory, see Inventory
+	Inventory []ItemStack
+
+	// Every equipment slot, see EquipHead etc.
+	Equipment []ItemStack
+}
+
 func InitializePlayer() {
 	Engine.TextureControl.NewTexture("assets/player/idle/1.png", "p_i1", "pixel")
 	Engine.TextureControl.NewTexture("assets/player/idle/2.png", "p_i2", "pixel")
@@ -158,13 +209,19 @@ func InitializePlayer() {
 
 		Gravity: BaseGravity,
 
-		PunchDamage: 10,
+		PunchDamage: BasePunchDamage,
 
 		NumJumps:    1,
 		CurrentAnim: "idle",
 
-		Health:    100,
-		MaxHealth: 100,
+		Health:    BaseMaxHealth,
+		MaxHealth: BaseMaxHealth,
+
+		Mana:    BaseMaxMana,
+		MaxMana: BaseMaxMana,
+
+		Inventory: newStartingInventory(),
+		Equipment: NewInventory(NumEquipSlots),
 	}
 
 	if Player1.God {
@@ -181,17 +238,13 @@ func InitializePlayer() {
 	Player1.Hitbox1 = NewHitBox(original, 5)
 
 	Player1.FullBox = AABB{0, 0, 50, 120, 0, 0}
-	Player1.AttackBox = AABB{
-		OffX:   0,
-		OffY:   45,
-		Width:  55,
-		Height: 60,
-	}
+	Player1.AttackBox = PunchBox
 
 	V.AddBox(&Player1.Hitbox1)
 }
 
 func (p *Player) Update(inputs *input.Input) {
+	p.updateStats()
 	p.UpdateMovement(inputs)
 	p.UpdateAnimation()
 }
@@ -206,8 +259,18 @@ func (p *Player) UpdateMovement(inputs *input.Input) {
 	colChild.X = Player1.Hitbox1.X
 	colChild.Y = Player1.Hitbox1.Y*/
 
-	p.PlayerChild.X += p.PlayerChild.VX * -float32(Engine.Renderer.DeltaFrameTime)
-	p.PlayerChild.Y += p.PlayerChild.VY * float32(Engine.Renderer.DeltaFrameTime)
+	// Liquids slow the player down, and lava burns
+	drag := float32(1)
+	liquid := liquidAt(p.CenterX+p.Hitbox1.DAABB.Width/2, p.CenterY+p.Hitbox1.LAABB.Height/4)
+	if liquid != nil {
+		drag = liquid.Drag
+		if liquid.Damage > 0 {
+			p.Hit(liquid.Damage * PlayerInvincibility)
+		}
+	}
+
+	p.PlayerChild.X += p.PlayerChild.VX * -float32(Engine.Renderer.DeltaFrameTime) * drag
+	p.PlayerChild.Y += p.PlayerChild.VY * float32(Engine.Renderer.DeltaFrameTime) * drag
 
 	p.CenterX = p.PlayerChild.X + (p.PlayerChild.ScaleX / 2) - (p.Hitbox1.DAABB.Width / 2)
 	p.CenterY = p.PlayerChild.Y + (p.PlayerChild.ScaleY / 2) - (p.Hitbox1.LAABB.Height / 2)
@@ -218,6 +281,7 @@ func (p *Player) UpdateMovement(inputs *input.Input) {
 	p.Hitbox1.X = p.CenterX
 	p.Hitbox1.Y = p.CenterY
 
+	p.updateAttackBox()
 	flip := p.PlayerMaterial.Flipped
 	if flip == 0 {
 		flip = 1
@@ -243,9 +307,17 @@ func (p *Player) UpdateMovement(inputs *input.Input) {
 		Started--
 	}
 
+	// Swimming, sinking slowly and jumping off nothing
+	if liquid != nil {
+		p.NumJumps = 1
+		if p.PlayerChild.VY < -p.SpeedY {
+			p.PlayerChild.VY = -p.SpeedY
+		}
+	}
+
 	// Basic movement
 
-	if !p.Crouching && !p.Attacking && !p.Dead {
+	if !p.Crouching && !p.Attacking && !p.Dead && p.HitStun <= 0 {
 		if inputs.Keys["w"] && p.NumJumps > 0 {
 			p.PlayerChild.VY = p.SpeedY
 			p.PlayerMaterial.PlayAnimationOnce("jump")
@@ -291,10 +363,10 @@ func (p *Player) UpdateMovement(inputs *input.Input) {
 		p.PlayerChild.VY = 0
 	}
 
-	// Attacking
-	if inputs.Keys["p"] {
-		if p.PunchCooldown <= 0 {
-			p.Punch()
+	// Attacking, left click only attacks with a weapon and mines otherwise
+	if inputs.Keys["p"] || (inputs.LeftMouseButton && p.heldWeapon() != nil) {
+		if p.AttackCooldown <= 0 && p.HitStun <= 0 {
+			p.Attack()
 		}
 	}
 
@@ -302,14 +374,18 @@ func (p *Player) UpdateMovement(inputs *input.Input) {
 	if p.Invincibility > 0 {
 		p.Invincibility -= Engine.Renderer.DeltaFrameTime
 	}
-	if p.PunchCooldown > 0 {
-		p.PunchCooldown -= Engine.Renderer.DeltaFrameTime
+	if p.AttackCooldown > 0 {
+		p.AttackCooldown -= Engine.Renderer.DeltaFrameTime
 	}
+	if p.HitStun > 0 {
+		p.HitStun -= Engine.Renderer.DeltaFrameTime
+	}
+	p.updateMana(Engine.Renderer.DeltaFrameTime)
 }
 
 func (p *Player) Punch() {
 	p.PlayerMaterial.PlayAnimationOnceCallback("punch", p.DoneAttack, p.PunchHitFrame)
-	p.PunchCooldown = 0.5
+	p.AttackCooldown = 0.5
 	p.CurrentAnim = "punch"
 	p.Attacking = true
 	p.PlayerChild.VX = 0
@@ -317,26 +393,33 @@ func (p *Player) Punch() {
 
 func (p *Player) PunchHitFrame() {
 	if enemy := EM.CheckPlayerCollision(); enemy != nil {
-		enemy.Damage(p.PunchDamage)
+		hitEnemy(enemy, p.PunchDamage, p.CenterX+p.Hitbox1.DAABB.Width/2, PunchKnockback, false)
 	}
 }
 
-func (p *Player) Hit(damage float32) {
+// Seconds the player can't be hit again after being hit
+const PlayerInvincibility = 0.75
+
+// Hit damages the player, returning false if they were still invincible
+func (p *Player) Hit(damage float32) bool {
 	if p.Invincibility > 0 {
-		return
+		return false
 	} else {
-		p.Invincibility = 0.75
+		p.Invincibility = PlayerInvincibility
 	}
 
 	Engine.Renderer.MainCamera.Shake(0.3, 0.01)
 
+	damage = p.damageTaken(damage)
 	p.Health -= damage
+	showDamageNumber(&PlayerDamageNumbers, damage, p.CenterX+p.Hitbox1.DAABB.Width/2, p.CenterY+p.Hitbox1.LAABB.Height)
 	if p.Health <= 0 {
 		p.Dead = true
 		RespawnScene.Activate()
 	}
 
 	fmt.Printf("Player hit! Health: %v\n", p.Health)
+	return true
 }
 
 func (p *Player) DoneAttack() {
@@ -344,6 +427,8 @@ func (p *Player) DoneAttack() {
 }
 
 func (p *Player) UpdateAnimation() {
+	flash(p.PlayerMaterial, p.Invincibility)
+
 	if p.PlayerChild.VX > 0 && p.NumJumps > 0 && p.CurrentAnim != "walk" && !p.Crouching && !p.Attacking {
 		p.PlayerMaterial.PlayAnimation("walk")
 		p.CurrentAnim = "walk"
@@ -376,6 +461,135 @@ func (p *Player) CheckEnemyCollision(enemy Enemy) bool {
 func (p *Player) Respawn() {
 	p.Dead = false
 	p.Health = p.MaxHealth
+	p.Mana = p.MaxMana
 	p.PlayerChild.SetPosition(float32(WorldWidth*BlockSize/2), float32((HeightMap[WorldWidth/2]+50)*BlockSize))
 	RespawnScene.Deactivate()
 }
+
+// Reset gives the player a fresh start, for when a different world is loaded
+func (p *Player) Reset() {
+	p.PlayerChild.VX = 0
+	p.PlayerChild.VY = 0
+
+	p.Inventory = newStartingInventory()
+	p.Equipment = NewInventory(NumEquipSlots)
+	p.updateStats()
+
+	p.Dead = false
+	p.Health = p.MaxHealth
+	p.Mana = p.MaxMana
+	p.Money = 0
+	p.Swing = nil
+
+	p.Invincibility = 0
+	p.AttackCooldown = 0
+	p.HitStun = 0
+	p.ManaRegenTimer = 0
+	p.CurrentMiningTimer = 0
+	p.MiningProgress = 0
+
+	RespawnScene.Deactivate()
+}
+
+// newStartingInventory returns the inventory a new player starts with
+func newStartingInventory() *Inventory {
+	inv := NewInventory(InventorySize)
+	inv.Add("woodPickaxe", 1)
+	inv.Add("torch", 10)
+	return inv
+}
+
+// GiveItem adds items to the player, returning how many they took
+func (p *Player) GiveItem(name string, count int32) int32 {
+	return p.Inventory.Add(name, count)
+}
+
+// HeldItem returns the stack in the active hotbar slot
+func (p *Player) HeldItem() ItemStack {
+	return p.Inventory.Slot(ActiveItem)
+}
+
+// PlaceHeldBlock places one of the blocks in the active hotbar
+// slot at a position, returning false if nothing was placed
+func (p *Player) PlaceHeldBlock(x, y int) bool {
+	held := p.HeldItem()
+	if held.Empty() || GetBlock(held.Name) == nil || !placeBlock(x, y, held.Name) {
+		return false
+	}
+	p.Inventory.TakeFromSlot(ActiveItem, 1)
+	return true
+}
+
+func (p *Player) SetPosition(x, y float32) {
+	p.PlayerChild.SetPosition(x, y)
+	p.CenterX = p.PlayerChild.X + (p.PlayerChild.ScaleX / 2) - (p.Hitbox1.DAABB.Width / 2)
+	p.CenterY = p.PlayerChild.Y + (p.PlayerChild.ScaleY / 2) - (p.Hitbox1.LAABB.Height / 2)
+}
+
+func (p *Player) SaveState() PlayerState {
+	return PlayerState{
+		X:  p.PlayerChild.X,
+		Y:  p.PlayerChild.Y,
+		VX: p.PlayerChild.VX,
+		VY: p.PlayerChild.VY,
+
+		Health:    p.Health,
+		MaxHealth: p.MaxHealth,
+		Money:     int32(p.Money),
+		Dead:      p.Dead,
+
+		Inventory: append([]ItemStack(nil), p.Inventory.Slots...),
+		Equipment: append([]ItemStack(nil), p.Equipment.Slots...),
+	}
+}
+
+func (p *Player) LoadState(state PlayerState) {
+	p.SetPosition(state.X, state.Y)
+	p.PlayerChild.VX = state.VX
+	p.PlayerChild.VY = state.VY
+
+	p.Health = state.Health
+	p.MaxHealth = state.MaxHealth
+	p.Money = int(state.Money)
+	p.Dead = state.Dead
+
+	// Mana isn't saved, it comes back quickly anyway
+	p.Mana = p.MaxMana
+	p.ManaRegenTimer = 0
+
+	p.Inventory.Clear()
+	for i, stack := range state.Inventory {
+		if i >= len(p.Inventory.Slots) || stack.Empty() {
+			continue
+		}
+		if !isItem(stack.Name) {
+			logInfo("Skipping unknown item " + stack.Name)
+			continue
+		}
+		if max := maxStack(stack.Name); stack.Count > max {
+			stack.Count = max
+		}
+		p.Inventory.Slots[i] = stack
+	}
+
+	p.Equipment.Clear()
+	for i, stack := range state.Equipment {
+		if i >= len(p.Equipment.Slots) || stack.Empty() {
+			continue
+		}
+		if !canEquip(i, stack.Name) {
+			logInfo("Skipping unknown equipment " + stack.Name)
+			continue
+		}
+		stack.Count = 1
+		p.Equipment.Slots[i] = stack
+	}
+	p.updateStats()
+	UpdateHotBar()
+
+	if p.Dead {
+		RespawnScene.Activate()
+	} else {
+		RespawnScene.Deactivate()
+	}
+}
@@ -0,0 +1,212 @@
+//go:build !gen
+// +build !gen
+
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"rapidengine/child"
+	"rapidengine/cmd"
+	"rapidengine/geometry"
+	"rapidengine/material"
+)
+
+//  --------------------------------------------------
+//  Projectiles.go contains projectiles, things that fly
+//  through the world and hurt enemies.
+//
+//  A projectile flies in a straight line, pulled down by
+//  gravity times its GravityScale, until it hits a block,
+//  runs out of lifetime, or hits one more enemy than its
+//  Pierce lets it fly through. Each enemy is only hit
+//  once by the same projectile. Projectiles aren't saved.
+//  --------------------------------------------------
+
+// ProjectileKind is a kind of projectile, which every
+// projectile of that kind shares
+type ProjectileKind struct {
+	Name    string
+	Texture string
+
+	// Width and height in pixels
+	Size float32
+
+	Damage    float32
+	Speed     float32
+	Knockback float32
+
+	// Fraction of BaseGravity pulling the projectile down
+	GravityScale float32
+
+	// Seconds before the projectile disappears
+	Lifetime float64
+
+	// Number of enemies the projectile flies through before it stops
+	Pierce int
+
+	Material *material.BasicMaterial
+	Child    *child.Child2D
+}
+
+// Projectile is a projectile flying through the world
+type Projectile struct {
+	Kind *ProjectileKind
+
+	// Position of the bottom left corner in pixels
+	X  float32
+	Y  float32
+	VX float32
+	VY float32
+
+	// Seconds since the projectile was spawned
+	Age float64
+
+	// Damage done to every enemy hit
+	Damage float32
+
+	// Enemies the projectile can still fly through
+	Pierce int
+
+	// Enemies already hit
+	hit map[Enemy]bool
+}
+
+// ProjectileKinds are all the kinds of projectile, by name
+var ProjectileKinds = map[string]*ProjectileKind{
+	"iceShard":  {Name: "iceShard", Texture: "iceShard.png", Size: 16, Damage: 12, Speed: 700, Knockback: 100, GravityScale: 0.3, Lifetime: 1.5, Pierce: 0},
+	"frostBolt": {Name: "frostBolt", Texture: "frostBolt.png", Size: 24, Damage: 22, Speed: 800, Knockback: 200, GravityScale: 0, Lifetime: 1.2, Pierce: 1},
+	"iceLance":  {Name: "iceLance", Texture: "iceLance.png", Size: 32, Damage: 35, Speed: 1000, Knockback: 300, GravityScale: 0, Lifetime: 1, Pierce: 3},
+}
+
+// Projectile textures are relative to this directory
+const ProjectileTextureDir = "./assets/projectiles"
+
+// Projectiles are all the projectiles flying through the world
+var Projectiles []*Projectile
+
+func loadProjectiles() {
+	for name, kind := range ProjectileKinds {
+		Engine.TextureControl.NewTexture(filepath.Join(ProjectileTextureDir, kind.Texture), name, "pixel")
+		kind.Material = Engine.MaterialControl.NewBasicMaterial()
+		kind.Material.DiffuseLevel = 1
+		kind.Material.DiffuseMap = Engine.TextureControl.GetTexture(name)
+
+		kind.Child = Engine.ChildControl.NewChild2D()
+		kind.Child.AttachMesh(geometry.NewRectangle())
+		kind.Child.ScaleX = kind.Size
+		kind.Child.ScaleY = kind.Size
+		kind.Child.EnableCopying()
+	}
+}
+
+// GetProjectileKind returns the kind of projectile with a name, or nil if there is none
+func GetProjectileKind(name string) *ProjectileKind {
+	return ProjectileKinds[name]
+}
+
+// spawnProjectile fires a projectile from a position in pixels
+// towards another position
+func spawnProjectile(kind *ProjectileKind, x, y, targetX, targetY float32) *Projectile {
+	dx, dy := targetX-x, targetY-y
+	dist := float32(math.Sqrt(float64(dx*dx + dy*dy)))
+	if dist == 0 {
+		dx, dist = 1, 1
+	}
+
+	p := &Projectile{
+		Kind:   kind,
+		X:      x - kind.Size/2,
+		Y:      y - kind.Size/2,
+		VX:     dx / dist * kind.Speed,
+		VY:     dy / dist * kind.Speed,
+		Damage: kind.Damage,
+		Pierce: kind.Pierce,
+		hit:    make(map[Enemy]bool),
+	}
+	Projectiles = append(Projectiles, p)
+	return p
+}
+
+// projectileHitbox returns the hitbox of a projectile of a size
+func projectileHitbox(size float32) Hitbox {
+	return Hitbox{
+		LAABB: AABB{X: 0, Y: 1, Width: 1, Height: size - 2},
+		RAABB: AABB{X: size - 1, Y: 1, Width: 1, Height: size - 2},
+		UAABB: AABB{X: 1, Y: size - 1, Width: size - 2, Height: 1},
+		DAABB: AABB{X: 1, Y: 0, Width: size - 2, Height: 1},
+	}
+}
+
+// updateProjectiles moves every projectile, and removes the ones that are gone
+func updateProjectiles(dt float32) {
+	projectiles := Projectiles[:0]
+	for _, p := range Projectiles {
+		if !p.update(dt) {
+			projectiles = append(projectiles, p)
+		}
+	}
+	for i := len(projectiles); i < len(Projectiles); i++ {
+		Projectiles[i] = nil
+	}
+	Projectiles = projectiles
+}
+
+// update moves a projectile and hits what it runs into,
+// returning true once it is gone
+func (p *Projectile) update(dt float32) bool {
+	p.Age += float64(dt)
+	if p.Age > p.Kind.Lifetime {
+		return true
+	}
+
+	p.VY -= BaseGravity * p.Kind.GravityScale * dt
+	if p.VY < -MaxFallSpeed {
+		p.VY = -MaxFallSpeed
+	}
+
+	top, left, bottom, right, _, _ := CheckWorldCollision(projectileHitbox(p.Kind.Size), p.VX, p.VY, p.X, p.Y)
+	if top || left || bottom || right {
+		return true
+	}
+
+	p.X += p.VX * dt
+	p.Y += p.VY * dt
+
+	return p.hitEnemies()
+}
+
+// hitEnemies hits the enemies the projectile touches, returning
+// true once it can't fly through any more
+func (p *Projectile) hitEnemies() bool {
+	box := AABB{X: p.X, Y: p.Y, Width: p.Kind.Size, Height: p.Kind.Size}
+	for _, enemy := range EM.AllEnemies {
+		c := enemy.GetCommon()
+		if c.Dead || p.hit[enemy] {
+			continue
+		}
+		if !box.CheckCollision(hitboxBounds(c.Hitbox1), 0, 0) {
+			continue
+		}
+
+		p.hit[enemy] = true
+		hitEnemy(enemy, p.Damage, p.X+p.Kind.Size/2-p.VX, p.Kind.Knockback, false)
+
+		if p.Pierce <= 0 {
+			return true
+		}
+		p.Pierce--
+	}
+	return false
+}
+
+func renderProjectiles(renderer *cmd.Renderer) {
+	for _, p := range Projectiles {
+		renderer.RenderCopy(p.Kind.Child, child.ChildCopy{
+			X:        p.X,
+			Y:        p.Y,
+			Material: p.Kind.Material,
+			Darkness: WorldMap.GetDarkness(int((p.X+p.Kind.Size/2)/BlockSize), int((p.Y+p.Kind.Size/2)/BlockSize)),
+		})
+	}
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+//  --------------------------------------------------
+//  Region.go streams the chunks of the WorldTree in
+//  and out of compressed region files.
+//
+//  Each region file holds RegionSize x RegionSize chunks
+//  as zlib-compressed chunk payloads (see worldfile.go),
+//  behind a table with the offset and length of every
+//  chunk. Chunks are loaded on demand, and chunks far
+//  away from the player are evicted unless they have
+//  changes that haven't been saved yet.
+//  --------------------------------------------------
+
+// RegionFileMagic is the first four bytes of every region file
+var RegionFileMagic = [4]byte{'H', 'L', 'N', 'R'}
+
+// RegionFormatVersion is bumped whenever the region layout changes
+const RegionFormatVersion = 1
+
+// RegionSize is the width and height of a region in chunks
+const RegionSize = 8
+
+// Chunks within ChunkLoadRadius of the player are kept loaded,
+// chunks further than ChunkUnloadRadius away are evicted
+const ChunkLoadRadius = 2
+const ChunkUnloadRadius = 4
+
+type regionHeader struct {
+	Magic   [4]byte
+	Version uint16
+}
+
+type regionEntry struct {
+	Offset uint32
+	Length uint32
+}
+
+type regionTable [RegionSize * RegionSize]regionEntry
+
+var regionDataStart = int64(binary.Size(regionHeader{}) + binary.Size(regionTable{}))
+
+// regionSource is the region directory chunks are loaded from,
+// and the format version they were written in. A full save swaps
+// in a new directory from its goroutine while the game may be
+// loading chunks, so both only happen while holding mu.
+type regionSource struct {
+	mu sync.Mutex
+
+	// Empty if the world has never been saved
+	dir string
+
+	// Older chunks are rewritten on save
+	version uint16
+}
+
+func (r *regionSource) get() (dir string, version uint16) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dir, r.version
+}
+
+func (r *regionSource) set(dir string, version uint16) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dir = dir
+	r.version = version
+}
+
+// readChunk reads the payload of a chunk and the version it is in,
+// returning a nil payload if it has never been saved
+func (r *regionSource) readChunk(pos ChunkPos) ([]byte, uint16, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.dir == "" {
+		return nil, r.version, nil
+	}
+	payload, err := readRegionChunk(r.dir, pos)
+	return payload, r.version, err
+}
+
+// swap replaces the directory at dest with a fully written one
+// in the current format, and loads chunks from it from then on
+func (r *regionSource) swap(src, dest string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := replaceDir(src, dest); err != nil {
+		return err
+	}
+	r.dir = dest
+	r.version = WorldFormatVersion
+	return nil
+}
+
+//  --------------------------------------------------
+//  Streaming
+//  --------------------------------------------------
+
+// UpdateChunks makes sure the chunks around a position
+// are loaded and evicts unchanged chunks far away from it
+func (tree *WorldTree) UpdateChunks(px, py float32) {
+	pcx := int(px/BlockSize) / ChunkSize
+	pcy := int(py/BlockSize) / ChunkSize
+
+	for cx := pcx - ChunkLoadRadius; cx <= pcx+ChunkLoadRadius; cx++ {
+		for cy := pcy - ChunkLoadRadius; cy <= pcy+ChunkLoadRadius; cy++ {
+			if isInWorld(cx*ChunkSize, cy*ChunkSize) {
+				tree.chunkAt(cx*ChunkSize, cy*ChunkSize)
+			}
+		}
+	}
+
+	// Region files may be behind while a save is running
+	if tree.saving != nil {
+		return
+	}
+
+	for pos, c := range tree.chunks {
+		if c.dirty {
+			continue
+		}
+		if abs(pos.X-pcx) > ChunkUnloadRadius || abs(pos.Y-pcy) > ChunkUnloadRadius {
+			delete(tree.chunks, pos)
+			if tree.lastChunk == c {
+				tree.lastChunk = nil
+			}
+		}
+	}
+}
+
+// loadChunk reads a chunk from its region file, or creates
+// an empty one if it has never been saved. A chunk that fails
+// to load is marked as corrupt, and so is the world.
+func (tree *WorldTree) loadChunk(pos ChunkPos) *Chunk {
+	c := newEmptyChunk()
+	tree.chunks[pos] = c
+
+	payload, version, err := tree.regions.readChunk(pos)
+	if err == nil && payload != nil {
+		var t *chunkTiles
+		if t, err = decodeChunk(payload, version); err == nil {
+			tree.pasteChunk(t)
+		}
+	}
+	if err != nil {
+		logInfo(fmt.Sprintf("Failed to load chunk %d,%d: %v", pos.X, pos.Y, err))
+		c = newEmptyChunk()
+		c.corrupt = true
+		tree.chunks[pos] = c
+		if tree.corrupt == nil {
+			tree.corrupt = fmt.Errorf("chunk %d,%d is damaged: %v", pos.X, pos.Y, err)
+		}
+	}
+
+	c.dirty = false
+	return c
+}
+
+//  --------------------------------------------------
+//  Saving
+//  --------------------------------------------------
+
+// writeRegion rewrites a region file, replacing the chunks given by
+// their region index and keeping the rest already in the file
+func writeRegion(dir string, rpos ChunkPos, payloads map[int][]byte) error {
+	path := regionPath(dir, rpos)
+
+	var oldTable regionTable
+	var oldData []byte
+	if f, err := os.Open(path); err == nil {
+		oldTable, err = readRegionTable(f)
+		if err == nil {
+			oldData, err = ioutil.ReadAll(f)
+		}
+		f.Close()
+		if err != nil {
+			logInfo(fmt.Sprintf("Replacing damaged region %s: %v", path, err))
+			oldTable = regionTable{}
+			oldData = nil
+		}
+	}
+
+	blobs := make(map[int][]byte)
+	for i, payload := range payloads {
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		zw.Write(payload)
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		blobs[i] = compressed.Bytes()
+	}
+
+	var table regionTable
+	var data bytes.Buffer
+	for i := range table {
+		blob, ok := blobs[i]
+		if !ok {
+			old := oldTable[i]
+			if old.Length == 0 || int(old.Offset)+int(old.Length) > len(oldData) {
+				continue
+			}
+			blob = oldData[old.Offset : old.Offset+old.Length]
+		}
+		table[i] = regionEntry{Offset: uint32(data.Len()), Length: uint32(len(blob))}
+		data.Write(blob)
+	}
+
+	return writeFileAtomic(path, func(w io.Writer) error {
+		if err := binary.Write(w, byteOrder, regionHeader{RegionFileMagic, RegionFormatVersion}); err != nil {
+			return err
+		}
+		if err := binary.Write(w, byteOrder, &table); err != nil {
+			return err
+		}
+		_, err := w.Write(data.Bytes())
+		return err
+	})
+}
+
+//  --------------------------------------------------
+//  Reading
+//  --------------------------------------------------
+
+// readRegionChunk returns the uncompressed payload of a chunk,
+// or nil if the chunk isn't in its region file
+func readRegionChunk(dir string, pos ChunkPos) ([]byte, error) {
+	f, err := os.Open(regionPath(dir, ChunkPos{pos.X / RegionSize, pos.Y / RegionSize}))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	table, err := readRegionTable(f)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := table[regionIndex(pos)]
+	if entry.Length == 0 {
+		return nil, nil
+	}
+	return readRegionEntry(f, entry, pos)
+}
+
+// readRegionEntry reads and uncompresses the chunk at an entry of
+// the table of a region file, checking that it lies within the file
+// and is stored at the position it belongs to
+func readRegionEntry(f *os.File, entry regionEntry, pos ChunkPos) ([]byte, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if regionDataStart+int64(entry.Offset)+int64(entry.Length) > info.Size() {
+		return nil, errors.New("chunk lies past the end of the region file")
+	}
+
+	compressed := make([]byte, entry.Length)
+	if _, err := f.ReadAt(compressed, regionDataStart+int64(entry.Offset)); err != nil {
+		return nil, err
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	payload, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(payload) < 4 ||
+		int(byteOrder.Uint16(payload)) != pos.X ||
+		int(byteOrder.Uint16(payload[2:])) != pos.Y {
+		return nil, errors.New("chunk is stored at the wrong position")
+	}
+	return payload, nil
+}
+
+func readRegionTable(f *os.File) (regionTable, error) {
+	var header regionHeader
+	var table regionTable
+	if err := binary.Read(f, byteOrder, &header); err != nil {
+		return table, err
+	}
+	if header.Magic != RegionFileMagic {
+		return table, errors.New("not a region file")
+	}
+	if header.Version != RegionFormatVersion {
+		return table, fmt.Errorf("unsupported region format version %d", header.Version)
+	}
+	err := binary.Read(f, byteOrder, &table)
+	return table, err
+}
+
+//  --------------------------------------------------
+//  Helpers
+//  --------------------------------------------------
+
+// regionDirFor returns the region directory of a world file,
+// which is the world file path without its extension
+func regionDirFor(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path))
+}
+
+func regionPath(dir string, rpos ChunkPos) string {
+	return filepath.Join(dir, fmt.Sprintf("r.%d.%d.hlr", rpos.X, rpos.Y))
+}
+
+func regionIndex(pos ChunkPos) int {
+	return (pos.X%RegionSize)*RegionSize + pos.Y%RegionSize
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,60 @@
+//go:build !gen
+// +build !gen
+
+package main
+
+import (
+	"fmt"
+	"rapidengine/child"
+	"rapidengine/material"
+)
+
+//  --------------------------------------------------
+//  Render.go contains the engine types the world is
+//  drawn with, and the materials of every block.
+//
+//  The world only refers to them by the names below.
+//  hellion-gen never draws the world and builds
+//  without the engine, so it gives the same names
+//  plain definitions instead (see hellion_gen.go).
+//  --------------------------------------------------
+
+// renderMaterial is the material a block, liquid or item is drawn with
+type renderMaterial = material.BasicMaterial
+
+// blockCopy is the render copy of a block, see BlockNode
+type blockCopy = child.ChildCopy
+
+// loadBlockMaterials loads the textures of every block and creates
+// their materials, including one per orientation for blocks with
+// orientations. Blocks must be loaded first, see loadBlocks.
+func loadBlockMaterials() {
+	for variant := 0; variant < NumOrientVariants; variant++ {
+		for orient := Orientation(0); orient < NumOrientations; orient++ {
+			Engine.TextureControl.NewTexture(
+				fmt.Sprintf("./assets/blocks/transparency/%s.png", orient),
+				fmt.Sprintf("%v%s", variant, orient),
+				"pixel",
+			)
+		}
+	}
+
+	for _, block := range BlocksByID {
+		if block == nil || block.Texture == "" {
+			continue
+		}
+		Engine.TextureControl.NewTexture(blockTexturePath(block.Texture), block.Name, "pixel")
+		block.Material = Engine.MaterialControl.NewBasicMaterial()
+		block.Material.DiffuseLevel = 1
+		block.Material.DiffuseMap = Engine.TextureControl.GetTexture(block.Name)
+
+		if block.OrientEnabled {
+			for orient := Orientation(0); orient < NumOrientations; orient++ {
+				m := *block.Material
+				m.AlphaMap = Engine.TextureControl.GetTexture(fmt.Sprintf("%v%s", block.OrientVariation, orient))
+				m.AlphaMapLevel = 1
+				block.Orientations[orient] = &m
+			}
+		}
+	}
+}
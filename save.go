@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+)
+
+//  --------------------------------------------------
+//  Save.go writes worlds to disk, without stopping
+//  the game while it does.
+//
+//  A save happens in two steps. First a snapshot of
+//  everything that changed is taken on the game thread:
+//  the tiles of dirty chunks are copied right away, so
+//  mining and placing blocks afterwards can't touch what
+//  is being written. Then the snapshot is encoded and
+//  written out, on a background goroutine for autosaves.
+//  Chunks a full save needs that aren't loaded are read
+//  from the previous region files by the writer as well.
+//
+//  While a save is running no chunks are evicted, since
+//  their region files may not be up to date yet. Chunks
+//  still load during a full save: they come from the old
+//  region directory until the new one is swapped in, and
+//  the swap and loads take turns (see regionSource). A
+//  world with a chunk that failed to load is never saved,
+//  so the damaged save stays on disk to be restored.
+//  --------------------------------------------------
+
+// worldSnapshot holds everything a save writes,
+// copied out of the game when the save started
+type worldSnapshot struct {
+	path      string
+	regionDir string
+
+	// Set for autosaves, which only back up every AutosaveBackupInterval
+	autosave bool
+
+	// Set when the region directory doesn't back the world
+	// yet, or holds chunks in an older format, so every
+	// chunk is written into a fresh one
+	full bool
+
+	// Where a full save reads the chunks that aren't
+	// loaded from, and the format they are in
+	sourceDir     string
+	sourceVersion uint16
+
+	// Region files of the world, which a full save swaps
+	// its new directory into once it is written
+	regions *regionSource
+
+	header    WorldHeader
+	heightMap []int32
+	biomeMap  []uint8
+	entities  worldEntities
+
+	// Tiles of the chunks copied out of the game
+	chunks map[ChunkPos]*chunkTiles
+}
+
+// pendingSave is a save running in the background
+type pendingSave struct {
+	snapshot *worldSnapshot
+	done     chan error
+}
+
+//  --------------------------------------------------
+//  Saving
+//  --------------------------------------------------
+
+// WriteToFile saves the world in the binary format (see worldfile.go),
+// with its chunks in region files next to it (see region.go). The
+// previous save is backed up first and every file is replaced
+// atomically (see backup.go). Blocks until the save is done.
+func (tree *WorldTree) WriteToFile(path string) error {
+	tree.WaitForSave()
+	if err := tree.checkSave(); err != nil {
+		return err
+	}
+
+	s := tree.snapshot(path)
+	err := s.write()
+	tree.finishSave(s, err)
+	return err
+}
+
+// SaveInBackground starts saving the world on another goroutine.
+// If a save is already running, this one starts once it is done.
+// Returns an error if the world can't be saved at all.
+func (tree *WorldTree) SaveInBackground(path string) error {
+	if err := tree.checkSave(); err != nil {
+		tree.finishSave(nil, err)
+		return err
+	}
+	if tree.saving != nil {
+		tree.queuedSave = path
+		return nil
+	}
+	tree.saveInBackground(path, false)
+	return nil
+}
+
+func (tree *WorldTree) saveInBackground(path string, autosave bool) bool {
+	if tree.saving != nil {
+		return false
+	}
+	if err := tree.checkSave(); err != nil {
+		tree.finishSave(nil, err)
+		return false
+	}
+
+	save := &pendingSave{
+		snapshot: tree.snapshot(path),
+		done:     make(chan error, 1),
+	}
+	save.snapshot.autosave = autosave
+	tree.saving = save
+
+	go func() {
+		save.done <- save.snapshot.write()
+	}()
+	return true
+}
+
+// IsSaving returns whether a background save is running or queued
+func (tree *WorldTree) IsSaving() bool {
+	return tree.saving != nil || tree.queuedSave != ""
+}
+
+// SaveFailedSince returns whether the last save failed less than d ago
+func (tree *WorldTree) SaveFailedSince(d time.Duration) bool {
+	return !tree.saveFailed.IsZero() && time.Since(tree.saveFailed) < d
+}
+
+// WaitForSave blocks until the background save, if any, is done,
+// then runs the queued save, if any
+func (tree *WorldTree) WaitForSave() {
+	if save := tree.saving; save != nil {
+		tree.saving = nil
+		tree.finishSave(save.snapshot, <-save.done)
+	}
+
+	if path := tree.queuedSave; path != "" {
+		tree.queuedSave = ""
+		if err := tree.checkSave(); err != nil {
+			tree.finishSave(nil, err)
+			return
+		}
+		s := tree.snapshot(path)
+		tree.finishSave(s, s.write())
+	}
+}
+
+// UpdateSave finishes background saves and starts an autosave
+// every AutosaveInterval, unless autosave is false (while the
+// player is dead). Called every frame.
+func (tree *WorldTree) UpdateSave(path string, autosave bool) {
+	if save := tree.saving; save != nil {
+		select {
+		case err := <-save.done:
+			tree.saving = nil
+			tree.finishSave(save.snapshot, err)
+		default:
+		}
+	}
+
+	if path := tree.queuedSave; path != "" && tree.saving == nil {
+		tree.queuedSave = ""
+		tree.SaveInBackground(path)
+	}
+
+	if AutosaveInterval > 0 && time.Since(tree.lastSave) >= AutosaveInterval && autosave && tree.corrupt == nil {
+		tree.saveInBackground(path, true)
+	}
+}
+
+// needsFullSave returns whether saving to path has to write every
+// chunk, since the region directory doesn't back the world yet or
+// holds chunks in an older format
+func (tree *WorldTree) needsFullSave(path string) bool {
+	dir, version := tree.regions.get()
+	return dir != regionDirFor(path) || version != WorldFormatVersion
+}
+
+// checkSave returns an error if the world is damaged and mustn't be saved
+func (tree *WorldTree) checkSave() error {
+	if tree.corrupt != nil {
+		return fmt.Errorf("not saving damaged world: %v", tree.corrupt)
+	}
+	return nil
+}
+
+// snapshot copies everything that needs saving out of the
+// game. checkSave has to have passed first.
+func (tree *WorldTree) snapshot(path string) *worldSnapshot {
+	s := &worldSnapshot{
+		path:      path,
+		regionDir: regionDirFor(path),
+		full:      tree.needsFullSave(path),
+		regions:   tree.regions,
+		header:    newWorldHeader(),
+		heightMap: make([]int32, WorldWidth),
+		chunks:    make(map[ChunkPos]*chunkTiles),
+	}
+	s.sourceDir, s.sourceVersion = tree.regions.get()
+
+	for x := 0; x < WorldWidth; x++ {
+		s.heightMap[x] = int32(HeightMap[x])
+	}
+	s.biomeMap = append([]uint8(nil), BiomeMap...)
+
+	s.entities = copyEntities()
+
+	for pos, c := range tree.chunks {
+		if !s.full && !c.dirty {
+			continue
+		}
+		s.chunks[pos] = tree.copyChunk(pos)
+		c.dirty = false
+	}
+
+	tree.lastSave = time.Now()
+	return s
+}
+
+// finishSave is run on the game thread once a snapshot is written,
+// or with a nil snapshot if none could be taken. If the save failed
+// its chunks are marked as changed again, so the next save retries them.
+func (tree *WorldTree) finishSave(s *worldSnapshot, err error) {
+	if err == nil {
+		tree.saveFailed = time.Time{}
+		return
+	}
+	logInfo("Failed to save world: " + err.Error())
+	tree.saveFailed = time.Now()
+	if s == nil {
+		return
+	}
+	for pos := range s.chunks {
+		if c, ok := tree.chunks[pos]; ok {
+			c.dirty = true
+		}
+	}
+}
+
+//  --------------------------------------------------
+//  Writing
+//  --------------------------------------------------
+
+// write backs up the previous save, then writes the region
+// files and the world file. Only touches the snapshot and
+// the disk, so it is safe to run off the game thread.
+func (s *worldSnapshot) write() error {
+	if !s.autosave || backupAge(s.path) >= AutosaveBackupInterval {
+		if err := backupWorld(s.path); err != nil {
+			return fmt.Errorf("backing up world: %v", err)
+		}
+	}
+
+	if err := s.writeRegions(); err != nil {
+		if s.full {
+			os.RemoveAll(tempDirFor(s.regionDir))
+		}
+		return err
+	}
+
+	if err := writeFileAtomic(s.path, s.encode); err != nil {
+		return err
+	}
+	if s.full {
+		return os.RemoveAll(oldDirFor(s.regionDir))
+	}
+	return nil
+}
+
+// writeRegions writes the region files. A full save writes them
+// into a fresh directory, which only replaces the old one once
+// every region in it is on disk.
+func (s *worldSnapshot) writeRegions() error {
+	dir := s.regionDir
+	if s.full {
+		dir = tempDirFor(s.regionDir)
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	regions := make(map[ChunkPos][]ChunkPos)
+	addChunk := func(pos ChunkPos) {
+		rpos := ChunkPos{pos.X / RegionSize, pos.Y / RegionSize}
+		regions[rpos] = append(regions[rpos], pos)
+	}
+	for pos := range s.chunks {
+		addChunk(pos)
+	}
+	if s.full && s.sourceDir != "" {
+		chunksX, chunksY := chunkCount()
+		for cx := 0; cx < chunksX; cx++ {
+			for cy := 0; cy < chunksY; cy++ {
+				if _, ok := s.chunks[ChunkPos{cx, cy}]; !ok {
+					addChunk(ChunkPos{cx, cy})
+				}
+			}
+		}
+	}
+
+	// Chunks are encoded a region at a time, so only
+	// one region's worth of payloads is held at once
+	for rpos, chunks := range regions {
+		payloads := make(map[int][]byte)
+		for _, pos := range chunks {
+			payload, err := s.chunkPayload(pos)
+			if err != nil {
+				return err
+			}
+			if payload != nil {
+				payloads[regionIndex(pos)] = payload
+			}
+		}
+		if err := writeRegion(dir, rpos, payloads); err != nil {
+			return err
+		}
+	}
+
+	if s.full {
+		return s.regions.swap(dir, s.regionDir)
+	}
+	return nil
+}
+
+// chunkPayload encodes a chunk. Chunks that weren't copied out of
+// the game are read from the previous region files, returning nil
+// if they were never saved.
+func (s *worldSnapshot) chunkPayload(pos ChunkPos) ([]byte, error) {
+	t, ok := s.chunks[pos]
+	if !ok {
+		payload, err := readRegionChunk(s.sourceDir, pos)
+		if err == nil && payload != nil {
+			t, err = decodeChunk(payload, s.sourceVersion)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("chunk %d,%d is damaged: %v", pos.X, pos.Y, err)
+		}
+		if t == nil {
+			return nil, nil
+		}
+	}
+
+	var buf bytes.Buffer
+	encodeChunk(&buf, t)
+	return buf.Bytes(), nil
+}
@@ -1,16 +1,51 @@
+//go:build !gen
+// +build !gen
+
 package main
 
 import (
 	"fmt"
 	"os"
 	"rapidengine/geometry"
+	"rapidengine/input"
 	"rapidengine/ui"
+	"strconv"
 )
 
 var b1Text *ui.TextBox
 var b2Text *ui.TextBox
 var b3Text *ui.TextBox
 
+// Seed typed on the choose screen, new worlds
+// get a random seed when it is left empty
+var SeedInput string
+var seedText *ui.TextBox
+var seedKeysDown = make(map[string]bool)
+
+const MaxSeedLength = 18
+
+// Size of new worlds, picked from WorldSizes on the choose
+// screen or typed in as a custom width and height
+var NewWorldSize = DefaultWorldSize
+var sizeText *ui.TextBox
+
+var WidthInput = fmt.Sprint(DefaultWorldSize.Width)
+var HeightInput = fmt.Sprint(DefaultWorldSize.Height)
+var widthText *ui.TextBox
+var heightText *ui.TextBox
+
+const MaxSizeLength = 5
+
+// Fields on the choose screen that digits can be typed into
+const (
+	SeedField = iota
+	WidthField
+	HeightField
+)
+
+// Field digits are typed into, picked by clicking it
+var focusedField = SeedField
+
 func InitializeChooseScene() {
 	ChooseScene = Engine.SceneControl.NewScene("choose")
 
@@ -48,6 +83,32 @@ func InitializeChooseScene() {
 	ChooseScene.InstanceText(c2Text)
 	ChooseScene.InstanceText(c3Text)
 
+	seedText = Engine.TextControl.NewTextBox("Seed: random", "pixel", (1920/2)-300, 125, 1, [3]float32{255, 255, 255})
+	widthText = Engine.TextControl.NewTextBox("", "pixel", (1920/2)-300, 75, 1, [3]float32{255, 255, 255})
+	heightText = Engine.TextControl.NewTextBox("", "pixel", (1920/2)+100, 75, 1, [3]float32{255, 255, 255})
+
+	seedButton := Engine.UIControl.NewUIButton((1920/2)-300, 125, 350, 25)
+	seedButton.SetClickCallback(func() { focusedField = SeedField })
+	seedButton.AttachText(seedText)
+	Engine.UIControl.InstanceElement(seedButton, ChooseScene)
+
+	widthButton := Engine.UIControl.NewUIButton((1920/2)-300, 75, 350, 25)
+	widthButton.SetClickCallback(func() { focusedField = WidthField })
+	widthButton.AttachText(widthText)
+	Engine.UIControl.InstanceElement(widthButton, ChooseScene)
+
+	heightButton := Engine.UIControl.NewUIButton((1920/2)+100, 75, 300, 25)
+	heightButton.SetClickCallback(func() { focusedField = HeightField })
+	heightButton.AttachText(heightText)
+	Engine.UIControl.InstanceElement(heightButton, ChooseScene)
+
+	sizeText = Engine.TextControl.NewTextBox("Size: "+NewWorldSize.Name, "pixel", (1920/2)+100, 125, 1, [3]float32{255, 255, 255})
+
+	sizeButton := Engine.UIControl.NewUIButton((1920/2)+100, 125, 200, 25)
+	sizeButton.SetClickCallback(cycleWorldSize)
+	sizeButton.AttachText(sizeText)
+	Engine.UIControl.InstanceElement(sizeButton, ChooseScene)
+
 	b1 := Engine.UIControl.NewUIButton((1920/2)+300, 425, 75, 25)
 	b1.SetClickCallback(choose1)
 	b1.AttachText(b1Text)
@@ -96,6 +157,91 @@ func updateChooseScene() {
 	}
 }
 
+// updateSeedInput types digits into the focused field on the
+// choose screen, the seed or the size of new worlds. Backspace
+// removes them.
+func updateSeedInput(inputs *input.Input) {
+	text, max := &SeedInput, MaxSeedLength
+	switch focusedField {
+	case WidthField:
+		text, max = &WidthInput, MaxSizeLength
+	case HeightField:
+		text, max = &HeightInput, MaxSizeLength
+	}
+
+	keys := []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9", "backspace"}
+	typed := false
+	for _, key := range keys {
+		pressed := inputs.Keys[key] && !seedKeysDown[key]
+		seedKeysDown[key] = inputs.Keys[key]
+		if !pressed {
+			continue
+		}
+
+		if key == "backspace" {
+			if len(*text) > 0 {
+				*text = (*text)[:len(*text)-1]
+			}
+		} else if len(*text) < max {
+			*text += key
+		}
+		typed = true
+	}
+
+	// Typing a width or height makes a custom size
+	if typed && focusedField != SeedField {
+		width, _ := strconv.Atoi(WidthInput)
+		height, _ := strconv.Atoi(HeightInput)
+		NewWorldSize = WorldSize{"custom", width, height}
+	}
+
+	if SeedInput == "" {
+		seedText.Text = "Seed: random"
+	} else {
+		seedText.Text = "Seed: " + SeedInput
+	}
+	widthText.Text = "Width: " + WidthInput
+	heightText.Text = "Height: " + HeightInput
+	sizeText.Text = "Size: " + NewWorldSize.Name
+	if checkWorldSize(NewWorldSize.Width, NewWorldSize.Height) != nil {
+		sizeText.Text = fmt.Sprintf("Size: %dx%d to %dx%d", MinWorldWidth, MinWorldHeight, MaxWorldWidth, MaxWorldHeight)
+	}
+
+	// Show which field is being typed into
+	switch focusedField {
+	case SeedField:
+		seedText.Text += "_"
+	case WidthField:
+		widthText.Text += "_"
+	case HeightField:
+		heightText.Text += "_"
+	}
+}
+
+// chosenSeed returns the typed seed, or a random one
+func chosenSeed() int64 {
+	if seed, err := strconv.ParseInt(SeedInput, 10, 64); err == nil {
+		return seed
+	}
+	return randomSeed()
+}
+
+// cycleWorldSize switches new worlds to the next size preset,
+// or the first one after a custom size
+func cycleWorldSize() {
+	next := WorldSizes[0]
+	for i, size := range WorldSizes {
+		if size == NewWorldSize {
+			next = WorldSizes[(i+1)%len(WorldSizes)]
+			break
+		}
+	}
+	NewWorldSize = next
+	WidthInput = fmt.Sprint(next.Width)
+	HeightInput = fmt.Sprint(next.Height)
+	sizeText.Text = "Size: " + NewWorldSize.Name
+}
+
 func choose1() {
 	CurrentWorld = 1
 	if b1Text.Text == "Play" {
@@ -123,31 +269,73 @@ func choose3() {
 	}*/
 
 	initializeWorldTree()
+	EM.Clear()
+	Player1.Reset()
 	generateTestWorldTree()
 }
 
 func loadWorld() {
-	ProgressText.Text = "Loading world..."
-	ProgressBar.SetPercentage(0)
 	Engine.SceneControl.SetCurrentScene(LoadingScene)
-	updateLoadingScreen()
+	updateLoadingProgress("Loading world...", 0)
 
 	initializeWorldTree()
-	WorldMap.LoadFromFile("./worlds/world" + fmt.Sprint(CurrentWorld) + ".hln")
+	progress := func(percent float32) {
+		updateLoadingProgress("Loading world...", percent)
+	}
+	if err := WorldMap.LoadFromFile(worldPath(CurrentWorld), progress); err != nil {
+		Engine.Logger.Info("Failed to load world: " + err.Error())
+		Engine.SceneControl.SetCurrentScene(ChooseScene)
+		offerRestore(CurrentWorld)
+		return
+	}
+
+	updateParallaxSize()
+
+	Player1.Reset()
+	if WorldMap.saved.player != nil {
+		Player1.LoadState(*WorldMap.saved.player)
+	} else {
+		Player1.SetPosition(float32(WorldWidth*BlockSize/2), float32((HeightMap[WorldWidth/2]+25)*BlockSize))
+	}
+
+	// Chunks load as they are needed, so check the ones around the player now
+	WorldMap.UpdateChunks(Player1.CenterX, Player1.CenterY)
+	if WorldMap.corrupt != nil {
+		Engine.Logger.Info("Failed to load world: " + WorldMap.corrupt.Error())
+		Engine.SceneControl.SetCurrentScene(ChooseScene)
+		offerRestore(CurrentWorld)
+		return
+	}
+	EM.LoadState(WorldMap.saved.enemies)
+	loadDroppedItems(WorldMap.saved.items)
+	loadFallingBlocks(WorldMap.saved.falling)
 
-	Player1.PlayerChild.SetPosition(float32(WorldWidth*BlockSize/2), float32((HeightMap[WorldWidth/2]+25)*BlockSize))
 	Engine.SceneControl.SetCurrentScene(WorldScene)
 }
 
 func newWorld() {
+	// The size on the choose screen says what sizes are allowed
+	if err := checkWorldSize(NewWorldSize.Width, NewWorldSize.Height); err != nil {
+		Engine.Logger.Info("Not creating world: " + err.Error())
+		return
+	}
+
 	ProgressText.Text = "Generating world..."
 	Engine.SceneControl.SetCurrentScene(LoadingScene)
-	generateWorldTree()
+
+	initializeWorldTree()
+	EM.Clear()
+	Player1.Reset()
+	generateWorldTree(chosenSeed(), NewWorldSize)
 }
 
 func doesWorldExist(world int) bool {
-	if _, err := os.Stat("./worlds/world" + fmt.Sprint(world) + ".hln"); os.IsNotExist(err) {
+	if _, err := os.Stat(worldPath(world)); os.IsNotExist(err) {
 		return false
 	}
 	return true
 }
+
+func worldPath(world int) string {
+	return "./worlds/world" + fmt.Sprint(world) + ".hln"
+}
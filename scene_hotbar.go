@@ -1,9 +1,14 @@
+//go:build !gen
+// +build !gen
+
 package main
 
 import (
+	"fmt"
 	"rapidengine/child"
 	"rapidengine/geometry"
 	"rapidengine/material"
+	"rapidengine/ui"
 )
 
 const NumSlots = 5
@@ -11,10 +16,10 @@ const SlotSize = 50
 const SlotSpacing = 25
 
 var ActiveItem int
-var HotBarItems [NumSlots]string
 
 var BarChildren [NumSlots]*child.Child2D
 var BarMats [NumSlots]*material.BasicMaterial
+var BarCounts [NumSlots]*ui.TextBox
 
 var ActiveChild *child.Child2D
 
@@ -50,15 +55,11 @@ func InitializeHotbarScene() {
 		BarChildren[i].SetPosition(float32(ScreenWidth)-120, 500-float32(i*(SlotSize+SlotSpacing)))
 
 		HotbarScene.InstanceChild(BarChildren[i])
-	}
 
-	HotBarItems = [NumSlots]string{
-		"dirt",
-		"stone",
-		"torch",
-		"stoneBrick",
-		"dirt",
+		BarCounts[i] = Engine.TextControl.NewTextBox("", "pixel", float32(ScreenWidth)-85, 500-float32(i*(SlotSize+SlotSpacing)), 0.5, [3]float32{255, 255, 255})
+		HotbarScene.InstanceText(BarCounts[i])
 	}
+
 	ActiveItem = 0
 
 	Player1Health = Engine.UIControl.NewProgressBar()
@@ -68,14 +69,36 @@ func InitializeHotbarScene() {
 	Player1Health.SetPosition(50, 0.9*float32(Engine.Config.ScreenHeight))
 	Engine.UIControl.InstanceElement(Player1Health, HotbarScene)
 
+	manaMat := Engine.MaterialControl.NewBasicMaterial()
+	manaMat.DiffuseLevel = 0
+	manaMat.Hue = [4]float32{60, 110, 255, 255}
+
+	Player1Mana = Engine.UIControl.NewProgressBar()
+	Player1Mana.BackChild.Static = true
+	Player1Mana.BarChild.Static = true
+	Player1Mana.BarChild.AttachMaterial(manaMat)
+	Player1Mana.SetDimensions(200, 25)
+	Player1Mana.SetPosition(470, 0.9*float32(Engine.Config.ScreenHeight))
+	Engine.UIControl.InstanceElement(Player1Mana, HotbarScene)
+
 	UpdateHotBar()
 	HotbarScene.Deactivate()
 }
 
+// UpdateHotBar shows the first NumSlots slots of the player's inventory
 func UpdateHotBar() {
+	if Player1.Inventory == nil {
+		return
+	}
 	for i := 0; i < NumSlots; i++ {
-		if HotBarItems[i] != "" {
-			BarMats[i].DiffuseMap = GetBlock(HotBarItems[i]).GetMaterial("NN").DiffuseMap
+		stack := Player1.Inventory.Slot(i)
+		BarCounts[i].Text = ""
+		if stack.Empty() {
+			continue
+		}
+		BarMats[i].DiffuseMap = itemMaterial(stack.Name).DiffuseMap
+		if stack.Count > 1 {
+			BarCounts[i].Text = fmt.Sprint(stack.Count)
 		}
 	}
 }
@@ -86,4 +109,6 @@ func UpdateActiveItem() {
 	// UI Updates
 	Player1Health.SetPercentage(Player1.Health / Player1.MaxHealth * 100)
 	Player1Health.SetPosition(50, 0.9*float32(Engine.Config.ScreenHeight))
+	Player1Mana.SetPercentage(Player1.Mana / Player1.MaxMana * 100)
+	Player1Mana.SetPosition(470, 0.9*float32(Engine.Config.ScreenHeight))
 }
@@ -0,0 +1,634 @@
+//go:build !gen
+// +build !gen
+
+package main
+
+import (
+	"fmt"
+	"rapidengine/child"
+	"rapidengine/cmd"
+	"rapidengine/geometry"
+	"rapidengine/input"
+	"rapidengine/material"
+	"rapidengine/ui"
+	"strings"
+)
+
+//  --------------------------------------------------
+//  Scene_inventory.go contains the inventory screen.
+//
+//  Tab opens and closes it, and while it is open the
+//  world keeps going without the player taking input.
+//  Every slot is a button: clicking one picks its stack
+//  up onto the cursor, and clicking another puts it down,
+//  merging or swapping with what is there. Stacks can
+//  also be dragged onto another slot. Shift-click moves
+//  a stack between the hotbar and the rest of the
+//  inventory, right-click picks up half a stack or puts
+//  down one item, and hovering a slot shows its stats.
+//  Next to the inventory, the crafting panel lists the
+//  recipes that can be crafted right now (see crafting.go),
+//  a page of CraftingRows at a time.
+//  --------------------------------------------------
+
+// Number of columns in the inventory grid
+const InventoryColumns = 7
+
+// Distance between neighbouring slots on the inventory screen
+const InventorySlotPitch = SlotSize + 10
+
+// Bottom left corner of the top left slot of the grid
+const InventoryGridX = 800
+const InventoryGridY = 760
+
+// inventorySlot is a slot on the inventory screen
+type inventorySlot struct {
+	// Slot in the player's inventory, or in their equipment if Equip is set
+	Index int
+	Equip bool
+
+	X float32
+	Y float32
+
+	Icon    *child.Child2D
+	IconMat *material.BasicMaterial
+	Count   *ui.TextBox
+}
+
+var InventorySlots []*inventorySlot
+var InventoryBackChild *child.Child2D
+
+var TooltipBackChild *child.Child2D
+var TooltipName *ui.TextBox
+var TooltipStats *ui.TextBox
+
+// CursorStack is the stack picked up off a slot
+var CursorStack ItemStack
+var CursorChild *child.Child2D
+var CursorMat *material.BasicMaterial
+var CursorCount *ui.TextBox
+
+// Slot the cursor stack is being dragged from, or nil
+var dragFrom *inventorySlot
+var hoveredSlot *inventorySlot
+
+// Input last frame, to tell presses from holds
+var inventoryKeyDown bool
+var inventoryLeftDown bool
+var inventoryRightDown bool
+var inventoryShiftDown bool
+
+// Set while the escape press that closed the inventory is held,
+// so it doesn't open the menu as well
+var escapeClosedInventory bool
+
+// Number of recipes the crafting panel lists at once
+const CraftingRows = 7
+const CraftingRowHeight = 40
+const CraftingRowWidth = 340
+
+// Width of the buttons that turn the crafting panel's pages
+const CraftingPageButtonWidth = 60
+
+// Bottom left corner of the top row of the crafting panel
+const CraftingPanelX = InventoryGridX + InventoryColumns*InventorySlotPitch + 40
+const CraftingPanelY = InventoryGridY + SlotSize - CraftingRowHeight
+
+var CraftingBackChild *child.Child2D
+var CraftingTexts [CraftingRows]*ui.TextBox
+var CraftingIcons [CraftingRows]*child.Child2D
+var CraftingIconMats [CraftingRows]*material.BasicMaterial
+
+var CraftingPageText *ui.TextBox
+
+// Recipes listed on the crafting panel, one per row
+var shownRecipes []*Recipe
+
+// Page of craftable recipes the crafting panel shows
+var craftingPage int
+
+func InitializeInventoryScene() {
+	InventoryScene = Engine.SceneControl.NewScene("inventory")
+
+	// Hotbar row under the grid
+	rows := (InventorySize - NumSlots) / InventoryColumns
+	hotbarY := float32(InventoryGridY - rows*InventorySlotPitch - 10)
+
+	backMat := Engine.MaterialControl.NewBasicMaterial()
+	backMat.Hue = [4]float32{30, 30, 30, 220}
+
+	InventoryBackChild = Engine.ChildControl.NewChild2D()
+	InventoryBackChild.AttachMesh(geometry.NewRectangle())
+	InventoryBackChild.AttachMaterial(backMat)
+	InventoryBackChild.ScaleX = InventoryColumns*InventorySlotPitch + 130
+	InventoryBackChild.ScaleY = InventoryGridY + SlotSize + 20 - (hotbarY - 20)
+	InventoryBackChild.Static = true
+	InventoryBackChild.SetPosition(InventoryGridX-110, hotbarY-20)
+	InventoryScene.InstanceChild(InventoryBackChild)
+
+	CraftingBackChild = Engine.ChildControl.NewChild2D()
+	CraftingBackChild.AttachMesh(geometry.NewRectangle())
+	CraftingBackChild.AttachMaterial(backMat)
+	CraftingBackChild.ScaleX = CraftingRowWidth + 20
+	CraftingBackChild.ScaleY = InventoryBackChild.ScaleY
+	CraftingBackChild.Static = true
+	CraftingBackChild.SetPosition(CraftingPanelX-10, hotbarY-20)
+	InventoryScene.InstanceChild(CraftingBackChild)
+
+	for i := 0; i < CraftingRows; i++ {
+		newCraftingRow(i, CraftingPanelX, CraftingPanelY-float32(i*(CraftingRowHeight+5)))
+	}
+	newCraftingPageButtons(CraftingPanelX, CraftingPanelY-float32(CraftingRows*(CraftingRowHeight+5)))
+
+	for i := 0; i < NumSlots; i++ {
+		newInventorySlot(i, false, InventoryGridX+float32(i*InventorySlotPitch), hotbarY)
+	}
+
+	// Rest of the inventory
+	for i := NumSlots; i < InventorySize; i++ {
+		col, row := (i-NumSlots)%InventoryColumns, (i-NumSlots)/InventoryColumns
+		newInventorySlot(i, false, InventoryGridX+float32(col*InventorySlotPitch), InventoryGridY-float32(row*InventorySlotPitch))
+	}
+
+	// Equipment, left of the grid
+	for i := 0; i < NumEquipSlots; i++ {
+		newInventorySlot(i, true, InventoryGridX-90, InventoryGridY-float32(i*InventorySlotPitch))
+	}
+
+	tooltipMat := Engine.MaterialControl.NewBasicMaterial()
+	tooltipMat.Hue = [4]float32{0, 0, 0, 255}
+
+	TooltipBackChild = Engine.ChildControl.NewChild2D()
+	TooltipBackChild.AttachMesh(geometry.NewRectangle())
+	TooltipBackChild.AttachMaterial(tooltipMat)
+	TooltipBackChild.ScaleY = 60
+	TooltipBackChild.Static = true
+	InventoryScene.InstanceChild(TooltipBackChild)
+
+	TooltipName = Engine.TextControl.NewTextBox("", "pixel", 0, 0, 0.5, [3]float32{255, 255, 255})
+	TooltipStats = Engine.TextControl.NewTextBox("", "pixel", 0, 0, 0.4, [3]float32{180, 180, 180})
+	InventoryScene.InstanceText(TooltipName)
+	InventoryScene.InstanceText(TooltipStats)
+
+	CursorMat = Engine.MaterialControl.NewBasicMaterial()
+	CursorMat.DiffuseLevel = 1
+	CursorMat.DiffuseMapScale = 1
+
+	CursorChild = Engine.ChildControl.NewChild2D()
+	CursorChild.AttachMesh(geometry.NewRectangle())
+	CursorChild.AttachMaterial(CursorMat)
+	CursorChild.ScaleX = SlotSize
+	CursorChild.ScaleY = SlotSize
+	CursorChild.Static = true
+	InventoryScene.InstanceChild(CursorChild)
+
+	CursorCount = Engine.TextControl.NewTextBox("", "pixel", 0, 0, 0.5, [3]float32{255, 255, 255})
+	InventoryScene.InstanceText(CursorCount)
+
+	InventoryScene.Deactivate()
+}
+
+// newInventorySlot adds a slot to the inventory screen, with
+// its bottom left corner at a position
+func newInventorySlot(index int, equip bool, x, y float32) {
+	s := &inventorySlot{Index: index, Equip: equip, X: x, Y: y}
+
+	button := Engine.UIControl.NewUIButton(x, y, SlotSize, SlotSize)
+	button.SetClickCallback(func() { clickSlot(s) })
+	button.ButtonChild.AttachMaterial(ButtonMaterial)
+	Engine.UIControl.InstanceElement(button, InventoryScene)
+
+	s.IconMat = Engine.MaterialControl.NewBasicMaterial()
+	s.IconMat.DiffuseLevel = 1
+	s.IconMat.DiffuseMapScale = 1
+
+	s.Icon = Engine.ChildControl.NewChild2D()
+	s.Icon.AttachMesh(geometry.NewRectangle())
+	s.Icon.AttachMaterial(s.IconMat)
+	s.Icon.ScaleX = SlotSize
+	s.Icon.ScaleY = SlotSize
+	s.Icon.Static = true
+	s.Icon.SetPosition(x, y)
+	InventoryScene.InstanceChild(s.Icon)
+
+	s.Count = Engine.TextControl.NewTextBox("", "pixel", x+35, y, 0.5, [3]float32{255, 255, 255})
+	InventoryScene.InstanceText(s.Count)
+
+	InventorySlots = append(InventorySlots, s)
+}
+
+// newCraftingRow adds a row to the crafting panel, with its
+// bottom left corner at a position
+func newCraftingRow(row int, x, y float32) {
+	CraftingTexts[row] = Engine.TextControl.NewTextBox("", "pixel", x+44, y+12, 0.4, [3]float32{255, 255, 255})
+
+	button := Engine.UIControl.NewUIButton(x, y, CraftingRowWidth, CraftingRowHeight)
+	button.SetClickCallback(func() { craftRow(row) })
+	button.AttachText(CraftingTexts[row])
+	button.ButtonChild.AttachMaterial(ButtonMaterial)
+	Engine.UIControl.InstanceElement(button, InventoryScene)
+
+	CraftingIconMats[row] = Engine.MaterialControl.NewBasicMaterial()
+	CraftingIconMats[row].DiffuseLevel = 1
+	CraftingIconMats[row].DiffuseMapScale = 1
+
+	CraftingIcons[row] = Engine.ChildControl.NewChild2D()
+	CraftingIcons[row].AttachMesh(geometry.NewRectangle())
+	CraftingIcons[row].AttachMaterial(CraftingIconMats[row])
+	CraftingIcons[row].ScaleX = CraftingRowHeight - 8
+	CraftingIcons[row].ScaleY = CraftingRowHeight - 8
+	CraftingIcons[row].Static = true
+	CraftingIcons[row].SetPosition(x+4, y+4)
+	InventoryScene.InstanceChild(CraftingIcons[row])
+}
+
+// newCraftingPageButtons adds the buttons that turn the crafting
+// panel's pages, with their bottom left corner at a position
+func newCraftingPageButtons(x, y float32) {
+	prevText := Engine.TextControl.NewTextBox("<", "pixel", x+24, y+12, 0.4, [3]float32{255, 255, 255})
+	prev := Engine.UIControl.NewUIButton(x, y, CraftingPageButtonWidth, CraftingRowHeight)
+	prev.SetClickCallback(func() { craftingPage-- })
+	prev.AttachText(prevText)
+	prev.ButtonChild.AttachMaterial(ButtonMaterial)
+	Engine.UIControl.InstanceElement(prev, InventoryScene)
+
+	nextX := x + CraftingRowWidth - CraftingPageButtonWidth
+	nextText := Engine.TextControl.NewTextBox(">", "pixel", nextX+24, y+12, 0.4, [3]float32{255, 255, 255})
+	next := Engine.UIControl.NewUIButton(nextX, y, CraftingPageButtonWidth, CraftingRowHeight)
+	next.SetClickCallback(func() { craftingPage++ })
+	next.AttachText(nextText)
+	next.ButtonChild.AttachMaterial(ButtonMaterial)
+	Engine.UIControl.InstanceElement(next, InventoryScene)
+
+	CraftingPageText = Engine.TextControl.NewTextBox("", "pixel", x+CraftingRowWidth/2-20, y+12, 0.4, [3]float32{255, 255, 255})
+	InventoryScene.InstanceText(CraftingPageText)
+}
+
+// inventory returns the inventory a slot belongs to
+func (s *inventorySlot) inventory() *Inventory {
+	if s.Equip {
+		return Player1.Equipment
+	}
+	return Player1.Inventory
+}
+
+// stack returns the stack in a slot
+func (s *inventorySlot) stack() *ItemStack {
+	return &s.inventory().Slots[s.Index]
+}
+
+// capacity returns how many of an item a slot holds, or 0
+// if the item can't go there at all
+func (s *inventorySlot) capacity(name string) int32 {
+	if s.Equip {
+		if !canEquip(s.Index, name) {
+			return 0
+		}
+		return 1
+	}
+	return maxStack(name)
+}
+
+// contains returns whether a point on the screen is over a slot
+func (s *inventorySlot) contains(x, y float32) bool {
+	return x >= s.X && x < s.X+SlotSize && y >= s.Y && y < s.Y+SlotSize
+}
+
+func openInventory() {
+	InventoryScene.Activate()
+}
+
+// closeInventory closes the inventory screen, putting the cursor
+// stack back in the inventory or dropping it if it doesn't fit
+func closeInventory() {
+	if !CursorStack.Empty() {
+		left := CursorStack.Count - Player1.Inventory.AddStack(CursorStack)
+		if left > 0 {
+			dropItem(CursorStack.Name, left, Player1.CenterX, Player1.CenterY)
+		}
+		CursorStack = ItemStack{}
+	}
+	dragFrom = nil
+	hoveredSlot = nil
+	InventoryScene.Deactivate()
+}
+
+// takeCursor takes up to a number of items off the cursor stack
+func takeCursor(count int32) ItemStack {
+	taken := CursorStack
+	if taken.Count > count {
+		taken.Count = count
+	}
+	CursorStack.Count -= taken.Count
+	if CursorStack.Count <= 0 {
+		CursorStack = ItemStack{}
+	}
+	return taken
+}
+
+// clickSlot is called when a slot is clicked
+func clickSlot(s *inventorySlot) {
+	if CursorStack.Empty() {
+		if inventoryShiftDown {
+			transferSlot(s)
+			return
+		}
+		CursorStack = s.inventory().TakeFromSlot(s.Index, s.stack().Count)
+		dragFrom = s
+		return
+	}
+	placeCursor(s)
+}
+
+// placeCursor puts the cursor stack down on a slot, merging it with
+// the stack there or swapping the two
+func placeCursor(s *inventorySlot) {
+	slot := s.stack()
+	max := s.capacity(CursorStack.Name)
+	if max == 0 {
+		return
+	}
+
+	switch {
+	case slot.Empty():
+		*slot = takeCursor(max)
+	case slot.stacksWith(CursorStack):
+		if slot.Count < max {
+			slot.Count += takeCursor(max - slot.Count).Count
+		}
+	case CursorStack.Count <= max:
+		*slot, CursorStack = CursorStack, *slot
+	}
+}
+
+// rightClickSlot picks up half of a slot's stack, or puts
+// down one item of the cursor stack on it
+func rightClickSlot(s *inventorySlot) {
+	slot := s.stack()
+	if CursorStack.Empty() {
+		CursorStack = s.inventory().TakeFromSlot(s.Index, (slot.Count+1)/2)
+		return
+	}
+
+	max := s.capacity(CursorStack.Name)
+	switch {
+	case slot.Empty() && max > 0:
+		*slot = takeCursor(1)
+	case slot.stacksWith(CursorStack) && slot.Count < max:
+		slot.Count += takeCursor(1).Count
+	}
+}
+
+// transferSlot moves a slot's stack from the hotbar into the rest of
+// the inventory or the other way around, and worn items into the
+// inventory. Items that can be worn are put on first.
+func transferSlot(s *inventorySlot) {
+	stack := *s.stack()
+	if stack.Empty() {
+		return
+	}
+
+	if s.Equip {
+		moved := Player1.Inventory.AddStack(stack)
+		s.inventory().TakeFromSlot(s.Index, moved)
+		return
+	}
+
+	for i := 0; i < NumEquipSlots; i++ {
+		if canEquip(i, stack.Name) && Player1.Equipment.Slot(i).Empty() {
+			Player1.Equipment.Slots[i] = Player1.Inventory.TakeFromSlot(s.Index, 1)
+			return
+		}
+	}
+
+	first, end := NumSlots, InventorySize
+	if s.Index >= NumSlots {
+		first, end = 0, NumSlots
+	}
+	moved := Player1.Inventory.AddStackBetween(stack, first, end)
+	Player1.Inventory.TakeFromSlot(s.Index, moved)
+}
+
+// updateInventoryScene opens and closes the inventory screen, and
+// handles the mouse while it is open
+func updateInventoryScene(inputs *input.Input) {
+	toggled := inputs.Keys["tab"] && !inventoryKeyDown
+	inventoryKeyDown = inputs.Keys["tab"]
+	if !inputs.Keys["escape"] {
+		escapeClosedInventory = false
+	}
+
+	if InventoryScene.IsActive() {
+		if toggled || Player1.Dead || GamePaused {
+			closeInventory()
+		} else if inputs.Keys["escape"] {
+			closeInventory()
+			escapeClosedInventory = true
+		}
+	} else if toggled && !Player1.Dead && !GamePaused {
+		openInventory()
+	}
+
+	if !InventoryScene.IsActive() {
+		return
+	}
+
+	mx, my := float32(inputs.MouseX), float32(Engine.Config.ScreenHeight)-float32(inputs.MouseY)
+	hoveredSlot = nil
+	for _, s := range InventorySlots {
+		if s.contains(mx, my) {
+			hoveredSlot = s
+			break
+		}
+	}
+
+	inventoryShiftDown = inputs.Keys["shift"]
+
+	if inputs.RightMouseButton && !inventoryRightDown && hoveredSlot != nil {
+		rightClickSlot(hoveredSlot)
+	}
+	inventoryRightDown = inputs.RightMouseButton
+
+	// Dropping a dragged stack
+	if !inputs.LeftMouseButton && inventoryLeftDown && dragFrom != nil {
+		if hoveredSlot != nil && hoveredSlot != dragFrom && !CursorStack.Empty() {
+			placeCursor(hoveredSlot)
+		}
+		dragFrom = nil
+	}
+	inventoryLeftDown = inputs.LeftMouseButton
+
+	updateInventorySlots()
+	updateCraftingPanel()
+	updateTooltip(mx, my)
+
+	CursorChild.SetPosition(mx-SlotSize/2, my-SlotSize/2)
+	CursorCount.X, CursorCount.Y = mx+10, my-SlotSize/2
+	CursorCount.Text = ""
+	if !CursorStack.Empty() {
+		CursorMat.DiffuseMap = itemMaterial(CursorStack.Name).DiffuseMap
+		if CursorStack.Count > 1 {
+			CursorCount.Text = fmt.Sprint(CursorStack.Count)
+		}
+	}
+}
+
+// updateInventorySlots shows the stacks in every slot
+func updateInventorySlots() {
+	for _, s := range InventorySlots {
+		stack := s.stack()
+		s.Count.Text = ""
+		if stack.Empty() {
+			continue
+		}
+		s.IconMat.DiffuseMap = itemMaterial(stack.Name).DiffuseMap
+		if stack.Count > 1 {
+			s.Count.Text = fmt.Sprint(stack.Count)
+		}
+	}
+}
+
+// updateTooltip describes the stack under the mouse
+func updateTooltip(mx, my float32) {
+	TooltipName.Text = ""
+	TooltipStats.Text = ""
+	if hoveredSlot == nil || !CursorStack.Empty() || hoveredSlot.stack().Empty() {
+		return
+	}
+
+	stack := hoveredSlot.stack()
+	TooltipName.Text = stack.Name
+	if stack.Count > 1 {
+		TooltipName.Text += fmt.Sprintf(" (%d)", stack.Count)
+	}
+	TooltipStats.Text = itemStats(stack.Name)
+
+	TooltipName.X, TooltipName.Y = mx+25, my-25
+	TooltipStats.X, TooltipStats.Y = mx+25, my-50
+
+	width := TooltipName.GetLength()
+	if w := TooltipStats.GetLength(); w > width {
+		width = w
+	}
+	TooltipBackChild.ScaleX = width + 20
+	TooltipBackChild.SetPosition(mx+15, my-TooltipBackChild.ScaleY-5)
+}
+
+// updateCraftingPanel lists the page of recipes the player can craft
+func updateCraftingPanel() {
+	recipes := craftableRecipes(Player1.Inventory, nearbyStations(Player1.CenterX, Player1.CenterY))
+
+	// Pages run out as recipes stop being craftable
+	pages := (len(recipes) + CraftingRows - 1) / CraftingRows
+	if pages < 1 {
+		pages = 1
+	}
+	if craftingPage >= pages {
+		craftingPage = pages - 1
+	}
+	if craftingPage < 0 {
+		craftingPage = 0
+	}
+	CraftingPageText.Text = fmt.Sprintf("%d/%d", craftingPage+1, pages)
+
+	first := craftingPage * CraftingRows
+	end := first + CraftingRows
+	if end > len(recipes) {
+		end = len(recipes)
+	}
+	shownRecipes = recipes[first:end]
+
+	for i := 0; i < CraftingRows; i++ {
+		CraftingTexts[i].Text = ""
+		if i < len(shownRecipes) {
+			CraftingTexts[i].Text = recipeText(shownRecipes[i])
+			CraftingIconMats[i].DiffuseMap = itemMaterial(shownRecipes[i].Output).DiffuseMap
+		}
+	}
+}
+
+// craftRow crafts the recipe on a row of the crafting panel
+func craftRow(row int) {
+	if row < len(shownRecipes) {
+		Player1.Craft(shownRecipes[row])
+	}
+}
+
+// recipeText describes a recipe on the crafting panel, like "torch x4 - 1 wood"
+func recipeText(r *Recipe) string {
+	text := r.Output
+	if r.Count > 1 {
+		text += fmt.Sprintf(" x%d", r.Count)
+	}
+	inputs := make([]string, len(r.Inputs))
+	for i, input := range r.Inputs {
+		inputs[i] = fmt.Sprintf("%d %s", input.Count, input.Name)
+	}
+	return text + " - " + strings.Join(inputs, ", ")
+}
+
+// itemStats describes what an item does, for its tooltip
+func itemStats(name string) string {
+	switch itemKind(name) {
+	case ItemTool:
+		tool := GetTool(name)
+		return fmt.Sprintf("Tier %d tool, mines %gx as fast", tool.Tier, tool.Speed)
+	case ItemWeapon:
+		weapon := GetWeapon(name)
+		return fmt.Sprintf("%g damage, %g reach, %g swings/s, %g%% crit", weapon.Damage, weapon.Reach, weapon.SwingSpeed, weapon.CritChance*100)
+	case ItemWand:
+		wand := GetWand(name)
+		kind := GetProjectileKind(wand.Projectile)
+		return fmt.Sprintf("%g damage, %g mana, pierces %d", kind.Damage, wand.ManaCost, kind.Pierce)
+	case ItemArmor:
+		armor := GetArmor(name)
+		return strings.Title(armor.Slot) + ", " + statsText(armor.Stats)
+	case ItemBlock:
+		block := GetBlock(name)
+		if block.Tier > 0 {
+			return fmt.Sprintf("Block, durability %g, needs a tier %d tool", block.Durability, block.Tier)
+		}
+		return fmt.Sprintf("Block, durability %g", block.Durability)
+	}
+	return ""
+}
+
+// statsText lists the stats that aren't 0
+func statsText(s Stats) string {
+	var parts []string
+	if s.Defense != 0 {
+		parts = append(parts, fmt.Sprintf("%g defense", s.Defense))
+	}
+	if s.MaxHealth != 0 {
+		parts = append(parts, fmt.Sprintf("%+g max health", s.MaxHealth))
+	}
+	if s.Speed != 0 {
+		parts = append(parts, fmt.Sprintf("%+g%% speed", s.Speed*100))
+	}
+	if s.Jump != 0 {
+		parts = append(parts, fmt.Sprintf("%+g%% jump", s.Jump*100))
+	}
+	if s.Damage != 0 {
+		parts = append(parts, fmt.Sprintf("%+g%% damage", s.Damage*100))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func renderInventoryScene(renderer *cmd.Renderer) {
+	renderer.RenderChild(InventoryBackChild)
+	renderer.RenderChild(CraftingBackChild)
+	for i := range shownRecipes {
+		renderer.RenderChild(CraftingIcons[i])
+	}
+	for _, s := range InventorySlots {
+		if !s.stack().Empty() {
+			renderer.RenderChild(s.Icon)
+		}
+	}
+	if TooltipName.Text != "" {
+		renderer.RenderChild(TooltipBackChild)
+	}
+	if !CursorStack.Empty() {
+		renderer.RenderChild(CursorChild)
+	}
+}
@@ -1,6 +1,11 @@
+//go:build !gen
+// +build !gen
+
 package main
 
 import (
+	"math/rand"
+	"rapidengine/child"
 	"rapidengine/ui"
 )
 
@@ -27,11 +32,97 @@ func updateLoadingScreen() {
 	Engine.Renderer.ForceUpdate()
 }
 
+// updateLoadingProgress shows the progress of world generation
+func updateLoadingProgress(text string, percent float32) {
+	ProgressText.Text = text
+	ProgressBar.SetPercentage(percent)
+	updateLoadingScreen()
+}
+
 func create() {
 	Engine.SceneControl.SetCurrentScene(LoadingScene)
 	Engine.Logger.Info("Generating world...")
 
 	updateLoadingScreen()
 
-	generateWorldTree()
+	generateWorldTree(randomSeed(), DefaultWorldSize)
+}
+
+func initializeWorldTree() {
+	WorldMap.WaitForSave()
+	WorldMap = NewWorldTree()
+	FallingBlocks = nil
+	DroppedItems = nil
+	Projectiles = nil
+	clearDamageNumbers()
+}
+
+// generateWorldTree generates a new world and starts playing it
+func generateWorldTree(seed int64, size WorldSize) {
+	if err := generateWorld(seed, size, updateLoadingProgress); err != nil {
+		logInfo("Failed to generate world: " + err.Error())
+		Engine.SceneControl.SetCurrentScene(ChooseScene)
+		return
+	}
+	updateParallaxSize()
+
+	// Create clouds
+	generateClouds(passRand("clouds"))
+
+	// Save world to image
+	if err := WorldMap.writeToImage("out.png"); err != nil {
+		logInfo("Failed to write world image: " + err.Error())
+	}
+
+	ProgressBar.SetPercentage(100)
+	updateLoadingScreen()
+
+	// Set player starting position
+	Player1.SetPosition(float32(WorldWidth*BlockSize/2), float32((HeightMap[WorldWidth/2]+50)*BlockSize))
+
+	Engine.SceneControl.SetCurrentScene(WorldScene)
+	HotbarScene.Activate()
+}
+
+func generateTestWorldTree() {
+	Seed = randomSeed()
+	SetWorldSize(DefaultWorldSize.Width, DefaultWorldSize.Height)
+	updateParallaxSize()
+
+	for x := 1300; x < 1600; x++ {
+		HeightMap[x] = 500
+		WorldMap.createWorldBlock(x, 500, "stone")
+	}
+
+	for x := 1300; x < 1600; x++ {
+		WorldMap.orientSingleBlock("stone", false, x, 500)
+	}
+
+	// Save world to image
+	if err := WorldMap.writeToImage("out.png"); err != nil {
+		logInfo("Failed to write world image: " + err.Error())
+	}
+
+	WorldMap.CreateLighting(1500, 500, 0.9)
+
+	Player1.SetPosition(float32(BlockSize)*1500, float32(BlockSize)*600)
+
+	Engine.SceneControl.SetCurrentScene(WorldScene)
+	HotbarScene.Activate()
+}
+
+func generateClouds(rng *rand.Rand) {
+	for x := 0; x < WorldWidth; x++ {
+		if rng.Float32() < 0.4 {
+			CloudChild.AddCopy(
+				child.ChildCopy{
+					X:        float32(x * BlockSize),
+					Y:        float32((rng.Intn(20) + HeightMap[x] + 15) * BlockSize),
+					Material: cloudMaterial,
+					Darkness: 1,
+				},
+			)
+			x += 400 / BlockSize
+		}
+	}
 }
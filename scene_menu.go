@@ -1,7 +1,9 @@
+//go:build !gen
+// +build !gen
+
 package main
 
 import (
-	"fmt"
 	"rapidengine/child"
 )
 
@@ -52,14 +54,15 @@ func resume() {
 	MenuScene.Deactivate()
 }
 
+// save saves the world, after the running autosave if there is one.
+// The save indicator shows it failed if the world can't be saved.
 func save() {
-	Engine.SceneControl.SetCurrentScene(SaveScene)
-	WorldMap.WriteToFile("./worlds/world" + fmt.Sprint(CurrentWorld) + ".hln")
-	Engine.SceneControl.SetCurrentScene(WorldScene)
-	MenuScene.Activate()
+	WorldMap.SaveInBackground(worldPath(CurrentWorld))
 }
 
 func exitToTitle() {
 	exitButton.Block()
+	WorldMap.WaitForSave()
+	setParallax(&Biomes[0], &Biomes[0], 0)
 	Engine.SceneControl.SetCurrentScene(TitleScene)
 }
@@ -0,0 +1,77 @@
+//go:build !gen
+// +build !gen
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"rapidengine/ui"
+)
+
+var restoreText *ui.TextBox
+var restoreBackupPath string
+
+func InitializeRestoreScene() {
+	RestoreScene = Engine.SceneControl.NewScene("restore")
+
+	restoreText = Engine.TextControl.NewTextBox("", "pixel", (1920/2)-400, 100+12.5, 1, [3]float32{217, 30, 24})
+	RestoreScene.InstanceText(restoreText)
+
+	restoreButtonText := Engine.TextControl.NewTextBox("Restore", "pixel", 100, 100, 1, [3]float32{255, 255, 255})
+	cancelButtonText := Engine.TextControl.NewTextBox("Cancel", "pixel", 100, 100, 1, [3]float32{255, 255, 255})
+
+	restoreButton := Engine.UIControl.NewUIButton((1920/2)+150, 100, 100, 25)
+	restoreButton.SetClickCallback(restore)
+	restoreButton.AttachText(restoreButtonText)
+	restoreButton.ButtonChild.AttachMaterial(ButtonMaterial)
+
+	cancelButton := Engine.UIControl.NewUIButton((1920/2)+300, 100, 100, 25)
+	cancelButton.SetClickCallback(cancelRestore)
+	cancelButton.AttachText(cancelButtonText)
+	cancelButton.ButtonChild.AttachMaterial(ButtonMaterial)
+
+	Engine.UIControl.InstanceElement(restoreButton, RestoreScene)
+	Engine.UIControl.InstanceElement(cancelButton, RestoreScene)
+
+	RestoreScene.Deactivate()
+}
+
+// offerRestore shows the restore prompt for a world that failed to load
+func offerRestore(world int) {
+	restoreBackupPath = newestValidBackup(worldPath(world))
+	if restoreBackupPath == "" {
+		restoreText.Text = fmt.Sprintf("Save %d is damaged and has no backups", world)
+	} else {
+		restoreText.Text = fmt.Sprintf("Save %d is damaged. Restore backup from %s?", world, filepath.Base(filepath.Dir(restoreBackupPath)))
+	}
+	RestoreScene.Activate()
+}
+
+func restore() {
+	if restoreBackupPath == "" {
+		return
+	}
+	RestoreScene.Deactivate()
+
+	if err := restoreBackup(restoreBackupPath, worldPath(CurrentWorld)); err != nil {
+		Engine.Logger.Info("Failed to restore backup: " + err.Error())
+		return
+	}
+	loadWorld()
+}
+
+// leaveDamagedWorld goes back to the choose screen from a world with
+// a chunk that failed to load, without saving, and offers to restore it
+func leaveDamagedWorld() {
+	logInfo("World is damaged: " + WorldMap.corrupt.Error())
+	GamePaused = false
+	MenuScene.Deactivate()
+	setParallax(&Biomes[0], &Biomes[0], 0)
+	Engine.SceneControl.SetCurrentScene(ChooseScene)
+	offerRestore(CurrentWorld)
+}
+
+func cancelRestore() {
+	RestoreScene.Deactivate()
+}
@@ -1,27 +1,39 @@
+//go:build !gen
+// +build !gen
+
 package main
 
-import "rapidengine/ui"
+import (
+	"rapidengine/ui"
+	"time"
+)
+
+// Time "Save failed" stays up after a save fails
+const SaveFailedTime = 5 * time.Second
 
-var SaveProgressBar *ui.ProgressBar
+var SaveText *ui.TextBox
 
+// The save scene is a small indicator in the corner
+// of the world, shown while a save is running or
+// queued, and for a moment after one failed
 func InitializeSaveScene() {
 	SaveScene = Engine.SceneControl.NewScene("save")
 
-	SaveProgressBar = Engine.UIControl.NewProgressBar()
-	SaveProgressBar.SetDimensions(200, 25)
-	SaveProgressBar.SetPosition(0, 500)
-	SaveProgressBar.SetPercentage(0)
-	Engine.UIControl.AlignCenter(SaveProgressBar)
-
-	saveText := Engine.TextControl.NewTextBox("Saving World...", "pixel", 720, 600, 1, [3]float32{255, 255, 255})
-	SaveScene.InstanceText(saveText)
-
-	Engine.UIControl.InstanceElement(SaveProgressBar, SaveScene)
+	SaveText = Engine.TextControl.NewTextBox("Saving...", "pixel", 50, 50, 1, [3]float32{255, 255, 255})
+	SaveScene.InstanceText(SaveText)
 
 	SaveScene.Deactivate()
 }
 
-func updateSaveScreen() {
-	SaveProgressBar.Update(nil)
-	Engine.Renderer.ForceUpdate()
+func updateSaveIndicator() {
+	switch {
+	case WorldMap.IsSaving():
+		SaveText.Text = "Saving..."
+		SaveScene.Activate()
+	case WorldMap.SaveFailedSince(SaveFailedTime):
+		SaveText.Text = "Save failed"
+		SaveScene.Activate()
+	default:
+		SaveScene.Deactivate()
+	}
 }
@@ -1,3 +1,6 @@
+//go:build !gen
+// +build !gen
+
 package main
 
 import (
@@ -63,12 +66,12 @@ func InitializeTitleScene() {
 
 func updateTitleScreen() {
 
-	Back6Child.X = (TitleParallax / (WorldWidth * BlockSize / 10000)) / 1.4
-	Back5Child.X = (TitleParallax / (WorldWidth * BlockSize / 10000)) / 1.2
-	Back4Child.X = (TitleParallax / (WorldWidth * BlockSize / 10000)) / 1.0
-	Back3Child.X = (TitleParallax / (WorldWidth * BlockSize / 10000)) / 0.6
-	Back2Child.X = (TitleParallax / (WorldWidth * BlockSize / 10000)) / 0.3
-	Back1Child.X = (TitleParallax / (WorldWidth * BlockSize / 10000)) / 0.2
+	Back6Child.X = (TitleParallax / parallaxScale()) / 1.4
+	Back5Child.X = (TitleParallax / parallaxScale()) / 1.2
+	Back4Child.X = (TitleParallax / parallaxScale()) / 1.0
+	Back3Child.X = (TitleParallax / parallaxScale()) / 0.6
+	Back2Child.X = (TitleParallax / parallaxScale()) / 0.3
+	Back1Child.X = (TitleParallax / parallaxScale()) / 0.2
 
 	TitleParallax -= 5
 }
@@ -1,7 +1,11 @@
+//go:build !gen
+// +build !gen
+
 package main
 
 import (
 	"fmt"
+	"rapidengine/child"
 	"rapidengine/cmd"
 	"rapidengine/geometry"
 	"rapidengine/material"
@@ -18,27 +22,7 @@ func InitializeWorldScene() {
 
 	Engine.TextureControl.NewTexture("assets/backgrounds/gradient.png", "sky", "pixel")
 
-	Engine.TextureControl.NewTexture("assets/backgrounds/og1/1.png", "parallax1", "pixel")
-	Engine.TextureControl.NewTexture("assets/backgrounds/og1/2.png", "parallax2", "pixel")
-	Engine.TextureControl.NewTexture("assets/backgrounds/og1/3.png", "parallax3", "pixel")
-	Engine.TextureControl.NewTexture("assets/backgrounds/og1/4.png", "parallax4", "pixel")
-	Engine.TextureControl.NewTexture("assets/backgrounds/og1/5.png", "parallax5", "pixel")
-	Engine.TextureControl.NewTexture("assets/backgrounds/og1/6.png", "parallax6", "pixel")
-
-	/*Engine.TextureControl.NewTexture("assets/backgrounds/forest/trees1.png", "parallax5", "pixel")
-	Engine.TextureControl.NewTexture("assets/backgrounds/forest/trees2.png", "parallax6", "pixel")
-	Engine.TextureControl.NewTexture("assets/backgrounds/forest/trees3.png", "parallax7", "pixel")
-	Engine.TextureControl.NewTexture("assets/backgrounds/mountain/parallax4.png", "parallax8", "pixel")
-
-	Engine.TextureControl.NewTexture("assets/backgrounds/snow/snow1.png", "parallax9", "pixel")
-	Engine.TextureControl.NewTexture("assets/backgrounds/snow/snow2.png", "parallax10", "pixel")
-	Engine.TextureControl.NewTexture("assets/backgrounds/snow/snow3.png", "parallax11", "pixel")
-	Engine.TextureControl.NewTexture("assets/backgrounds/snow/snow4.png", "parallax12", "pixel")
-
-	Engine.TextureControl.NewTexture("assets/backgrounds/mountain2/mountain1.png", "parallax13", "pixel")
-	Engine.TextureControl.NewTexture("assets/backgrounds/mountain2/mountain2.png", "parallax14", "pixel")
-	Engine.TextureControl.NewTexture("assets/backgrounds/mountain2/mountain3.png", "parallax15", "pixel")
-	Engine.TextureControl.NewTexture("assets/backgrounds/mountain2/mountain4.png", "parallax16", "pixel")*/
+	loadParallaxTextures()
 
 	backgroundMaterial := Engine.MaterialControl.NewBasicMaterial()
 	backgroundMaterial.DiffuseLevel = 1
@@ -48,75 +32,71 @@ func InitializeWorldScene() {
 	backMat1 = Engine.MaterialControl.NewBasicMaterial()
 	backMat1.DiffuseLevel = 1
 	backMat1.Blending = true
-	backMat1.DiffuseMap = Engine.TextureControl.GetTexture("parallax1")
-	backMat1.DiffuseMapScale = float32(Config.ScreenWidth) / float32(WorldWidth*BlockSize)
 
 	backMat2 = Engine.MaterialControl.NewBasicMaterial()
 	backMat2.DiffuseLevel = 1
 	backMat2.Blending = true
-	backMat2.DiffuseMap = Engine.TextureControl.GetTexture("parallax2")
-	backMat2.DiffuseMapScale = float32(Config.ScreenWidth) / float32(WorldWidth*BlockSize)
 
 	backMat3 = Engine.MaterialControl.NewBasicMaterial()
 	backMat3.DiffuseLevel = 1
 	backMat3.Blending = true
-	backMat3.DiffuseMap = Engine.TextureControl.GetTexture("parallax3")
-	backMat3.DiffuseMapScale = float32(Config.ScreenWidth) / float32(WorldWidth*BlockSize)
 
 	backMat4 = Engine.MaterialControl.NewBasicMaterial()
 	backMat4.DiffuseLevel = 1
 	backMat4.Blending = true
-	backMat4.DiffuseMap = Engine.TextureControl.GetTexture("parallax4")
-	backMat4.DiffuseMapScale = float32(Config.ScreenWidth) / float32(WorldWidth*BlockSize)
 
 	backMat5 = Engine.MaterialControl.NewBasicMaterial()
 	backMat5.DiffuseLevel = 1
 	backMat5.Blending = true
-	backMat5.DiffuseMap = Engine.TextureControl.GetTexture("parallax5")
-	backMat5.DiffuseMapScale = float32(Config.ScreenWidth) / float32(WorldWidth*BlockSize)
 
 	backMat6 = Engine.MaterialControl.NewBasicMaterial()
 	backMat6.DiffuseLevel = 1
 	backMat6.Blending = true
-	backMat6.DiffuseMap = Engine.TextureControl.GetTexture("parallax6")
-	backMat6.DiffuseMapScale = float32(Config.ScreenWidth) / float32(WorldWidth*BlockSize)
 
 	Back1Child = Engine.ChildControl.NewChild2D()
 	Back1Child.AttachMaterial(backMat1)
 	Back1Child.AttachMesh(geometry.NewRectangle())
-	Back1Child.ScaleX = float32(WorldWidth * BlockSize)
 	Back1Child.ScaleY = float32(Config.ScreenHeight)
 
 	Back2Child = Engine.ChildControl.NewChild2D()
 	Back2Child.AttachMaterial(backMat2)
 	Back2Child.AttachMesh(geometry.NewRectangle())
-	Back2Child.ScaleX = float32(WorldWidth * BlockSize)
 	Back2Child.ScaleY = float32(Config.ScreenHeight)
 
 	Back3Child = Engine.ChildControl.NewChild2D()
 	Back3Child.AttachMaterial(backMat3)
 	Back3Child.AttachMesh(geometry.NewRectangle())
-	Back3Child.ScaleX = float32(WorldWidth * BlockSize)
 	Back3Child.ScaleY = float32(Config.ScreenHeight)
 
 	Back4Child = Engine.ChildControl.NewChild2D()
 	Back4Child.AttachMaterial(backMat4)
 	Back4Child.AttachMesh(geometry.NewRectangle())
-	Back4Child.ScaleX = float32(WorldWidth * BlockSize)
 	Back4Child.ScaleY = float32(Config.ScreenHeight)
 
 	Back5Child = Engine.ChildControl.NewChild2D()
 	Back5Child.AttachMaterial(backMat5)
 	Back5Child.AttachMesh(geometry.NewRectangle())
-	Back5Child.ScaleX = float32(WorldWidth * BlockSize)
 	Back5Child.ScaleY = float32(Config.ScreenHeight)
 
 	Back6Child = Engine.ChildControl.NewChild2D()
 	Back6Child.AttachMaterial(backMat6)
 	Back6Child.AttachMesh(geometry.NewRectangle())
-	Back6Child.ScaleX = float32(WorldWidth * BlockSize)
 	Back6Child.ScaleY = float32(Config.ScreenHeight)
 
+	for i := range blendMats {
+		blendMats[i] = Engine.MaterialControl.NewBasicMaterial()
+		blendMats[i].DiffuseLevel = 1
+		blendMats[i].Blending = true
+
+		blendChildren[i] = Engine.ChildControl.NewChild2D()
+		blendChildren[i].AttachMaterial(blendMats[i])
+		blendChildren[i].AttachMesh(geometry.NewRectangle())
+		blendChildren[i].ScaleY = float32(Config.ScreenHeight)
+	}
+
+	setParallax(&Biomes[0], &Biomes[0], 0)
+	updateParallaxSize()
+
 	SkyChild = Engine.ChildControl.NewChild2D()
 	SkyChild.AttachMaterial(backgroundMaterial)
 	SkyChild.AttachMesh(geometry.NewRectangle())
@@ -159,6 +139,10 @@ func InitializeWorldScene() {
 	GrassChild.ScaleY = BlockSize / 1.5
 	GrassChild.EnableCopying()
 
+	initializeLiquids()
+	initializeCracks()
+	initializeDroppedItems()
+
 	Engine.TextureControl.NewTexture("./assets/cloud1.png", "cloud1", "pixel")
 	cloudMaterial = Engine.MaterialControl.NewBasicMaterial()
 	cloudMaterial.DiffuseLevel = 1
@@ -206,35 +190,123 @@ func InitializeWorldScene() {
 	WorldScene.InstanceChild(Back3Child)
 	WorldScene.InstanceChild(Back2Child)
 	WorldScene.InstanceChild(Back1Child)
+	for _, blend := range blendChildren {
+		WorldScene.InstanceChild(blend)
+	}
 
 	WorldScene.InstanceChild(CloudChild)
 	WorldScene.InstanceChild(NoCollisionChild)
 	WorldScene.InstanceChild(NatureChild)
+	for _, liquid := range liquidChildren {
+		WorldScene.InstanceChild(liquid)
+	}
 	WorldScene.InstanceChild(WorldChild)
 	WorldScene.InstanceChild(GrassChild)
+	WorldScene.InstanceChild(CrackChild)
+	WorldScene.InstanceChild(ItemChild)
 	WorldScene.InstanceChild(Player1.PlayerChild)
 	WorldScene.InstanceChild(BlockSelect)
 }
 
-var CurrentParallax = int(1)
-var JustParallax = false
+//  --------------------------------------------------
+//  Parallax
+//  --------------------------------------------------
+
+// The parallax backgrounds are the layers of the biome the player is
+// in. Near a border the layers of the next biome are drawn over them
+// in blendChildren, fading in as the player gets closer to it.
+var blendMats [6]*material.BasicMaterial
+var blendChildren [6]*child.Child2D
+
+// How far the backgrounds are blended between the two biomes
+var parallaxFrom, parallaxTo *Biome
+var parallaxBlend float32
+
+func loadParallaxTextures() {
+	for b := range Biomes {
+		for i, path := range Biomes[b].Parallax {
+			Engine.TextureControl.NewTexture(path, parallaxTexture(&Biomes[b], i), "pixel")
+		}
+	}
+}
+
+func parallaxTexture(biome *Biome, layer int) string {
+	return fmt.Sprintf("%s%d", biome.Name, layer+1)
+}
+
+func backMaterials() []*material.BasicMaterial {
+	return []*material.BasicMaterial{backMat1, backMat2, backMat3, backMat4, backMat5, backMat6}
+}
+
+func backChildren() []*child.Child2D {
+	return []*child.Child2D{Back1Child, Back2Child, Back3Child, Back4Child, Back5Child, Back6Child}
+}
 
-func ChangeParallax() {
-	if JustParallax {
-		return
+// setParallax shows the layers of one biome, blended
+// into the layers of another by t from 0 to 1
+func setParallax(from, to *Biome, t float32) {
+	if from == to {
+		t = 0
 	}
+	parallaxFrom, parallaxTo, parallaxBlend = from, to, t
 
-	JustParallax = true
-	println(CurrentParallax)
+	for i, mat := range backMaterials() {
+		setParallaxLayer(mat, from, i, 1-t)
+		setParallaxLayer(blendMats[i], to, i, t)
+	}
+}
 
-	if CurrentParallax == 13 {
-		CurrentParallax = 1
+// setParallaxLayer shows a layer of a biome at the given opacity,
+// hiding it when the biome has fewer layers
+func setParallaxLayer(mat *material.BasicMaterial, biome *Biome, layer int, alpha float32) {
+	if layer >= len(biome.Parallax) {
+		alpha = 0
 	} else {
-		CurrentParallax += 4
+		mat.DiffuseMap = Engine.TextureControl.GetTexture(parallaxTexture(biome, layer))
+	}
+	mat.Hue[3] = alpha
+}
+
+// updateParallax blends the backgrounds for the biomes around the player
+// and lines the blended layers up with the ones they are drawn over
+func updateParallax() {
+	from, to, t := biomeBlendAt(Player1.CenterX / BlockSize)
+	if from != parallaxFrom || to != parallaxTo || t != parallaxBlend {
+		setParallax(from, to, t)
+	}
+
+	for i, back := range backChildren() {
+		blendChildren[i].X = back.X
+		blendChildren[i].Y = back.Y
 	}
+}
+
+// renderParallax renders the visible background layers, back to front
+func renderParallax(renderer *cmd.Renderer) {
+	mats := backMaterials()
+	backs := backChildren()
+	for i := len(backs) - 1; i >= 0; i-- {
+		if mats[i].Hue[3] > 0 {
+			renderer.RenderChild(backs[i])
+		}
+		if blendMats[i].Hue[3] > 0 {
+			renderer.RenderChild(blendChildren[i])
+		}
+	}
+}
+
+// updateParallaxSize stretches the parallax backgrounds over the whole world
+func updateParallaxSize() {
+	for _, mat := range append(backMaterials(), blendMats[:]...) {
+		mat.DiffuseMapScale = float32(Config.ScreenWidth) / float32(WorldWidth*BlockSize)
+	}
+	for _, back := range append(backChildren(), blendChildren[:]...) {
+		back.ScaleX = float32(WorldWidth * BlockSize)
+	}
+}
 
-	backMat1.DiffuseMap = Engine.TextureControl.GetTexture("parallax" + fmt.Sprint(CurrentParallax))
-	backMat2.DiffuseMap = Engine.TextureControl.GetTexture("parallax" + fmt.Sprint(CurrentParallax+1))
-	backMat3.DiffuseMap = Engine.TextureControl.GetTexture("parallax" + fmt.Sprint(CurrentParallax+2))
-	backMat4.DiffuseMap = Engine.TextureControl.GetTexture("parallax" + fmt.Sprint(CurrentParallax+3))
+// parallaxScale slows the parallax down in bigger worlds, so
+// the backgrounds span the world at the same relative speed
+func parallaxScale() float32 {
+	return float32(WorldWidth * BlockSize / 10000)
 }
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+//  --------------------------------------------------
+//  Settings.go reads the player's settings from
+//  settings.json next to the game:
+//
+//    {
+//      "autosaveMinutes": 5,
+//      "autosaveBackupMinutes": 60
+//    }
+//
+//  Every setting is optional and keeps its default
+//  from globals.go when left out, and so does every
+//  setting when there is no settings file at all.
+//  --------------------------------------------------
+
+// SettingsPath is the file the settings are read from
+const SettingsPath = "./settings.json"
+
+// settingsFile is the settings as written in the settings file
+type settingsFile struct {
+	// Minutes between autosaves, 0 turns autosaving off
+	AutosaveMinutes *float64 `json:"autosaveMinutes"`
+
+	// Minutes between backups taken by autosaves
+	AutosaveBackupMinutes *float64 `json:"autosaveBackupMinutes"`
+}
+
+// loadSettings applies the settings file, if there is one
+func loadSettings() error {
+	var settings settingsFile
+	err := decodeJSONFile(SettingsPath, &settings)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading settings: %v", err)
+	}
+
+	if m := settings.AutosaveMinutes; m != nil {
+		if *m < 0 {
+			return fmt.Errorf("%s: autosaveMinutes can't be negative", SettingsPath)
+		}
+		AutosaveInterval = minutes(*m)
+	}
+	if m := settings.AutosaveBackupMinutes; m != nil {
+		if *m < 0 {
+			return fmt.Errorf("%s: autosaveBackupMinutes can't be negative", SettingsPath)
+		}
+		AutosaveBackupInterval = minutes(*m)
+	}
+	return nil
+}
+
+func minutes(m float64) time.Duration {
+	return time.Duration(m * float64(time.Minute))
+}
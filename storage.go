@@ -2,121 +2,244 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"image"
 	"image/color"
 	"image/png"
+	"io"
 	"os"
-	"rapidengine/child"
 	"strconv"
+	"time"
 )
 
 //  --------------------------------------------------
 //  Storage.go contains the WorldTree, which stores the
 //  entire world, and can save/load worlds.
 //
-//  ID System:
-//  Each block in the map has a 5 digit ID, in which the
-//  first 3 digits specify the Block ID (see blocks.go)
-//  and the last 2 specify the orientation of the block.
-//
-//  This allows for simple serialization of the world
-//	in and out of a file on the disk.
+//  Tiles:
+//  Every tile holds a block on each layer (see
+//  worldfile.go). A block is its BlockID, its
+//  Orientation and a byte of metadata the block can
+//  keep state in, all of which are saved. Next to them
+//  each tile keeps a render copy per layer, which is
+//  only used to draw the block (see render.go).
 //  --------------------------------------------------
 
-// WorldTree contains the entire world map
+// WorldTree contains the entire world map, split into chunks
+// which are streamed in and out of region files (see region.go)
 type WorldTree struct {
-	blockNodes [WorldWidth][WorldHeight]BlockNode
+	chunks map[ChunkPos]*Chunk
+
+	// Region files backing this world (see region.go)
+	regions *regionSource
+
+	// Most recently used chunk, since lookups are very local
+	lastPos   ChunkPos
+	lastChunk *Chunk
+
+	// Player, enemies and dropped items read from the
+	// last loaded save (see entities.go)
+	saved worldEntities
+
+	// Background save in progress, if any (see save.go)
+	saving   *pendingSave
+	lastSave time.Time
+
+	// Path of a save asked for while another one was
+	// running, which starts once that one is done
+	queuedSave string
+
+	// When the last save failed, zero if it worked
+	saveFailed time.Time
+
+	// First chunk that failed to load, if any. A world with
+	// damaged chunks is never saved, so they can be restored.
+	corrupt error
+
+	// Liquid simulation timing (see liquids.go)
+	liquidTimer float64
+	liquidTick  int
+
+	// Levels of liquid each tile received in the current tick
+	liquidArrived map[[2]int]uint8
+}
+
+// ChunkPos is the position of a chunk, in chunks
+type ChunkPos struct {
+	X int
+	Y int
+}
+
+// Chunk contains the nodes of a ChunkSize x ChunkSize area
+type Chunk struct {
+	blockNodes [ChunkSize][ChunkSize]BlockNode
+
+	// Set when the chunk has changes that aren't on disk yet
+	dirty bool
+
+	// Set when the chunk failed to load, its real blocks are
+	// still on disk and it must never be written back
+	corrupt bool
+
+	// Set when liquids in the chunk may still flow (see liquids.go)
+	liquidActive bool
 }
 
 // BlockNode contains all the data for one tile on the map
 type BlockNode struct {
-	worldBlock  *child.ChildCopy
-	backBlock   *child.ChildCopy
-	natureBlock *child.ChildCopy
-	grassBlock  *child.ChildCopy
-	lightBlock  *child.ChildCopy
+	// Blocks and their render copies, indexed by layer
+	tiles  [NumLayers]Tile
+	copies [NumLayers]*blockCopy
+
+	liquid Liquid
+}
+
+// Tile is the block on one layer of a tile
+type Tile struct {
+	Block  BlockID
+	Orient Orientation
+
+	// Free for the block to keep state in
+	Meta uint8
 }
 
 // NewWorldTree returns an empty WorldTree
 func NewWorldTree() WorldTree {
-	w := WorldTree{}
-	for x := 0; x < WorldWidth; x++ {
-		for y := 0; y < WorldHeight; y++ {
-			w.AddWorldBlock(x, y, &child.ChildCopy{
-				ID:       "00000",
-				Darkness: 0,
-			})
-			w.AddBackBlock(x, y, &child.ChildCopy{
-				ID:       "00000",
-				Darkness: 0,
-			})
-			w.AddNatureBlock(x, y, &child.ChildCopy{
-				ID:       "00000",
-				Darkness: 0,
-			})
-			w.AddGrassBlock(x, y, &child.ChildCopy{
-				ID:       "00000",
-				Darkness: 0,
-			})
-			w.AddLightBlock(x, y, &child.ChildCopy{
-				ID:       "00000",
-				Darkness: 0,
-			})
+	return WorldTree{
+		chunks:   make(map[ChunkPos]*Chunk),
+		regions:  &regionSource{version: WorldFormatVersion},
+		lastSave: time.Now(),
+	}
+}
+
+func newEmptyChunk() *Chunk {
+	c := &Chunk{}
+	for x := 0; x < ChunkSize; x++ {
+		for y := 0; y < ChunkSize; y++ {
+			c.blockNodes[x][y] = newEmptyNode()
 		}
 	}
-	return w
+	return c
+}
+
+func newEmptyNode() BlockNode {
+	var n BlockNode
+	for layer := range n.copies {
+		n.copies[layer] = &blockCopy{}
+	}
+	return n
 }
 
 //  --------------------------------------------------
-//  Node Creation
+//  Chunk Access
 //  --------------------------------------------------
 
-func (tree *WorldTree) AddNode(x, y int, node BlockNode) {
-	tree.blockNodes[x][y] = node
+func isInWorld(x, y int) bool {
+	return x >= 0 && x < WorldWidth && y >= 0 && y < WorldHeight
+}
+
+// chunkAt returns the chunk containing a block, loading it if needed
+func (tree *WorldTree) chunkAt(x, y int) *Chunk {
+	pos := ChunkPos{x / ChunkSize, y / ChunkSize}
+	if tree.lastChunk != nil && tree.lastPos == pos {
+		return tree.lastChunk
+	}
+
+	c, ok := tree.chunks[pos]
+	if !ok {
+		c = tree.loadChunk(pos)
+	}
+
+	tree.lastPos = pos
+	tree.lastChunk = c
+	return c
 }
 
-func (tree *WorldTree) AddWorldBlock(x, y int, cpy *child.ChildCopy) {
-	tree.blockNodes[x][y].worldBlock = cpy
+// node returns the node at a block position. Positions
+// outside of the world get a throwaway empty node.
+func (tree *WorldTree) node(x, y int) *BlockNode {
+	if !isInWorld(x, y) {
+		n := newEmptyNode()
+		return &n
+	}
+	return &tree.chunkAt(x, y).blockNodes[x%ChunkSize][y%ChunkSize]
 }
 
-func (tree *WorldTree) AddBackBlock(x, y int, cpy *child.ChildCopy) {
-	tree.blockNodes[x][y].backBlock = cpy
+// editNode is node, but marks the chunk as changed
+func (tree *WorldTree) editNode(x, y int) *BlockNode {
+	if !isInWorld(x, y) {
+		n := newEmptyNode()
+		return &n
+	}
+	c := tree.chunkAt(x, y)
+	c.dirty = !c.corrupt
+	return &c.blockNodes[x%ChunkSize][y%ChunkSize]
 }
 
-func (tree *WorldTree) AddNatureBlock(x, y int, cpy *child.ChildCopy) {
-	tree.blockNodes[x][y].natureBlock = cpy
+//  --------------------------------------------------
+//  Node Creation
+//  --------------------------------------------------
+
+func (tree *WorldTree) AddNode(x, y int, node BlockNode) {
+	*tree.editNode(x, y) = node
 }
 
-func (tree *WorldTree) AddGrassBlock(x, y int, cpy *child.ChildCopy) {
-	tree.blockNodes[x][y].grassBlock = cpy
+// SetLayerBlock places a block on the given layer
+func (tree *WorldTree) SetLayerBlock(layer, x, y int, tile Tile) {
+	n := tree.editNode(x, y)
+	n.tiles[layer] = tile
+	n.copies[layer] = newBlockCopy(layer, x, y, tile)
 }
 
-func (tree *WorldTree) AddLightBlock(x, y int, cpy *child.ChildCopy) {
-	tree.blockNodes[x][y].lightBlock = cpy
+// RemoveLayerBlock empties the given layer
+func (tree *WorldTree) RemoveLayerBlock(layer, x, y int) {
+	n := tree.editNode(x, y)
+	n.tiles[layer] = Tile{}
+	n.copies[layer] = &blockCopy{}
 }
 
 func (tree *WorldTree) RemoveWorldBlock(x, y int) {
-	tree.blockNodes[x][y].worldBlock = &child.ChildCopy{
-		ID: "00000",
-	}
+	tree.RemoveLayerBlock(LayerWorld, x, y)
 }
 
 func (tree *WorldTree) RemoveBackBlock(x, y int) {
-	tree.blockNodes[x][y].backBlock = &child.ChildCopy{
-		ID: "00000",
-	}
+	tree.RemoveLayerBlock(LayerBack, x, y)
 }
 
 func (tree *WorldTree) RemoveNatureBlock(x, y int) {
-	tree.blockNodes[x][y].natureBlock = &child.ChildCopy{
-		ID: "00000",
-	}
+	tree.RemoveLayerBlock(LayerNature, x, y)
 }
 
 func (tree *WorldTree) RemoveGrassBlock(x, y int) {
-	tree.blockNodes[x][y].grassBlock = &child.ChildCopy{
-		ID: "00000",
+	tree.RemoveLayerBlock(LayerGrass, x, y)
+}
+
+// SetBlockMeta changes the metadata of the block on a layer
+func (tree *WorldTree) SetBlockMeta(layer, x, y int, meta uint8) {
+	tree.editNode(x, y).tiles[layer].Meta = meta
+}
+
+// SetLiquid changes the liquid of a tile, and wakes up
+// the liquids around it so they flow into the change
+func (tree *WorldTree) SetLiquid(x, y int, liquid Liquid) {
+	if !isInWorld(x, y) {
+		return
+	}
+	if liquid.Level == 0 {
+		liquid = Liquid{}
+	}
+	tree.editNode(x, y).liquid = liquid
+	tree.WakeLiquids(x, y)
+}
+
+// WakeLiquids makes the liquids around a tile flow again,
+// for when the blocks holding them change
+func (tree *WorldTree) WakeLiquids(x, y int) {
+	for _, p := range [5][2]int{{x, y}, {x + 1, y}, {x - 1, y}, {x, y + 1}, {x, y - 1}} {
+		if isInWorld(p[0], p[1]) {
+			tree.chunkAt(p[0], p[1]).liquidActive = true
+		}
 	}
 }
 
@@ -124,307 +247,293 @@ func (tree *WorldTree) RemoveGrassBlock(x, y int) {
 //  Node Retrieval
 //  --------------------------------------------------
 
-func (tree *WorldTree) GetWorldBlock(x, y int) *child.ChildCopy {
-	return tree.blockNodes[x][y].worldBlock
+// GetLayerBlock returns the render copy of the block on the given layer
+func (tree *WorldTree) GetLayerBlock(layer, x, y int) *blockCopy {
+	return tree.node(x, y).copies[layer]
+}
+
+func (tree *WorldTree) GetWorldBlock(x, y int) *blockCopy {
+	return tree.GetLayerBlock(LayerWorld, x, y)
 }
 
-func (tree *WorldTree) GetBackBlock(x, y int) *child.ChildCopy {
-	return tree.blockNodes[x][y].backBlock
+func (tree *WorldTree) GetBackBlock(x, y int) *blockCopy {
+	return tree.GetLayerBlock(LayerBack, x, y)
 }
 
-func (tree *WorldTree) GetNatureBlock(x, y int) *child.ChildCopy {
-	return tree.blockNodes[x][y].natureBlock
+func (tree *WorldTree) GetNatureBlock(x, y int) *blockCopy {
+	return tree.GetLayerBlock(LayerNature, x, y)
 }
 
-func (tree *WorldTree) GetGrassBlock(x, y int) *child.ChildCopy {
-	return tree.blockNodes[x][y].grassBlock
+func (tree *WorldTree) GetGrassBlock(x, y int) *blockCopy {
+	return tree.GetLayerBlock(LayerGrass, x, y)
 }
 
-func (tree *WorldTree) GetLightBlock(x, y int) *child.ChildCopy {
-	return tree.blockNodes[x][y].lightBlock
+func (tree *WorldTree) GetLightBlock(x, y int) *blockCopy {
+	return tree.GetLayerBlock(LayerLight, x, y)
+}
+
+// GetLayerTile returns the block on the given layer
+func (tree *WorldTree) GetLayerTile(layer, x, y int) Tile {
+	return tree.node(x, y).tiles[layer]
+}
+
+func (tree *WorldTree) GetLiquid(x, y int) Liquid {
+	return tree.node(x, y).liquid
 }
 
 func (tree *WorldTree) GetWorldBlockName(x, y int) string {
-	return GetNameFromID(tree.blockNodes[x][y].worldBlock.ID[:3])
+	return GetNameFromID(tree.GetWorldBlockID(x, y))
 }
 
 func (tree *WorldTree) GetBackBlockName(x, y int) string {
-	return GetNameFromID(tree.blockNodes[x][y].backBlock.ID[:3])
+	return GetNameFromID(tree.GetBackBlockID(x, y))
 }
 
 func (tree *WorldTree) GetNatureBlockName(x, y int) string {
-	return GetNameFromID(tree.blockNodes[x][y].natureBlock.ID[:3])
+	return GetNameFromID(tree.GetNatureBlockID(x, y))
 }
 
 func (tree *WorldTree) GetGrassBlockName(x, y int) string {
-	return GetNameFromID(tree.blockNodes[x][y].grassBlock.ID[:3])
+	return GetNameFromID(tree.GetGrassBlockID(x, y))
 }
 
-func (tree *WorldTree) GetWorldBlockID(x, y int) string {
-	if x > WorldWidth || x < 0 || y > WorldHeight || y < 0 {
-		return "00000"
-	}
-	return tree.blockNodes[x][y].worldBlock.ID
+func (tree *WorldTree) GetWorldBlockID(x, y int) BlockID {
+	return tree.node(x, y).tiles[LayerWorld].Block
+}
+
+func (tree *WorldTree) GetBackBlockID(x, y int) BlockID {
+	return tree.node(x, y).tiles[LayerBack].Block
 }
 
-func (tree *WorldTree) GetBackBlockID(x, y int) string {
-	return tree.blockNodes[x][y].backBlock.ID
+func (tree *WorldTree) GetNatureBlockID(x, y int) BlockID {
+	return tree.node(x, y).tiles[LayerNature].Block
 }
 
-func (tree *WorldTree) GetNatureBlockID(x, y int) string {
-	return tree.blockNodes[x][y].natureBlock.ID
+func (tree *WorldTree) GetGrassBlockID(x, y int) BlockID {
+	return tree.node(x, y).tiles[LayerGrass].Block
 }
 
-func (tree *WorldTree) GetLightBlockID(x, y int) string {
-	return tree.blockNodes[x][y].lightBlock.ID
+func (tree *WorldTree) GetLightBlockID(x, y int) BlockID {
+	return tree.node(x, y).tiles[LayerLight].Block
 }
 
-func (tree *WorldTree) GetWorldBlockOrientation(x, y int) string {
-	return GetOrientationFromID(tree.blockNodes[x][y].worldBlock.ID[3:])
+func (tree *WorldTree) GetWorldBlockOrientation(x, y int) Orientation {
+	return tree.node(x, y).tiles[LayerWorld].Orient
 }
 
-func (tree *WorldTree) GetBackBlockOrientation(x, y int) string {
-	return GetOrientationFromID(tree.blockNodes[x][y].backBlock.ID[3:])
+func (tree *WorldTree) GetBackBlockOrientation(x, y int) Orientation {
+	return tree.node(x, y).tiles[LayerBack].Orient
+}
+
+func (tree *WorldTree) GetBlockMeta(layer, x, y int) uint8 {
+	return tree.node(x, y).tiles[layer].Meta
 }
 
 func (tree *WorldTree) GetDarkness(x, y int) float32 {
-	if tree.blockNodes[x][y].worldBlock.ID == "00000" {
-		if back := tree.blockNodes[x][y].backBlock; back.ID != "00000" {
-			return back.Darkness
-		}
+	n := tree.node(x, y)
+	if n.tiles[LayerWorld].Block == BlockEmpty && n.tiles[LayerBack].Block != BlockEmpty {
+		return n.copies[LayerBack].Darkness
 	}
-	return tree.blockNodes[x][y].worldBlock.Darkness
+	return n.copies[LayerWorld].Darkness
 }
 
 //  --------------------------------------------------
 //  Node Modification
 //  --------------------------------------------------
 
-// Updates node materials, for when orientations change
-func (tree *WorldTree) UpdateWorldBlockMaterial(x, y int) {
-	tree.blockNodes[x][y].worldBlock.Material = GetBlock(tree.GetWorldBlockName(x, y)).GetMaterial(tree.GetWorldBlockOrientation(x, y))
+// updateLayerMaterial updates the material of a block, for when its orientation changes
+func (tree *WorldTree) updateLayerMaterial(layer, x, y int) {
+	n := tree.node(x, y)
+	if tile := n.tiles[layer]; tile.Block != BlockEmpty {
+		n.copies[layer].Material = GetBlockByID(tile.Block).GetMaterial(tile.Orient)
+	}
 }
 
-func (tree *WorldTree) UpdateBackBlockMaterial(x, y int) {
-	tree.blockNodes[x][y].backBlock.Material = GetBlock(tree.GetBackBlockName(x, y)).GetMaterial(tree.GetBackBlockOrientation(x, y))
+func (tree *WorldTree) UpdateWorldBlockMaterial(x, y int) {
+	tree.updateLayerMaterial(LayerWorld, x, y)
 }
 
-func (tree *WorldTree) UpdateNatureBlockMaterial(x, y int) {
-	tree.blockNodes[x][y].natureBlock.Material = GetBlock(tree.GetNatureBlockName(x, y)).GetMaterial(tree.GetWorldBlockOrientation(x, y))
+func (tree *WorldTree) UpdateBackBlockMaterial(x, y int) {
+	tree.updateLayerMaterial(LayerBack, x, y)
 }
 
-func (tree *WorldTree) UpdateGrassBlockMaterial(x, y int) {
-	tree.blockNodes[x][y].grassBlock.Material = GetBlock(tree.GetGrassBlockName(x, y)).GetMaterial(tree.GetWorldBlockOrientation(x, y))
+func (tree *WorldTree) SetWorldBlockOrientation(x, y int, orient Orientation) {
+	tree.editNode(x, y).tiles[LayerWorld].Orient = orient
 }
 
-func (tree *WorldTree) SetWorldBlockOrientation(x, y int, orient string) {
-	tree.blockNodes[x][y].worldBlock.ID = tree.blockNodes[x][y].worldBlock.ID[:3] + OrientationsMap[orient]
-}
-func (tree *WorldTree) SetBackBlockOrientation(x, y int, orient string) {
-	tree.blockNodes[x][y].backBlock.ID = tree.blockNodes[x][y].backBlock.ID[:3] + OrientationsMap[orient]
+func (tree *WorldTree) SetBackBlockOrientation(x, y int, orient Orientation) {
+	tree.editNode(x, y).tiles[LayerBack].Orient = orient
 }
 
 func (tree *WorldTree) SetDarkness(x, y int, darkness float32) {
-	tree.blockNodes[x][y].worldBlock.Darkness = darkness
-	tree.blockNodes[x][y].backBlock.Darkness = darkness
-	tree.blockNodes[x][y].natureBlock.Darkness = darkness
-	tree.blockNodes[x][y].grassBlock.Darkness = darkness
-	tree.blockNodes[x][y].lightBlock.Darkness = darkness
+	n := tree.editNode(x, y)
+	for _, cpy := range n.copies {
+		cpy.Darkness = darkness
+	}
 }
 
 //  --------------------------------------------------
 //  Block Helpers
 //  --------------------------------------------------
 
-func createWorldBlock(x, y int, name string) {
-	WorldMap.AddWorldBlock(x, y, &child.ChildCopy{
-		X:        float32(x * BlockSize),
-		Y:        float32(y * BlockSize),
-		Material: GetBlock(name).GetMaterial("NN"),
-		Darkness: 0,
-		ID:       GetIDFromName(name) + "00",
-	})
+// newBlockCopy creates the render copy of a block, offset
+// so nature and grass blocks sit right on the ground
+func newBlockCopy(layer, x, y int, tile Tile) *blockCopy {
+	cpy := &blockCopy{
+		X: float32(x * BlockSize),
+		Y: float32(y * BlockSize),
+	}
+	if tile.Block != BlockEmpty {
+		cpy.Material = GetBlockByID(tile.Block).GetMaterial(tile.Orient)
+	}
+	switch layer {
+	case LayerNature:
+		cpy.Y -= 2
+	case LayerGrass:
+		cpy.Y += 20
+	}
+	return cpy
 }
 
-func createBackBlock(x, y int, name string) {
-	WorldMap.AddBackBlock(x, y, &child.ChildCopy{
-		X:        float32(x * BlockSize),
-		Y:        float32(y * BlockSize),
-		Material: GetBlock(name).GetMaterial("NN"),
-		Darkness: 0,
-		ID:       GetIDFromName(name) + "00",
-	})
+func (tree *WorldTree) createWorldBlock(x, y int, name string) {
+	tree.SetLayerBlock(LayerWorld, x, y, Tile{Block: GetIDFromName(name)})
 }
 
-func createNatureBlock(x, y int, name string) {
-	WorldMap.AddNatureBlock(x, y, &child.ChildCopy{
-		X:        float32(x * BlockSize),
-		Y:        float32(y*BlockSize) - 2,
-		Material: GetBlock(name).GetMaterial("NN"),
-		Darkness: 0,
-		ID:       GetIDFromName(name) + "00",
-	})
+func (tree *WorldTree) createBackBlock(x, y int, name string) {
+	tree.SetLayerBlock(LayerBack, x, y, Tile{Block: GetIDFromName(name)})
 }
 
-func createGrassBlock(x, y int, name string) {
-	WorldMap.AddGrassBlock(x, y, &child.ChildCopy{
-		X:        float32(x * BlockSize),
-		Y:        float32(y*BlockSize) + 20,
-		Material: GetBlock(name).GetMaterial("NN"),
-		Darkness: 0,
-		ID:       GetIDFromName(name) + "00",
-	})
+func (tree *WorldTree) createNatureBlock(x, y int, name string) {
+	tree.SetLayerBlock(LayerNature, x, y, Tile{Block: GetIDFromName(name)})
 }
 
-func createLightBlock(x, y int, name string) {
-	WorldMap.AddLightBlock(x, y, &child.ChildCopy{
-		X:        float32(x * BlockSize),
-		Y:        float32(y * BlockSize),
-		Material: GetBlock(name).GetMaterial("NN"),
-		Darkness: 0.8,
-		ID:       GetIDFromName(name) + "00",
-	})
+func (tree *WorldTree) createGrassBlock(x, y int, name string) {
+	tree.SetLayerBlock(LayerGrass, x, y, Tile{Block: GetIDFromName(name)})
+}
+
+func (tree *WorldTree) createLightBlock(x, y int, name string) {
+	tree.SetLayerBlock(LayerLight, x, y, Tile{Block: GetIDFromName(name)})
+	tree.GetLightBlock(x, y).Darkness = 0.8
 }
 
 //  --------------------------------------------------
 //  World Serialization
 //  --------------------------------------------------
 
-func (tree *WorldTree) WriteToFile(path string) {
-	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0600)
+// LoadFromFile loads a world saved in either the binary
+// format or the legacy line-per-block text format, calling
+// progress with the percentage of the world loaded so far
+func (tree *WorldTree) LoadFromFile(path string, progress func(percent float32)) error {
+	f, err := os.Open(path)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	defer f.Close()
 
-	point := float32(100) / float32(WorldHeight)
-	SaveProgressBar.SetPercentage(0)
-
-	for x := 0; x < WorldWidth; x++ {
-		f.WriteString(fmt.Sprint(HeightMap[x]))
-		f.WriteString("\n")
-	}
-
-	for x := 0; x < WorldWidth; x++ {
-		for y := 0; y < WorldHeight; y++ {
-			f.WriteString(
-				tree.GetWorldBlockID(x, y) +
-					tree.GetBackBlockID(x, y) +
-					tree.GetNatureBlockID(x, y) +
-					tree.GetLightBlockID(x, y) +
-					fmt.Sprint(tree.GetDarkness(x, y)) + "\n",
-			)
+	r := bufio.NewReader(f)
+	magic, err := r.Peek(len(WorldFileMagic))
+	if err == nil && bytes.Equal(magic, WorldFileMagic[:]) {
+		if err := recoverDir(regionDirFor(path)); err != nil {
+			return err
 		}
-		SaveProgressBar.IncrementPercentage(point)
-		updateSaveScreen()
-	}
-
-	if err != nil {
-		panic(err)
+		return tree.decode(r, regionDirFor(path), progress)
 	}
+	return tree.loadLegacy(r, progress)
 }
 
-func (tree *WorldTree) LoadFromFile(path string) {
-	f, err := os.Open(path)
-	if err != nil {
-		panic(err)
+// Legacy saves are all the same size
+const LegacyWorldWidth = 3000
+const LegacyWorldHeight = 2000
+
+// legacyLayers is the order of the block IDs on each line of a legacy save
+var legacyLayers = [...]int{LayerWorld, LayerBack, LayerNature, LayerLight}
+
+// loadLegacy reads the old .hln text format: one line per
+// heightmap column, then one line per tile holding the
+// world, back, nature and light IDs followed by the darkness
+func (tree *WorldTree) loadLegacy(r io.Reader, progress func(percent float32)) error {
+	if err := SetWorldSize(LegacyWorldWidth, LegacyWorldHeight); err != nil {
+		return err
 	}
-	defer f.Close()
 
 	cx := 0
 	cy := 0
 
-	point := 100.0 / float32(WorldHeight)
-	ProgressBar.SetPercentage(0)
+	progress(0)
 
-	scanner := bufio.NewScanner(f)
+	scanner := bufio.NewScanner(r)
+	line := 0
 
-	for scanner.Scan() {
+	for cx < WorldWidth && scanner.Scan() {
+		line++
 		ht, err := strconv.ParseInt(scanner.Text(), 10, 32)
 		if err != nil {
-			panic(err)
+			return fmt.Errorf("line %d: bad height: %v", line, err)
+		}
+		if err := checkHeight(cx, int(ht)); err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
 		}
 
 		HeightMap[cx] = int(ht)
-
 		cx++
-		if cx >= WorldWidth {
-			break
-		}
 	}
 
 	cx = 0
 	cy = 0
 
-	for scanner.Scan() {
+	for cx < WorldWidth && scanner.Scan() {
+		line++
 		block := scanner.Text()
 
-		if block[3:5] == "" {
-			return
+		if len(block) < 21 {
+			return fmt.Errorf("line %d: block entry too short", line)
 		}
 
-		if block[:5] != "00000" {
-			WorldMap.AddWorldBlock(cx, cy, &child.ChildCopy{
-				ID:       block[:5],
-				X:        float32(cx * BlockSize),
-				Y:        float32(cy * BlockSize),
-				Material: GetBlock(GetNameFromID(block[:3])).GetMaterial(InverseOrientationMap[block[3:5]]),
-			})
-		}
-
-		if block[5:10] != "00000" {
-			WorldMap.AddBackBlock(cx, cy, &child.ChildCopy{
-				ID:       block[5:10],
-				X:        float32(cx * BlockSize),
-				Y:        float32(cy * BlockSize),
-				Material: GetBlock(GetNameFromID(block[5:8])).GetMaterial(InverseOrientationMap[block[8:10]]),
-			})
-		}
-
-		if block[10:15] != "00000" {
-			WorldMap.AddNatureBlock(cx, cy, &child.ChildCopy{
-				ID:       block[10:15],
-				X:        float32(cx * BlockSize),
-				Y:        float32(cy * BlockSize),
-				Material: GetBlock(GetNameFromID(block[10:13])).GetMaterial(InverseOrientationMap[block[13:15]]),
-			})
-		}
-
-		if block[15:20] != "00000" {
-			WorldMap.AddLightBlock(cx, cy, &child.ChildCopy{
-				ID:       block[15:20],
-				X:        float32(cx * BlockSize),
-				Y:        float32(cy * BlockSize),
-				Material: GetBlock(GetNameFromID(block[15:18])).GetMaterial(InverseOrientationMap[block[18:20]]),
-			})
+		for i, layer := range legacyLayers {
+			id := block[i*5 : i*5+5]
+			blockID, err := strconv.ParseUint(id[:3], 10, 16)
+			if err != nil || GetBlockByID(BlockID(blockID)) == nil {
+				return fmt.Errorf("line %d: unknown block ID %s", line, id[:3])
+			}
+			orient, err := strconv.ParseUint(id[3:], 10, 8)
+			if err != nil || orient >= uint64(NumOrientations) {
+				return fmt.Errorf("line %d: unknown orientation %s", line, id[3:])
+			}
+			if blockID != uint64(BlockEmpty) {
+				tree.SetLayerBlock(layer, cx, cy, Tile{Block: BlockID(blockID), Orient: Orientation(orient)})
+			}
 		}
 
 		darkness, err := strconv.ParseFloat(block[20:], 32)
 		if err != nil {
-			panic(err)
+			return fmt.Errorf("line %d: bad darkness: %v", line, err)
 		}
-		WorldMap.SetDarkness(cx, cy, float32(darkness))
+		tree.SetDarkness(cx, cy, float32(darkness))
 
 		cy++
 		if cy >= WorldHeight {
 			cy = 0
 			cx++
 
-			ProgressBar.IncrementPercentage(point)
-			updateLoadingScreen()
+			progress(float32(cx) * 100 / float32(WorldWidth))
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		panic(err)
+		return err
+	}
+	if cx < WorldWidth {
+		return fmt.Errorf("world file ended early at column %d", cx)
 	}
+	return nil
 }
 
-func (tree *WorldTree) writeToImage() {
-	img := image.NewRGBA(image.Rect(0, 0, len(tree.blockNodes), len(tree.blockNodes[0])))
+// writeToImage writes a PNG map of the world, one pixel per block
+func (tree *WorldTree) writeToImage(path string) error {
+	img := image.NewRGBA(image.Rect(0, 0, WorldWidth, WorldHeight))
 
-	width := len(tree.blockNodes)
-	height := len(tree.blockNodes[0])
+	width := WorldWidth
+	height := WorldHeight
 
 	for x := 0; x < width; x++ {
 		for y := 0; y < height; y++ {
@@ -439,16 +548,19 @@ func (tree *WorldTree) writeToImage() {
 				}
 			}
 
-			block := GetBlock(b)
+			saveColor := GetBlock(b).SaveColor
+			if liquid := tree.GetLiquid(x, y); liquid.Level > 0 {
+				saveColor = LiquidTypes[liquid.Type].SaveColor
+			}
 
 			img.Set(x, height-y, color.RGBA{
-				uint8(block.SaveColor[0]),
-				uint8(block.SaveColor[1]),
-				uint8(block.SaveColor[2]), 255})
+				uint8(saveColor[0]),
+				uint8(saveColor[1]),
+				uint8(saveColor[2]), 255})
 		}
 	}
 
-	f, _ := os.OpenFile("out.png", os.O_WRONLY|os.O_CREATE, 0600)
-	defer f.Close()
-	png.Encode(f, img)
+	return writeFileAtomic(path, func(w io.Writer) error {
+		return png.Encode(w, img)
+	})
 }
@@ -266,10 +266,20 @@ var structures = []Structure{
 	goblinCamp, goblinFortress,
 }
 
-func generateStructures() {
+func generateStructures(world *WorldTree, rng *rand.Rand) {
 	for _, current := range structures {
-		RightStartx := rand.Intn(current.Width/2) + current.XBeginning + WorldWidth/2
-		LeftStartx := WorldWidth/2 - rand.Intn(current.Width/2) - current.XBeginning
+		// Structures are laid out for the default world size,
+		// so keep them at the same relative distance from spawn
+		xBeginning := current.XBeginning * WorldWidth / DefaultWorldSize.Width
+
+		RightStartx := rng.Intn(current.Width/2) + xBeginning + WorldWidth/2
+		LeftStartx := WorldWidth/2 - rng.Intn(current.Width/2) - xBeginning
+
+		span := structureSpan(current)
+		if RightStartx+span >= WorldWidth || LeftStartx-span < 0 {
+			continue
+		}
+
 		currentX := RightStartx
 		if current.PlaceMethod == "mesh" {
 			for i, building := range current.Layout {
@@ -287,12 +297,12 @@ func generateStructures() {
 
 				for y := 0; y < height; y++ {
 					for x := 0; x < len(building.Layout[y]); x++ {
-						if WorldMap.GetWorldBlockID(currentX+x, lowestY+(height-y)) == "00000" {
-							if bname := GetBlockName(building.Layout[y][x]); bname == "backdirt" {
-								createBackBlock(currentX+x, lowestY+(height-y), bname)
+						if world.GetWorldBlockID(currentX+x, lowestY+(height-y)) == BlockEmpty {
+							if bname := GetNameFromID(BlockID(building.Layout[y][x])); bname == "backdirt" {
+								world.createBackBlock(currentX+x, lowestY+(height-y), bname)
 							} else {
-								WorldMap.RemoveNatureBlock(currentX+x, lowestY+(height-y))
-								createWorldBlock(currentX+x, lowestY+(height-y), bname)
+								world.RemoveNatureBlock(currentX+x, lowestY+(height-y))
+								world.createWorldBlock(currentX+x, lowestY+(height-y), bname)
 							}
 						}
 					}
@@ -316,12 +326,12 @@ func generateStructures() {
 				leftLayout := flipMatrix(building.Layout)
 				for y := 0; y < height; y++ {
 					for x := 0; x < len(leftLayout[y]); x++ {
-						if WorldMap.GetWorldBlockID(currentX+x, lowestY+(height-y)) == "00000" {
-							if bname := GetBlockName(leftLayout[y][x]); bname == "backdirt" {
-								createBackBlock(currentX+x, lowestY+(height-y), bname)
+						if world.GetWorldBlockID(currentX+x, lowestY+(height-y)) == BlockEmpty {
+							if bname := GetNameFromID(BlockID(leftLayout[y][x])); bname == "backdirt" {
+								world.createBackBlock(currentX+x, lowestY+(height-y), bname)
 							} else {
-								WorldMap.RemoveNatureBlock(currentX+x, lowestY+(height-y))
-								createWorldBlock(currentX+x, lowestY+(height-y), bname)
+								world.RemoveNatureBlock(currentX+x, lowestY+(height-y))
+								world.createWorldBlock(currentX+x, lowestY+(height-y), bname)
 							}
 						}
 					}
@@ -331,6 +341,20 @@ func generateStructures() {
 	}
 }
 
+// structureSpan returns how far a structure can reach from where it starts
+func structureSpan(s Structure) int {
+	span, widest := 0, 0
+	for i, building := range s.Layout {
+		if i != 0 {
+			span += s.Spacing[i-1] + 10
+		}
+		if w := len(building.Layout[0]); w > widest {
+			widest = w
+		}
+	}
+	return span + widest
+}
+
 func fillStructureFloor(startx int, starty int, endx int, endy int) {
 
 }
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"math/rand"
+)
+
+//  --------------------------------------------------
+//  Trees.go contains tree generation.
+//
+//  A tree is a trunk of nature blocks standing on a
+//  bottom root, with a root on each side, branches
+//  along the trunk and a canopy of leaves on top. Trunks,
+//  roots and branches are in the nature layer so they
+//  can be walked through, while leaves are world blocks.
+//
+//  Every biome has its own tree species (see biome.go).
+//  Cutting any part of the trunk fells everything of the
+//  tree connected to it from there up (see worldedit.go).
+//  --------------------------------------------------
+
+// TreeSpecies is the shape of the trees of a biome
+type TreeSpecies struct {
+	Name string
+
+	// Trunk height in blocks, not counting the bottom root
+	MinHeight int
+	MaxHeight int
+
+	// Half the width and height of the leaf canopy
+	CanopyWidth  int
+	CanopyHeight int
+
+	// Chance of a branch at each block of the trunk
+	BranchChance float32
+}
+
+var oakTree = TreeSpecies{
+	Name:         "oak",
+	MinHeight:    5,
+	MaxHeight:    9,
+	CanopyWidth:  3,
+	CanopyHeight: 2,
+	BranchChance: 0.2,
+}
+
+var tallOakTree = TreeSpecies{
+	Name:         "tall oak",
+	MinHeight:    9,
+	MaxHeight:    15,
+	CanopyWidth:  4,
+	CanopyHeight: 3,
+	BranchChance: 0.25,
+}
+
+var pineTree = TreeSpecies{
+	Name:         "pine",
+	MinHeight:    7,
+	MaxHeight:    12,
+	CanopyWidth:  2,
+	CanopyHeight: 5,
+	BranchChance: 0,
+}
+
+// Blocks between the canopies of neighbouring trees
+const TreeSpacing = 2
+
+// treeBlocks are the nature blocks a tree is made of, besides leaves
+var treeBlocks = map[string]bool{
+	"treeTrunk":      true,
+	"treeBottomRoot": true,
+	"treeLeftRoot":   true,
+	"treeRightRoot":  true,
+	"treeBranchL1":   true,
+	"treeBranchR1":   true,
+}
+
+//  --------------------------------------------------
+//  Generation
+//  --------------------------------------------------
+
+// canGrowTree returns whether a column has room for a tree of a species
+func (tree *WorldTree) canGrowTree(x int, species *TreeSpecies) bool {
+	if x < species.CanopyWidth+2 || x >= WorldWidth-species.CanopyWidth-2 {
+		return false
+	}
+
+	ground := HeightMap[x]
+	if ground+species.MaxHeight+species.CanopyHeight+2 >= WorldHeight {
+		return false
+	}
+
+	// The roots need flat ground made of the biome's surface
+	surface := getBiome(x).SurfaceBlock
+	for dx := -1; dx <= 1; dx++ {
+		if HeightMap[x+dx] != ground ||
+			tree.GetWorldBlockName(x+dx, ground) != surface ||
+			tree.GetWorldBlockName(x+dx, ground+1) != "sky" ||
+			tree.GetLiquid(x+dx, ground+1).Level > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// growTree grows a tree with its bottom root on top of the ground at x
+func (tree *WorldTree) growTree(rng *rand.Rand, x int, species *TreeSpecies) {
+	base := HeightMap[x] + 1
+
+	height := species.MinHeight
+	if species.MaxHeight > species.MinHeight {
+		height += rng.Intn(species.MaxHeight - species.MinHeight + 1)
+	}
+	top := base + height
+
+	// Roots
+	tree.createNatureBlock(x, base, "treeBottomRoot")
+	tree.createNatureBlock(x-1, base, "treeLeftRoot")
+	tree.createNatureBlock(x+1, base, "treeRightRoot")
+
+	// Trunk and branches, keeping clear of the roots and the canopy
+	lastBranch := 0
+	for y := base + 1; y <= top; y++ {
+		tree.createNatureBlock(x, y, "treeTrunk")
+
+		if y < base+3 || y > top-species.CanopyHeight-1 || y-lastBranch < 2 {
+			continue
+		}
+		if rng.Float32() < species.BranchChance {
+			side := rng.Intn(2)*2 - 1
+			if side < 0 {
+				tree.createNatureBlock(x-1, y, "treeBranchL1")
+			} else {
+				tree.createNatureBlock(x+1, y, "treeBranchR1")
+			}
+			tree.growLeaves(x+side*2, y)
+			tree.growLeaves(x+side*2, y+1)
+			lastBranch = y
+		}
+	}
+
+	// Canopy, a rough ellipse around the top of the trunk
+	w, h := species.CanopyWidth, species.CanopyHeight
+	for dx := -w; dx <= w; dx++ {
+		for dy := -h; dy <= h; dy++ {
+			edge := float32(dx*dx)/float32((w+1)*(w+1)) + float32(dy*dy)/float32((h+1)*(h+1))
+			if edge > 1 || (edge > 0.6 && rng.Float32() < 0.4) {
+				continue
+			}
+			tree.growLeaves(x+dx, top+dy+1)
+		}
+	}
+}
+
+// growLeaves places leaves on a block if nothing is there yet
+func (tree *WorldTree) growLeaves(x, y int) {
+	if isInWorld(x, y) && tree.GetWorldBlockName(x, y) == "sky" {
+		tree.createWorldBlock(x, y, "leaves")
+	}
+}
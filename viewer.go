@@ -1,3 +1,6 @@
+//go:build !gen
+// +build !gen
+
 package main
 
 import (
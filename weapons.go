@@ -1,4 +1,174 @@
+//go:build !gen
+// +build !gen
+
 package main
 
-type Weapon struct {
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"rapidengine/child"
+	"rapidengine/cmd"
+	"rapidengine/geometry"
+)
+
+//  --------------------------------------------------
+//  Weapons.go contains weapons, which the player swings
+//  at enemies instead of punching them.
+//
+//  While a weapon is in the active hotbar slot its
+//  hitbox replaces the player's AttackBox, and left click
+//  swings it. A swing plays the weapon's animation, and
+//  enemies in the hitbox are hit on its hit frame, the
+//  same way punches are. Every weapon has an icon and a
+//  folder of swing frames in assets/swords, drawn with
+//  the grip in the middle of the frame.
+//  --------------------------------------------------
+
+// SwingStyle is a way of swinging a weapon
+type SwingStyle struct {
+	// Frame of the swing animation that hits
+	HitFrame int
+
+	// Height of the hitbox, as a fraction of the reach
+	Height float32
+}
+
+// Every swing animation has this many frames
+const NumSwingFrames = 5
+
+var SwingStyles = map[string]SwingStyle{
+	"slash": {HitFrame: 4, Height: 1.5},
+	"stab":  {HitFrame: 4, Height: 0.3},
+}
+
+// Weapon textures are relative to this directory
+const WeaponTextureDir = "./assets/swords"
+
+// Width and height of a swing frame, and how much bigger it is drawn
+const SwingFrameSize = 160
+const WeaponScale = 2
+
+// Where the player holds a weapon, from the bottom middle of their hitbox
+const WeaponHandX = 10
+const WeaponHandY = 60
+
+// Crits do this many times the damage
+const CritMultiplier = 2
+
+var WeaponChild *child.Child2D
+
+func loadWeapons() {
+	for name, weapon := range Weapons {
+		Engine.TextureControl.NewTexture(filepath.Join(WeaponTextureDir, name+".png"), name, "pixel")
+		weapon.Icon = Engine.MaterialControl.NewBasicMaterial()
+		weapon.Icon.DiffuseLevel = 1
+		weapon.Icon.DiffuseMap = Engine.TextureControl.GetTexture(name)
+
+		weapon.Material = Engine.MaterialControl.NewBasicMaterial()
+		weapon.Material.DiffuseLevel = 1
+		weapon.Material.EnableAnimation()
+		for i := 1; i <= NumSwingFrames; i++ {
+			frame := fmt.Sprintf("%s_s%d", name, i)
+			Engine.TextureControl.NewTexture(filepath.Join(WeaponTextureDir, name, fmt.Sprintf("%d.png", i)), frame, "pixel")
+			if i == SwingStyles[weapon.Style].HitFrame {
+				weapon.Material.AddHitFrame(Engine.TextureControl.GetTexture(frame), "swing")
+			} else {
+				weapon.Material.AddFrame(Engine.TextureControl.GetTexture(frame), "swing")
+			}
+		}
+		weapon.Material.SetAnimationFPS("swing", NumSwingFrames*weapon.SwingSpeed)
+	}
+
+	WeaponChild = Engine.ChildControl.NewChild2D()
+	WeaponChild.AttachMesh(geometry.NewRectangle())
+	WeaponChild.ScaleX = SwingFrameSize * WeaponScale
+	WeaponChild.ScaleY = SwingFrameSize * WeaponScale
+}
+
+// AttackBox returns the hitbox of a weapon, placed like the punch's
+func (w *Weapon) AttackBox() AABB {
+	height := w.Reach * SwingStyles[w.Style].Height
+	return AABB{
+		OffX:   0,
+		OffY:   WeaponHandY - height/2,
+		Width:  w.Reach,
+		Height: height,
+	}
+}
+
+// heldWeapon returns the weapon in the active hotbar slot, or nil
+func (p *Player) heldWeapon() *Weapon {
+	return GetWeapon(p.HeldItem().Name)
+}
+
+// updateAttackBox uses the hitbox of the weapon being swung or
+// held, and the punch's without one
+func (p *Player) updateAttackBox() {
+	weapon := p.Swing
+	if weapon == nil {
+		weapon = p.heldWeapon()
+	}
+	if weapon == nil {
+		p.AttackBox = PunchBox
+		return
+	}
+	p.AttackBox = weapon.AttackBox()
+}
+
+// Attack swings the held weapon, or punches without one
+func (p *Player) Attack() {
+	weapon := p.heldWeapon()
+	if weapon == nil {
+		p.Punch()
+		return
+	}
+
+	p.Swing = weapon
+	weapon.Material.Flipped = p.PlayerMaterial.Flipped
+	weapon.Material.PlayAnimationOnceCallback("swing", p.DoneSwing, p.SwingHitFrame)
+	WeaponChild.AttachMaterial(weapon.Material)
+
+	p.PlayerMaterial.PlayAnimationOnce("punch")
+	p.AttackCooldown = 1 / weapon.SwingSpeed
+	p.CurrentAnim = "punch"
+	p.Attacking = true
+	p.PlayerChild.VX = 0
+}
+
+// SwingHitFrame hits every enemy in the weapon's hitbox
+func (p *Player) SwingHitFrame() {
+	if p.Swing == nil {
+		return
+	}
+	for _, enemy := range EM.CheckPlayerCollisions() {
+		damage := p.damageDealt(p.Swing.Damage)
+		crit := rand.Float64() < p.Swing.CritChance
+		if crit {
+			damage *= CritMultiplier
+		}
+		hitEnemy(enemy, damage, p.CenterX+p.Hitbox1.DAABB.Width/2, p.Swing.Knockback, crit)
+	}
+}
+
+// DoneSwing is called when the swing animation ends
+func (p *Player) DoneSwing() {
+	p.Swing = nil
+	p.DoneAttack()
+}
+
+// renderWeaponSwing draws the weapon being swung in the player's hand
+func renderWeaponSwing(renderer *cmd.Renderer) {
+	if Player1.Swing == nil {
+		return
+	}
+	dir := float32(1)
+	if Player1.PlayerMaterial.Flipped != 0 {
+		dir = -1
+	}
+	handX := Player1.CenterX + Player1.Hitbox1.DAABB.Width/2 + dir*WeaponHandX
+	handY := Player1.CenterY + WeaponHandY
+	WeaponChild.SetPosition(handX-WeaponChild.ScaleX/2, handY-WeaponChild.ScaleY/2)
+	WeaponChild.Darkness = Player1.PlayerChild.Darkness
+	renderer.RenderChild(WeaponChild)
 }
@@ -6,188 +6,147 @@ type WorldBlock struct {
 	Darkness    float32
 }
 
-func createAllExtraBackdirt() {
+func (tree *WorldTree) createAllExtraBackdirt() {
 	for x := 2; x < WorldWidth-2; x++ {
 		for y := 2; y < WorldHeight-2; y++ {
-			createSingleExtraBackdirt(x, y)
+			tree.createSingleExtraBackdirt(x, y)
 		}
 	}
 }
 
-func createSingleExtraBackdirt(x, y int) {
-	orient := WorldMap.GetWorldBlockOrientation(x, y)
-	if orient != "E" && orient != "NN" && WorldMap.GetWorldBlockID(x, y) != "00000" {
-		if WorldMap.GetWorldBlockID(x+1, y) == "00000" ||
-			WorldMap.GetWorldBlockID(x-1, y) == "00000" ||
-			WorldMap.GetWorldBlockID(x, y+1) == "00000" ||
-			WorldMap.GetWorldBlockID(x, y-1) == "00000" {
+func (tree *WorldTree) createSingleExtraBackdirt(x, y int) {
+	orient := tree.GetWorldBlockOrientation(x, y)
+	if orient != OrientNN && tree.GetWorldBlockID(x, y) != BlockEmpty {
+		if tree.GetWorldBlockID(x+1, y) == BlockEmpty ||
+			tree.GetWorldBlockID(x-1, y) == BlockEmpty ||
+			tree.GetWorldBlockID(x, y+1) == BlockEmpty ||
+			tree.GetWorldBlockID(x, y-1) == BlockEmpty {
 			if y <= HeightMap[x] {
-				createBackBlock(x, y, "backdirt")
+				tree.createBackBlock(x, y, "backdirt")
 			}
 		} else {
-			createBackBlock(x, y, "backdirt")
+			tree.createBackBlock(x, y, "backdirt")
 		}
 	}
 }
 
-func orientBlocks(name string, topBlock bool) {
+func (tree *WorldTree) orientBlocks(name string, topBlock bool) {
 	for x := 1; x < WorldWidth-1; x++ {
 		for y := 1; y < WorldHeight-1; y++ {
-			orientSingleBlock(name, topBlock, x, y)
+			tree.orientSingleBlock(name, topBlock, x, y)
 		}
 	}
 }
 
-func orientSingleBlock(name string, topBlock bool, x, y int) {
-	if WorldMap.GetWorldBlockName(x, y) == name {
-		WorldMap.SetWorldBlockOrientation(x, y, getWorldBlockOrientation(name, topBlock, x, y))
-		WorldMap.UpdateWorldBlockMaterial(x, y)
-	} else if WorldMap.GetBackBlockName(x, y) == name {
-		WorldMap.SetBackBlockOrientation(x, y, getBackBlockOrientation(name, topBlock, x, y))
-		WorldMap.UpdateBackBlockMaterial(x, y)
+// orientWorldBlocks orients every world block with orientations
+func (tree *WorldTree) orientWorldBlocks() {
+	for x := 1; x < WorldWidth-1; x++ {
+		for y := 1; y < WorldHeight-1; y++ {
+			if name := tree.GetWorldBlockName(x, y); GetBlock(name).OrientEnabled {
+				tree.orientSingleBlock(name, true, x, y)
+			}
+		}
+	}
+}
+
+func (tree *WorldTree) orientSingleBlock(name string, topBlock bool, x, y int) {
+	if tree.GetWorldBlockName(x, y) == name {
+		tree.SetWorldBlockOrientation(x, y, tree.getWorldBlockOrientation(name, topBlock, x, y))
+		tree.UpdateWorldBlockMaterial(x, y)
+	} else if tree.GetBackBlockName(x, y) == name {
+		tree.SetBackBlockOrientation(x, y, tree.getBackBlockOrientation(name, topBlock, x, y))
+		tree.UpdateBackBlockMaterial(x, y)
 	}
 }
 
-func getWorldBlockOrientation(name string, topBlock bool, x, y int) string {
+func (tree *WorldTree) getWorldBlockOrientation(name string, topBlock bool, x, y int) Orientation {
 	above := false
 	under := false
 	left := false
 	right := false
-	if WorldMap.GetWorldBlockName(x-1, y) == "sky" || (WorldMap.GetBackBlockName(x-1, y) == "backdirt" && WorldMap.GetWorldBlockName(x-1, y) == "sky") {
+	if tree.GetWorldBlockName(x-1, y) == "sky" || (tree.GetBackBlockName(x-1, y) == "backdirt" && tree.GetWorldBlockName(x-1, y) == "sky") {
 		left = true
 	}
-	if WorldMap.GetWorldBlockName(x+1, y) == "sky" || (WorldMap.GetBackBlockName(x+1, y) == "backdirt" && WorldMap.GetWorldBlockName(x+1, y) == "sky") {
+	if tree.GetWorldBlockName(x+1, y) == "sky" || (tree.GetBackBlockName(x+1, y) == "backdirt" && tree.GetWorldBlockName(x+1, y) == "sky") {
 		right = true
 	}
-	if WorldMap.GetWorldBlockName(x, y-1) == "sky" || (WorldMap.GetBackBlockName(x, y-1) == "backdirt" && WorldMap.GetWorldBlockName(x, y-1) == "sky") {
+	if tree.GetWorldBlockName(x, y-1) == "sky" || (tree.GetBackBlockName(x, y-1) == "backdirt" && tree.GetWorldBlockName(x, y-1) == "sky") {
 		under = true
 	}
-	if WorldMap.GetWorldBlockName(x, y+1) == "sky" || (WorldMap.GetBackBlockName(x, y+1) == "backdirt" && WorldMap.GetWorldBlockName(x, y+1) == "sky") {
+	if tree.GetWorldBlockName(x, y+1) == "sky" || (tree.GetBackBlockName(x, y+1) == "backdirt" && tree.GetWorldBlockName(x, y+1) == "sky") {
 		above = true
 	}
 	return getOrientationLetter(left, right, under, above, topBlock)
 }
 
-func getBackBlockOrientation(name string, topBlock bool, x, y int) string {
+func (tree *WorldTree) getBackBlockOrientation(name string, topBlock bool, x, y int) Orientation {
 	above := false
 	under := false
 	left := false
 	right := false
-	if WorldMap.GetWorldBlockName(x-1, y) == "sky" && WorldMap.GetBackBlockName(x-1, y) != "backdirt" {
+	if tree.GetWorldBlockName(x-1, y) == "sky" && tree.GetBackBlockName(x-1, y) != "backdirt" {
 		left = true
 	}
-	if WorldMap.GetWorldBlockName(x+1, y) == "sky" && WorldMap.GetBackBlockName(x+1, y) != "backdirt" {
+	if tree.GetWorldBlockName(x+1, y) == "sky" && tree.GetBackBlockName(x+1, y) != "backdirt" {
 		right = true
 	}
-	if WorldMap.GetWorldBlockName(x, y-1) == "sky" && WorldMap.GetBackBlockName(x, y-1) != "backdirt" {
+	if tree.GetWorldBlockName(x, y-1) == "sky" && tree.GetBackBlockName(x, y-1) != "backdirt" {
 		under = true
 	}
-	if WorldMap.GetWorldBlockName(x, y+1) == "sky" && WorldMap.GetBackBlockName(x, y+1) != "backdirt" {
+	if tree.GetWorldBlockName(x, y+1) == "sky" && tree.GetBackBlockName(x, y+1) != "backdirt" {
 		above = true
 	}
 	return getOrientationLetter(left, right, under, above, topBlock)
 }
 
-func getOrientationLetter(left, right, under, above, topBlock bool) string {
+func getOrientationLetter(left, right, under, above, topBlock bool) Orientation {
 	if left && right && under && above {
-		return "AA"
+		return OrientAA
 	}
 	if left && right && !under && !above {
-		return "AN"
+		return OrientAN
 	}
 	if !left && !right && under && above {
-		return "NA"
+		return OrientNA
 	}
 	if left && !right && under && above {
-		return "LA"
+		return OrientLA
 	}
 	if !left && right && under && above {
-		return "RA"
+		return OrientRA
 	}
 	if left && right && !under && above {
-		return "AT"
+		return OrientAT
 	}
 	if left && right && under && !above {
-		return "AB"
+		return OrientAB
 	}
 	if left && !right && !under && !above {
-		return "LN"
+		return OrientLN
 	}
 	if !left && right && !under && !above {
-		return "RN"
+		return OrientRN
 	}
 	if !left && !right && !under && above && topBlock {
-		return "NT"
+		return OrientNT
 	}
 	if !left && !right && under && !above {
-		return "NB"
+		return OrientNB
 	}
 	if !left && right && under && !above {
-		return "RB"
+		return OrientRB
 	}
 	if left && !right && under && !above {
-		return "LB"
+		return OrientLB
 	}
 	if !left && !right && !under && !above {
-		return "NN"
+		return OrientNN
 	}
 	if !left && right && !under && above {
-		return "RT"
+		return OrientRT
 	}
 	if left && !right && !under && above {
-		return "LT"
-	}
-	return "NN"
-}
-
-func placeBlock(x, y int, block string) {
-	if WorldMap.GetWorldBlockID(x, y) != "00000" {
-		return
-	}
-	if block == "torch" {
-		createLightBlock(x, y, block)
-	} else {
-		createWorldBlock(x, y, block)
-	}
-
-	orientSingleBlock(block, true, x, y)
-
-	FixLightingAt(x, y)
-
-	fixBlock(x+1, y)
-	fixBlock(x, y+1)
-	fixBlock(x-1, y)
-	fixBlock(x, y-1)
-}
-
-func destroyBlock(x, y int) {
-	if WorldMap.GetWorldBlockID(x, y) == "00000" {
-		return
-	}
-
-	WorldMap.RemoveWorldBlock(x, y)
-	WorldMap.RemoveGrassBlock(x, y)
-	WorldMap.RemoveNatureBlock(x, y)
-	WorldMap.RemoveBackBlock(x, y)
-
-	if y <= HeightMap[x] {
-		createBackBlock(x, y, "backdirt")
-		orientSingleBlock("backdirt", true, x, y)
-	}
-
-	FixLightingAt(x, y)
-
-	fixBlock(x+1, y)
-	fixBlock(x, y+1)
-	fixBlock(x-1, y)
-	fixBlock(x, y-1)
-}
-
-func fixBlock(x, y int) {
-	if WorldMap.GetWorldBlockID(x, y) == "00000" {
-		return
+		return OrientLT
 	}
-	orientSingleBlock(WorldMap.GetWorldBlockName(x, y), true, x, y)
-	createSingleExtraBackdirt(x, y)
-	orientSingleBlock("backdirt", true, x, y)
+	return OrientNN
 }
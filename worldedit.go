@@ -0,0 +1,172 @@
+//go:build !gen
+// +build !gen
+
+package main
+
+//  --------------------------------------------------
+//  Worldedit.go contains the changes the player makes
+//  to the world while playing: placing and destroying
+//  blocks, and felling trees. They drop items and let
+//  blocks fall, so unlike the rest of the world code
+//  they are part of the game only.
+//  --------------------------------------------------
+
+// placeBlock places a block on its layer, returning false if there
+// is a world block in the way or the layer is already taken there
+func placeBlock(x, y int, block string) bool {
+	layer := GetBlock(block).Layer
+	if WorldMap.GetWorldBlockID(x, y) != BlockEmpty || WorldMap.GetLayerTile(layer, x, y).Block != BlockEmpty {
+		return false
+	}
+	switch layer {
+	case LayerBack:
+		WorldMap.createBackBlock(x, y, block)
+	case LayerNature:
+		WorldMap.createNatureBlock(x, y, block)
+	case LayerGrass:
+		WorldMap.createGrassBlock(x, y, block)
+	case LayerLight:
+		WorldMap.createLightBlock(x, y, block)
+	default:
+		// Blocks push out the liquid they are placed in
+		WorldMap.SetLiquid(x, y, Liquid{})
+		WorldMap.createWorldBlock(x, y, block)
+	}
+
+	WorldMap.orientSingleBlock(block, true, x, y)
+
+	WorldMap.FixLightingAt(x, y)
+
+	fixBlock(x+1, y)
+	fixBlock(x, y+1)
+	fixBlock(x-1, y)
+	fixBlock(x, y-1)
+
+	// Sand and gravel placed on nothing fall right away
+	dropUnsupportedBlocks(x, y)
+	return true
+}
+
+func destroyBlock(x, y int) {
+	// Cutting any part of a tree brings all of it down
+	if WorldMap.GetWorldBlockID(x, y) == BlockEmpty && treeBlocks[WorldMap.GetNatureBlockName(x, y)] {
+		fellTree(x, y)
+		return
+	}
+
+	if WorldMap.GetWorldBlockID(x, y) == BlockEmpty {
+		return
+	}
+
+	dropBlockItems(x, y, WorldMap.GetWorldBlockName(x, y))
+	WorldMap.RemoveWorldBlock(x, y)
+	WorldMap.RemoveGrassBlock(x, y)
+	WorldMap.RemoveNatureBlock(x, y)
+	WorldMap.RemoveBackBlock(x, y)
+
+	if y <= HeightMap[x] {
+		WorldMap.createBackBlock(x, y, "backdirt")
+		WorldMap.orientSingleBlock("backdirt", true, x, y)
+	}
+
+	WorldMap.FixLightingAt(x, y)
+	WorldMap.WakeLiquids(x, y)
+
+	// Sand and gravel above fall into the gap
+	dropUnsupportedBlocks(x, y+1)
+
+	fixBlock(x+1, y)
+	fixBlock(x, y+1)
+	fixBlock(x-1, y)
+	fixBlock(x, y-1)
+}
+
+func fixBlock(x, y int) {
+	if WorldMap.GetWorldBlockID(x, y) == BlockEmpty {
+		return
+	}
+	WorldMap.orientSingleBlock(WorldMap.GetWorldBlockName(x, y), true, x, y)
+	WorldMap.createSingleExtraBackdirt(x, y)
+	WorldMap.orientSingleBlock("backdirt", true, x, y)
+}
+
+//  --------------------------------------------------
+//  Felling
+//  --------------------------------------------------
+
+// isTreeBlock returns whether a block is part of a tree
+func isTreeBlock(x, y int) bool {
+	return treeBlocks[WorldMap.GetNatureBlockName(x, y)] || WorldMap.GetWorldBlockName(x, y) == "leaves"
+}
+
+// trunkColumn returns the column of the trunk a tree block grows from
+func trunkColumn(x, y int) int {
+	switch WorldMap.GetNatureBlockName(x, y) {
+	case "treeLeftRoot", "treeBranchL1":
+		return x + 1
+	case "treeRightRoot", "treeBranchR1":
+		return x - 1
+	}
+	return x
+}
+
+// treeReach returns how far from its trunk a tree at x can have leaves
+func treeReach(x int) int {
+	// Leaves at the end of branches
+	reach := 2
+	for _, species := range getBiome(x).Trees {
+		if species.CanopyWidth > reach {
+			reach = species.CanopyWidth
+		}
+	}
+	return reach
+}
+
+// fellTree removes every tree block connected to the one at x, y,
+// roots and stump included, so no trunk is left floating or standing
+// without a top. Only blocks within reach of its trunk are followed,
+// and never another trunk, so a canopy touching another tree's leaves
+// the other tree standing. Returns the number of blocks removed.
+func fellTree(x, y int) int {
+	type pos struct{ x, y int }
+
+	trunk := trunkColumn(x, y)
+	reach := treeReach(trunk)
+
+	visited := map[pos]bool{{x, y}: true}
+	queue := []pos{{x, y}}
+
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+
+		if WorldMap.GetWorldBlockName(p.x, p.y) == "leaves" {
+			dropBlockItems(p.x, p.y, "leaves")
+			WorldMap.RemoveWorldBlock(p.x, p.y)
+		}
+		dropBlockItems(p.x, p.y, WorldMap.GetNatureBlockName(p.x, p.y))
+		WorldMap.RemoveNatureBlock(p.x, p.y)
+
+		for _, n := range []pos{{p.x + 1, p.y}, {p.x - 1, p.y}, {p.x, p.y + 1}, {p.x, p.y - 1}} {
+			if visited[n] || abs(n.x-trunk) > reach || !isInWorld(n.x, n.y) || !isTreeBlock(n.x, n.y) {
+				continue
+			}
+			if treeBlocks[WorldMap.GetNatureBlockName(n.x, n.y)] && trunkColumn(n.x, n.y) != trunk {
+				continue
+			}
+			visited[n] = true
+			queue = append(queue, n)
+		}
+	}
+
+	for p := range visited {
+		WorldMap.FixLightingAt(p.x, p.y)
+		WorldMap.WakeLiquids(p.x, p.y)
+	}
+	for p := range visited {
+		fixBlock(p.x+1, p.y)
+		fixBlock(p.x-1, p.y)
+		fixBlock(p.x, p.y-1)
+	}
+	return len(visited)
+}
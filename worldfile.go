@@ -0,0 +1,583 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+//  --------------------------------------------------
+//  Worldfile.go contains the binary world format.
+//
+//  Layout:
+//  A fixed header (magic, format version, world size,
+//  seed and chunk size) followed by a list of tagged
+//  sections. Each section is a 4 byte tag, a uint32
+//  payload length and the payload itself, so readers
+//  can skip sections they don't understand.
+//
+//  Blocks are stored in ChunkSize x ChunkSize chunks.
+//  Every layer of a chunk is run-length encoded as
+//  (count, block, orientation, metadata) runs, followed
+//  by the run-length encoded darkness and, since version
+//  3, the run-length encoded liquids. Runs before version
+//  4 have no metadata. Since version 2 chunks live in
+//  region files next to the world file (see region.go);
+//  version 1 files hold one CHNK section per chunk.
+//
+//  Chunks are read in the format of the world file they
+//  belong to, and the first save of an older world
+//  rewrites all of them in the current format.
+//
+//  The PLYR and ENTS sections hold the player and the
+//  live enemies, the ITEM section holds the dropped
+//  items and the FALL section the blocks still falling
+//  (see entities.go). Since version 5 the player
+//  has an inventory instead of a hotbar of block names,
+//  and since version 6 their equipment follows it. The
+//  BIOM section holds the biome names followed by the
+//  biome of every column; worlds without one are plains.
+//  --------------------------------------------------
+
+// WorldFileMagic is the first four bytes of every binary world file
+var WorldFileMagic = [4]byte{'H', 'L', 'N', 'W'}
+
+// WorldFormatVersion is bumped whenever the binary layout changes
+const WorldFormatVersion = 6
+
+// ChunkSize is the width and height of a chunk in blocks
+const ChunkSize = 64
+
+// Section tags
+var (
+	sectionHeightMap = [4]byte{'H', 'G', 'H', 'T'}
+	sectionChunk     = [4]byte{'C', 'H', 'N', 'K'}
+	sectionPlayer    = [4]byte{'P', 'L', 'Y', 'R'}
+	sectionEnemies   = [4]byte{'E', 'N', 'T', 'S'}
+	sectionBiomes    = [4]byte{'B', 'I', 'O', 'M'}
+	sectionItems     = [4]byte{'I', 'T', 'E', 'M'}
+	sectionFalling   = [4]byte{'F', 'A', 'L', 'L'}
+)
+
+// Block layers, in the order they are stored in a chunk
+const (
+	LayerWorld = iota
+	LayerBack
+	LayerNature
+	LayerGrass
+	LayerLight
+	NumLayers
+)
+
+// WorldHeader is the fixed-size header at the start of a world file
+type WorldHeader struct {
+	Magic     [4]byte
+	Version   uint16
+	Width     uint32
+	Height    uint32
+	Seed      int64
+	ChunkSize uint16
+}
+
+var byteOrder = binary.LittleEndian
+
+//  --------------------------------------------------
+//  Encoding
+//  --------------------------------------------------
+
+func newWorldHeader() WorldHeader {
+	return WorldHeader{
+		Magic:     WorldFileMagic,
+		Version:   WorldFormatVersion,
+		Width:     uint32(WorldWidth),
+		Height:    uint32(WorldHeight),
+		Seed:      Seed,
+		ChunkSize: ChunkSize,
+	}
+}
+
+// encode writes the world file of a snapshot (see save.go)
+func (s *worldSnapshot) encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	if err := binary.Write(bw, byteOrder, &s.header); err != nil {
+		return err
+	}
+
+	var payload bytes.Buffer
+
+	binary.Write(&payload, byteOrder, s.heightMap)
+	if err := writeSection(bw, sectionHeightMap, payload.Bytes()); err != nil {
+		return err
+	}
+
+	payload.Reset()
+	encodeBiomes(&payload, s.biomeMap)
+	if err := writeSection(bw, sectionBiomes, payload.Bytes()); err != nil {
+		return err
+	}
+
+	if err := s.entities.encode(bw); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+func writeSection(w io.Writer, tag [4]byte, payload []byte) error {
+	if _, err := w.Write(tag[:]); err != nil {
+		return err
+	}
+	if err := binary.Write(w, byteOrder, uint32(len(payload))); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// chunkTiles is a copy of everything a chunk saves, indexed by
+// position within the chunk, so it can be encoded off the game thread
+type chunkTiles struct {
+	pos      ChunkPos
+	tiles    [NumLayers][ChunkSize][ChunkSize]Tile
+	darkness [ChunkSize][ChunkSize]float32
+	liquids  [ChunkSize][ChunkSize]Liquid
+}
+
+// copyChunk copies what a chunk saves out of the tree
+func (tree *WorldTree) copyChunk(pos ChunkPos) *chunkTiles {
+	t := &chunkTiles{pos: pos}
+	x0, y0, x1, y1 := chunkBounds(pos.X, pos.Y)
+	for x := x0; x < x1; x++ {
+		for y := y0; y < y1; y++ {
+			for layer := 0; layer < NumLayers; layer++ {
+				t.tiles[layer][x-x0][y-y0] = tree.GetLayerTile(layer, x, y)
+			}
+			t.darkness[x-x0][y-y0] = tree.GetDarkness(x, y)
+			t.liquids[x-x0][y-y0] = tree.GetLiquid(x, y)
+		}
+	}
+	return t
+}
+
+func encodeChunk(buf *bytes.Buffer, t *chunkTiles) {
+	binary.Write(buf, byteOrder, uint16(t.pos.X))
+	binary.Write(buf, byteOrder, uint16(t.pos.Y))
+
+	x0, y0, x1, y1 := chunkBounds(t.pos.X, t.pos.Y)
+	w, h := x1-x0, y1-y0
+
+	for layer := 0; layer < NumLayers; layer++ {
+		var runTile Tile
+		runLength := uint16(0)
+
+		for x := 0; x < w; x++ {
+			for y := 0; y < h; y++ {
+				tile := t.tiles[layer][x][y]
+				if runLength > 0 && tile == runTile && runLength < math.MaxUint16 {
+					runLength++
+					continue
+				}
+				if runLength > 0 {
+					binary.Write(buf, byteOrder, layerRun{runLength, runTile})
+				}
+				runTile, runLength = tile, 1
+			}
+		}
+		binary.Write(buf, byteOrder, layerRun{runLength, runTile})
+	}
+
+	var runDarkness float32
+	runLength := uint16(0)
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			darkness := t.darkness[x][y]
+			if runLength > 0 && darkness == runDarkness && runLength < math.MaxUint16 {
+				runLength++
+				continue
+			}
+			if runLength > 0 {
+				binary.Write(buf, byteOrder, runLength)
+				binary.Write(buf, byteOrder, runDarkness)
+			}
+			runDarkness, runLength = darkness, 1
+		}
+	}
+	binary.Write(buf, byteOrder, runLength)
+	binary.Write(buf, byteOrder, runDarkness)
+
+	var runLiquid Liquid
+	runLength = 0
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			liquid := t.liquids[x][y]
+			if runLength > 0 && liquid == runLiquid && runLength < math.MaxUint16 {
+				runLength++
+				continue
+			}
+			if runLength > 0 {
+				binary.Write(buf, byteOrder, runLength)
+				binary.Write(buf, byteOrder, runLiquid)
+			}
+			runLiquid, runLength = liquid, 1
+		}
+	}
+	binary.Write(buf, byteOrder, runLength)
+	binary.Write(buf, byteOrder, runLiquid)
+}
+
+//  --------------------------------------------------
+//  Decoding
+//  --------------------------------------------------
+
+// decode reads a world file. Chunks of version 2 files
+// are loaded on demand from the given region directory.
+func (tree *WorldTree) decode(r io.Reader, regionDir string, progress func(percent float32)) error {
+	br := bufio.NewReader(r)
+
+	var header WorldHeader
+	if err := binary.Read(br, byteOrder, &header); err != nil {
+		return fmt.Errorf("reading world header: %v", err)
+	}
+	if header.Magic != WorldFileMagic {
+		return errors.New("not a binary world file")
+	}
+	if header.Version < 1 || header.Version > WorldFormatVersion {
+		return fmt.Errorf("unsupported world format version %d", header.Version)
+	}
+	if header.ChunkSize != ChunkSize {
+		return fmt.Errorf("unsupported chunk size %d", header.ChunkSize)
+	}
+	if err := SetWorldSize(int(header.Width), int(header.Height)); err != nil {
+		return err
+	}
+
+	Seed = header.Seed
+	tree.saved = worldEntities{}
+	if header.Version < 2 {
+		regionDir = ""
+	}
+	tree.regions.set(regionDir, header.Version)
+
+	chunksX, chunksY := chunkCount()
+	loaded := 0
+	progress(0)
+
+	for {
+		var tag [4]byte
+		if _, err := io.ReadFull(br, tag[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("reading section tag: %v", err)
+		}
+
+		var length uint32
+		if err := binary.Read(br, byteOrder, &length); err != nil {
+			return fmt.Errorf("reading %s section length: %v", tag[:], err)
+		}
+
+		// Copied rather than read into a buffer of that length,
+		// so a bad length can't allocate more than the file holds
+		var section bytes.Buffer
+		if _, err := io.CopyN(&section, br, int64(length)); err != nil {
+			return fmt.Errorf("reading %s section: %v", tag[:], err)
+		}
+		payload := section.Bytes()
+
+		switch tag {
+		case sectionHeightMap:
+			if err := decodeHeightMap(payload); err != nil {
+				return err
+			}
+		case sectionBiomes:
+			if err := decodeBiomes(payload); err != nil {
+				return fmt.Errorf("biomes section: %v", err)
+			}
+		case sectionChunk:
+			t, err := decodeChunk(payload, header.Version)
+			if err != nil {
+				return err
+			}
+			tree.pasteChunk(t)
+			loaded++
+			progress(float32(loaded) * 100 / float32(chunksX*chunksY))
+		default:
+			if err := tree.saved.decodeSection(tag, payload, header.Version); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func decodeHeightMap(payload []byte) error {
+	if len(payload) != WorldWidth*4 {
+		return fmt.Errorf("heightmap section has %d bytes, expected %d", len(payload), WorldWidth*4)
+	}
+	for x := 0; x < WorldWidth; x++ {
+		height := int(int32(byteOrder.Uint32(payload[x*4:])))
+		if err := checkHeight(x, height); err != nil {
+			return err
+		}
+		HeightMap[x] = height
+	}
+	return nil
+}
+
+// checkHeight returns an error if a column of the heightmap is outside the world
+func checkHeight(x, height int) error {
+	if height < 0 || height >= WorldHeight {
+		return fmt.Errorf("heightmap column %d has height %d, outside of 0 to %d", x, height, WorldHeight-1)
+	}
+	return nil
+}
+
+func encodeBiomes(buf *bytes.Buffer, biomeMap []uint8) {
+	w := fieldWriter{buf}
+	w.write(uint8(len(Biomes)))
+	for _, biome := range Biomes {
+		w.writeString(biome.Name)
+	}
+	w.write(biomeMap)
+}
+
+// decodeBiomes reads the biome map, matching biomes by name
+// so the list can change between versions. Biomes that no
+// longer exist become plains.
+func decodeBiomes(payload []byte) error {
+	r := fieldReader{r: bytes.NewReader(payload)}
+
+	var count uint8
+	r.read(&count)
+	indices := make([]uint8, count)
+	for i := range indices {
+		if index := getBiomeIndex(r.readString()); index >= 0 {
+			indices[i] = uint8(index)
+		}
+	}
+
+	saved := make([]uint8, WorldWidth)
+	r.read(saved)
+	if r.err != nil {
+		return r.err
+	}
+
+	for x, biome := range saved {
+		if int(biome) >= len(indices) {
+			return fmt.Errorf("unknown biome %d", biome)
+		}
+		BiomeMap[x] = indices[biome]
+	}
+	return nil
+}
+
+// decodeChunk reads a chunk payload written in the given format version.
+// Only reads the world size and block types, so it is safe to run off
+// the game thread.
+func decodeChunk(payload []byte, version uint16) (*chunkTiles, error) {
+	r := bytes.NewReader(payload)
+
+	var cx, cy uint16
+	binary.Read(r, byteOrder, &cx)
+	if err := binary.Read(r, byteOrder, &cy); err != nil {
+		return nil, fmt.Errorf("reading chunk position: %v", err)
+	}
+	t := &chunkTiles{pos: ChunkPos{int(cx), int(cy)}}
+	if chunksX, chunksY := chunkCount(); t.pos.X >= chunksX || t.pos.Y >= chunksY {
+		return nil, fmt.Errorf("chunk %d,%d is outside the world", cx, cy)
+	}
+
+	x0, y0, x1, y1 := chunkBounds(t.pos.X, t.pos.Y)
+	h := y1 - y0
+	tiles := (x1 - x0) * h
+
+	for layer := 0; layer < NumLayers; layer++ {
+		for filled := 0; filled < tiles; {
+			run, err := readLayerRun(r, version)
+			if err != nil {
+				return nil, fmt.Errorf("chunk %d,%d layer %d: %v", cx, cy, layer, err)
+			}
+			if run.Length == 0 || filled+int(run.Length) > tiles {
+				return nil, fmt.Errorf("chunk %d,%d layer %d: bad run length %d", cx, cy, layer, run.Length)
+			}
+			tile := run.Tile
+			if GetBlockByID(tile.Block) == nil {
+				return nil, fmt.Errorf("chunk %d,%d layer %d: unknown block ID %d", cx, cy, layer, tile.Block)
+			}
+			if tile.Orient >= NumOrientations {
+				return nil, fmt.Errorf("chunk %d,%d layer %d: unknown orientation %d", cx, cy, layer, tile.Orient)
+			}
+
+			for i := filled; i < filled+int(run.Length); i++ {
+				t.tiles[layer][i/h][i%h] = tile
+			}
+			filled += int(run.Length)
+		}
+	}
+
+	for filled := 0; filled < tiles; {
+		var run struct {
+			Length   uint16
+			Darkness float32
+		}
+		if err := binary.Read(r, byteOrder, &run); err != nil {
+			return nil, fmt.Errorf("chunk %d,%d darkness: %v", cx, cy, err)
+		}
+		if run.Length == 0 || filled+int(run.Length) > tiles {
+			return nil, fmt.Errorf("chunk %d,%d darkness: bad run length %d", cx, cy, run.Length)
+		}
+		for i := filled; i < filled+int(run.Length); i++ {
+			t.darkness[i/h][i%h] = run.Darkness
+		}
+		filled += int(run.Length)
+	}
+
+	// Chunks from before version 3 end here, with no liquids
+	if r.Len() == 0 {
+		return t, nil
+	}
+
+	for filled := 0; filled < tiles; {
+		var run struct {
+			Length uint16
+			Liquid Liquid
+		}
+		if err := binary.Read(r, byteOrder, &run); err != nil {
+			return nil, fmt.Errorf("chunk %d,%d liquids: %v", cx, cy, err)
+		}
+		if run.Length == 0 || filled+int(run.Length) > tiles {
+			return nil, fmt.Errorf("chunk %d,%d liquids: bad run length %d", cx, cy, run.Length)
+		}
+		if int(run.Liquid.Type) >= len(LiquidTypes) || run.Liquid.Level > MaxLiquidLevel {
+			return nil, fmt.Errorf("chunk %d,%d liquids: bad liquid %d level %d", cx, cy, run.Liquid.Type, run.Liquid.Level)
+		}
+		for i := filled; i < filled+int(run.Length); i++ {
+			t.liquids[i/h][i%h] = run.Liquid
+		}
+		filled += int(run.Length)
+	}
+
+	return t, nil
+}
+
+// pasteChunk puts decoded tiles into the tree
+func (tree *WorldTree) pasteChunk(t *chunkTiles) {
+	x0, y0, x1, y1 := chunkBounds(t.pos.X, t.pos.Y)
+
+	for layer := 0; layer < NumLayers; layer++ {
+		for x := x0; x < x1; x++ {
+			for y := y0; y < y1; y++ {
+				if tile := t.tiles[layer][x-x0][y-y0]; tile.Block != BlockEmpty {
+					tree.SetLayerBlock(layer, x, y, tile)
+				}
+			}
+		}
+	}
+
+	for x := x0; x < x1; x++ {
+		for y := y0; y < y1; y++ {
+			tree.SetDarkness(x, y, t.darkness[x-x0][y-y0])
+
+			// Set directly, waking the neighbours would load their chunks
+			if liquid := t.liquids[x-x0][y-y0]; liquid.Level > 0 {
+				tree.editNode(x, y).liquid = liquid
+				tree.chunkAt(x, y).liquidActive = true
+			}
+		}
+	}
+}
+
+// layerRun is a run of the same block in a chunk layer
+type layerRun struct {
+	Length uint16
+	Tile   Tile
+}
+
+// readLayerRun reads a layer run written in the given format version.
+// Runs from before version 4 have no metadata.
+func readLayerRun(r io.Reader, version uint16) (layerRun, error) {
+	var run layerRun
+	if version >= 4 {
+		err := binary.Read(r, byteOrder, &run)
+		return run, err
+	}
+
+	var old struct {
+		Length uint16
+		Block  BlockID
+		Orient Orientation
+	}
+	err := binary.Read(r, byteOrder, &old)
+	run.Length = old.Length
+	run.Tile = Tile{Block: old.Block, Orient: old.Orient}
+	return run, err
+}
+
+// fieldWriter writes fixed-size values and strings to a section payload
+type fieldWriter struct {
+	buf *bytes.Buffer
+}
+
+func (w fieldWriter) write(values ...interface{}) {
+	for _, v := range values {
+		binary.Write(w.buf, byteOrder, v)
+	}
+}
+
+func (w fieldWriter) writeString(s string) {
+	binary.Write(w.buf, byteOrder, uint16(len(s)))
+	w.buf.WriteString(s)
+}
+
+// fieldReader reads what fieldWriter writes, keeping the first error
+type fieldReader struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (r *fieldReader) read(values ...interface{}) {
+	for _, v := range values {
+		if r.err != nil {
+			return
+		}
+		r.err = binary.Read(r.r, byteOrder, v)
+	}
+}
+
+func (r *fieldReader) readString() string {
+	var length uint16
+	r.read(&length)
+	if r.err != nil {
+		return ""
+	}
+	b := make([]byte, length)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		r.err = err
+		return ""
+	}
+	return string(b)
+}
+
+//  --------------------------------------------------
+//  Helpers
+//  --------------------------------------------------
+
+func chunkCount() (int, int) {
+	return (WorldWidth + ChunkSize - 1) / ChunkSize, (WorldHeight + ChunkSize - 1) / ChunkSize
+}
+
+func chunkBounds(cx, cy int) (x0, y0, x1, y1 int) {
+	x0, y0 = cx*ChunkSize, cy*ChunkSize
+	x1, y1 = x0+ChunkSize, y0+ChunkSize
+	if x1 > WorldWidth {
+		x1 = WorldWidth
+	}
+	if y1 > WorldHeight {
+		y1 = WorldHeight
+	}
+	return
+}
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestWorld loads the blocks and makes WorldMap an empty world
+// of the smallest size, with a heightmap and biomes set
+func newTestWorld(t *testing.T) *WorldTree {
+	t.Helper()
+	if err := loadBlocks(); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetWorldSize(MinWorldWidth, MinWorldHeight); err != nil {
+		t.Fatal(err)
+	}
+	Seed = 1234
+	for x := 0; x < WorldWidth; x++ {
+		HeightMap[x] = 200 + x%50
+		BiomeMap[x] = uint8(x % len(Biomes))
+	}
+	WorldMap = NewWorldTree()
+	return &WorldMap
+}
+
+// worldFile builds a world file out of a header and sections
+func worldFile(header WorldHeader, sections ...interface{}) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, byteOrder, &header)
+	for i := 0; i+1 < len(sections); i += 2 {
+		writeSection(&buf, sections[i].([4]byte), sections[i+1].([]byte))
+	}
+	return buf.Bytes()
+}
+
+// heightMapPayload is the HGHT section of the current heightmap
+func heightMapPayload() []byte {
+	var buf bytes.Buffer
+	for x := 0; x < WorldWidth; x++ {
+		binary.Write(&buf, byteOrder, int32(HeightMap[x]))
+	}
+	return buf.Bytes()
+}
+
+func noProgress(percent float32) {}
+
+func TestWorldFileRoundTrip(t *testing.T) {
+	tree := newTestWorld(t)
+	dirt, stone := GetIDFromName("dirt"), GetIDFromName("stone")
+
+	// The last chunks of the smallest world are cut short
+	tiles := []struct {
+		layer, x, y int
+		tile        Tile
+	}{
+		{LayerWorld, 0, 0, Tile{Block: dirt, Orient: OrientNN}},
+		{LayerWorld, 63, 63, Tile{Block: stone, Orient: OrientLT, Meta: 7}},
+		{LayerBack, 64, 10, Tile{Block: dirt, Orient: OrientAA}},
+		{LayerWorld, WorldWidth - 1, WorldHeight - 1, Tile{Block: stone, Orient: OrientRB, Meta: 255}},
+	}
+	for _, tt := range tiles {
+		tree.SetLayerBlock(tt.layer, tt.x, tt.y, tt.tile)
+	}
+	tree.SetDarkness(5, 5, 0.25)
+	tree.editNode(6, 6).liquid = Liquid{Type: LiquidWater, Level: MaxLiquidLevel}
+	tree.editNode(WorldWidth-1, 0).liquid = Liquid{Type: LiquidLava, Level: 3}
+
+	path := filepath.Join(t.TempDir(), "world.hln")
+	if err := tree.WriteToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	heights := append([]int(nil), HeightMap...)
+	biomes := append([]uint8(nil), BiomeMap...)
+
+	Seed = 0
+	if err := SetWorldSize(DefaultWorldSize.Width, DefaultWorldSize.Height); err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewWorldTree()
+	if err := loaded.LoadFromFile(path, noProgress); err != nil {
+		t.Fatal(err)
+	}
+
+	if WorldWidth != MinWorldWidth || WorldHeight != MinWorldHeight {
+		t.Errorf("loaded a %dx%d world, want %dx%d", WorldWidth, WorldHeight, MinWorldWidth, MinWorldHeight)
+	}
+	if Seed != 1234 {
+		t.Errorf("loaded seed %d, want 1234", Seed)
+	}
+	for x := 0; x < WorldWidth; x++ {
+		if HeightMap[x] != heights[x] || BiomeMap[x] != biomes[x] {
+			t.Fatalf("column %d has height %d and biome %d, want %d and %d", x, HeightMap[x], BiomeMap[x], heights[x], biomes[x])
+		}
+	}
+	for _, tt := range tiles {
+		if got := loaded.GetLayerTile(tt.layer, tt.x, tt.y); got != tt.tile {
+			t.Errorf("layer %d at %d,%d holds %+v, want %+v", tt.layer, tt.x, tt.y, got, tt.tile)
+		}
+	}
+	if got := loaded.GetLayerTile(LayerWorld, 1, 0); got != (Tile{}) {
+		t.Errorf("sky at 1,0 loaded as %+v", got)
+	}
+	if got := loaded.GetDarkness(5, 5); got != 0.25 {
+		t.Errorf("darkness at 5,5 is %v, want 0.25", got)
+	}
+	if got := loaded.GetLiquid(6, 6); got != (Liquid{Type: LiquidWater, Level: MaxLiquidLevel}) {
+		t.Errorf("liquid at 6,6 is %+v, want full water", got)
+	}
+	if got := loaded.GetLiquid(WorldWidth-1, 0); got != (Liquid{Type: LiquidLava, Level: 3}) {
+		t.Errorf("liquid at the last column is %+v, want lava level 3", got)
+	}
+	if loaded.corrupt != nil {
+		t.Errorf("loaded world is marked as damaged: %v", loaded.corrupt)
+	}
+}
+
+func TestWorldFileCorrupt(t *testing.T) {
+	newTestWorld(t)
+	heights := heightMapPayload()
+
+	badHeight := append([]byte(nil), heights...)
+	byteOrder.PutUint32(badHeight[8:], uint32(WorldHeight))
+	negativeHeight := append([]byte(nil), heights...)
+	byteOrder.PutUint32(negativeHeight[8:], 0xFFFFFFFF)
+
+	var biomes bytes.Buffer
+	encodeBiomes(&biomes, make([]uint8, WorldWidth))
+	badBiome := append([]byte(nil), biomes.Bytes()...)
+	badBiome[len(badBiome)-1] = uint8(len(Biomes))
+
+	header := newWorldHeader()
+	badMagic, oldVersion, newVersion, badChunkSize, badSize := header, header, header, header, header
+	badMagic.Magic = [4]byte{'H', 'L', 'N', 'X'}
+	oldVersion.Version = 0
+	newVersion.Version = WorldFormatVersion + 1
+	badChunkSize.ChunkSize = ChunkSize / 2
+	badSize.Width = MaxWorldWidth + 1
+
+	valid := worldFile(header, sectionHeightMap, heights, sectionBiomes, biomes.Bytes())
+
+	// A section claiming 4 GiB in a file that holds a few bytes
+	huge := worldFile(header)
+	huge = append(huge, sectionHeightMap[:]...)
+	huge = append(huge, 0xFF, 0xFF, 0xFF, 0xFF, 1, 2, 3)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"truncated header", valid[:10]},
+		{"bad magic", worldFile(badMagic)},
+		{"version 0", worldFile(oldVersion)},
+		{"future version", worldFile(newVersion)},
+		{"bad chunk size", worldFile(badChunkSize)},
+		{"bad world size", worldFile(badSize)},
+		{"truncated section tag", valid[:binary.Size(header)+2]},
+		{"truncated section length", valid[:binary.Size(header)+6]},
+		{"truncated section", valid[:len(valid)-1]},
+		{"huge section", huge},
+		{"short heightmap", worldFile(header, sectionHeightMap, heights[4:])},
+		{"height above the world", worldFile(header, sectionHeightMap, badHeight)},
+		{"negative height", worldFile(header, sectionHeightMap, negativeHeight)},
+		{"unknown biome", worldFile(header, sectionBiomes, badBiome)},
+		{"truncated biomes", worldFile(header, sectionBiomes, biomes.Bytes()[:10])},
+	}
+	for _, tt := range tests {
+		tree := NewWorldTree()
+		if err := tree.decode(bytes.NewReader(tt.data), "", noProgress); err == nil {
+			t.Errorf("%s: decoded without an error", tt.name)
+		}
+	}
+
+	tree := NewWorldTree()
+	if err := tree.decode(bytes.NewReader(valid), "", noProgress); err != nil {
+		t.Errorf("valid file: %v", err)
+	}
+
+	// Sections a version doesn't know are skipped
+	unknown := worldFile(header, [4]byte{'N', 'E', 'W', 'S'}, []byte{1, 2, 3}, sectionHeightMap, heights)
+	if err := tree.decode(bytes.NewReader(unknown), "", noProgress); err != nil {
+		t.Errorf("file with an unknown section: %v", err)
+	}
+}
+
+// testChunk returns chunk 1,0 with a bit of everything in it
+func testChunk() *chunkTiles {
+	t := &chunkTiles{pos: ChunkPos{1, 0}}
+	dirt, stone := GetIDFromName("dirt"), GetIDFromName("stone")
+	for x := 0; x < ChunkSize; x++ {
+		for y := 0; y < ChunkSize/2; y++ {
+			t.tiles[LayerWorld][x][y] = Tile{Block: dirt, Orient: OrientNN}
+			t.tiles[LayerBack][x][y] = Tile{Block: stone, Orient: Orientation((x + y) % int(NumOrientations))}
+			t.darkness[x][y] = float32(y) / ChunkSize
+		}
+	}
+	t.tiles[LayerWorld][3][40] = Tile{Block: stone, Orient: OrientAT, Meta: 9}
+	t.liquids[5][50] = Liquid{Type: LiquidWater, Level: 4}
+	t.liquids[5][51] = Liquid{Type: LiquidLava, Level: MaxLiquidLevel}
+	return t
+}
+
+// encodeOldChunk writes a chunk the way versions before 4 did, with
+// no metadata in the layer runs and, before version 3, no liquids
+func encodeOldChunk(t *chunkTiles, version uint16) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, byteOrder, uint16(t.pos.X))
+	binary.Write(&buf, byteOrder, uint16(t.pos.Y))
+	for layer := 0; layer < NumLayers; layer++ {
+		for x := 0; x < ChunkSize; x++ {
+			for y := 0; y < ChunkSize; y++ {
+				tile := t.tiles[layer][x][y]
+				binary.Write(&buf, byteOrder, uint16(1))
+				binary.Write(&buf, byteOrder, tile.Block)
+				binary.Write(&buf, byteOrder, tile.Orient)
+			}
+		}
+	}
+	for x := 0; x < ChunkSize; x++ {
+		for y := 0; y < ChunkSize; y++ {
+			binary.Write(&buf, byteOrder, uint16(1))
+			binary.Write(&buf, byteOrder, t.darkness[x][y])
+		}
+	}
+	if version >= 3 {
+		for x := 0; x < ChunkSize; x++ {
+			for y := 0; y < ChunkSize; y++ {
+				binary.Write(&buf, byteOrder, uint16(1))
+				binary.Write(&buf, byteOrder, t.liquids[x][y])
+			}
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestChunkRoundTrip(t *testing.T) {
+	newTestWorld(t)
+	want := testChunk()
+
+	var buf bytes.Buffer
+	encodeChunk(&buf, want)
+	got, err := decodeChunk(buf.Bytes(), WorldFormatVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != *want {
+		t.Errorf("decoded chunk differs from the one encoded")
+	}
+
+	// The last chunk of the world is cut short on both sides
+	chunksX, chunksY := chunkCount()
+	edge := &chunkTiles{pos: ChunkPos{chunksX - 1, chunksY - 1}}
+	x0, y0, x1, y1 := chunkBounds(edge.pos.X, edge.pos.Y)
+	edge.tiles[LayerWorld][x1-x0-1][y1-y0-1] = Tile{Block: GetIDFromName("stone")}
+	buf.Reset()
+	encodeChunk(&buf, edge)
+	if got, err = decodeChunk(buf.Bytes(), WorldFormatVersion); err != nil {
+		t.Fatal(err)
+	}
+	if *got != *edge {
+		t.Errorf("decoded edge chunk differs from the one encoded")
+	}
+}
+
+func TestChunkOldVersions(t *testing.T) {
+	newTestWorld(t)
+	want := testChunk()
+
+	// Metadata is new in version 4
+	want.tiles[LayerWorld][3][40].Meta = 0
+	for _, version := range []uint16{1, 2, 3} {
+		got, err := decodeChunk(encodeOldChunk(want, version), version)
+		if err != nil {
+			t.Fatalf("version %d: %v", version, err)
+		}
+		expect := *want
+		if version < 3 {
+			expect.liquids = [ChunkSize][ChunkSize]Liquid{}
+		}
+		if *got != expect {
+			t.Errorf("version %d: decoded chunk differs from the one encoded", version)
+		}
+	}
+}
+
+func TestChunkCorrupt(t *testing.T) {
+	newTestWorld(t)
+
+	var buf bytes.Buffer
+	encodeChunk(&buf, testChunk())
+	valid := buf.Bytes()
+
+	// Every cut of a chunk fails, except right before the liquids,
+	// which is how chunks from before version 3 end
+	liquidsStart := len(valid) - 4*binary.Size(struct {
+		Length uint16
+		Liquid Liquid
+	}{})
+	for n := 0; n < len(valid); n++ {
+		if n == liquidsStart {
+			continue
+		}
+		if _, err := decodeChunk(valid[:n], WorldFormatVersion); err == nil {
+			t.Errorf("decoded the first %d of %d bytes without an error", n, len(valid))
+		}
+	}
+
+	// A single run covers the whole first layer of an empty chunk
+	empty := &chunkTiles{pos: ChunkPos{1, 0}}
+	buf.Reset()
+	encodeChunk(&buf, empty)
+	run := 4
+
+	change := func(f func(b []byte)) []byte {
+		b := append([]byte(nil), buf.Bytes()...)
+		f(b)
+		return b
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"outside the world", change(func(b []byte) { byteOrder.PutUint16(b, 0xFFFF) })},
+		{"empty run", change(func(b []byte) { byteOrder.PutUint16(b[run:], 0) })},
+		{"long run", change(func(b []byte) { byteOrder.PutUint16(b[run:], ChunkSize*ChunkSize+1) })},
+		{"unknown block", change(func(b []byte) { byteOrder.PutUint16(b[run+2:], 0xFFFF) })},
+		{"unknown orientation", change(func(b []byte) { b[run+4] = uint8(NumOrientations) })},
+		{"unknown liquid", change(func(b []byte) { b[len(b)-2] = uint8(len(LiquidTypes)) })},
+		{"liquid too deep", change(func(b []byte) { b[len(b)-1] = MaxLiquidLevel + 1 })},
+	}
+	for _, tt := range tests {
+		if _, err := decodeChunk(tt.data, WorldFormatVersion); err == nil {
+			t.Errorf("%s: decoded without an error", tt.name)
+		}
+	}
+}
+
+// legacyWorld writes a legacy save whose columns are dirt up
+// to height 100, with darkness 0.5, to a pipe
+func legacyWorld(columns int) io.Reader {
+	dirt := GetIDFromName("dirt")
+	ground := fmt.Sprintf("%03d%02d%03d%02d00000000000.5\n", dirt, OrientNN, dirt, OrientAA)
+	sky := "000000000000000000000\n"
+
+	r, w := io.Pipe()
+	go func() {
+		bw := bufio.NewWriter(w)
+		for x := 0; x < LegacyWorldWidth; x++ {
+			bw.WriteString("100\n")
+		}
+		for x := 0; x < columns; x++ {
+			for y := 0; y < LegacyWorldHeight; y++ {
+				if y < 100 {
+					bw.WriteString(ground)
+				} else {
+					bw.WriteString(sky)
+				}
+			}
+		}
+		bw.Flush()
+		w.Close()
+	}()
+	return r
+}
+
+func TestLoadLegacy(t *testing.T) {
+	newTestWorld(t)
+	tree := NewWorldTree()
+	if err := tree.loadLegacy(legacyWorld(LegacyWorldWidth), noProgress); err != nil {
+		t.Fatal(err)
+	}
+
+	if WorldWidth != LegacyWorldWidth || WorldHeight != LegacyWorldHeight {
+		t.Errorf("loaded a %dx%d world, want %dx%d", WorldWidth, WorldHeight, LegacyWorldWidth, LegacyWorldHeight)
+	}
+	dirt := GetIDFromName("dirt")
+	for _, x := range []int{0, 1500, LegacyWorldWidth - 1} {
+		if HeightMap[x] != 100 {
+			t.Errorf("column %d has height %d, want 100", x, HeightMap[x])
+		}
+		if got := tree.GetLayerTile(LayerWorld, x, 99); got != (Tile{Block: dirt, Orient: OrientNN}) {
+			t.Errorf("world block at %d,99 is %+v, want dirt", x, got)
+		}
+		if got := tree.GetLayerTile(LayerBack, x, 0); got != (Tile{Block: dirt, Orient: OrientAA}) {
+			t.Errorf("back block at %d,0 is %+v, want dirt", x, got)
+		}
+		if got := tree.GetLayerTile(LayerWorld, x, 100); got != (Tile{}) {
+			t.Errorf("world block at %d,100 is %+v, want sky", x, got)
+		}
+		if got := tree.GetDarkness(x, 50); got != 0.5 {
+			t.Errorf("darkness at %d,50 is %v, want 0.5", x, got)
+		}
+	}
+}
+
+func TestLoadLegacyCorrupt(t *testing.T) {
+	newTestWorld(t)
+
+	heights := strings.Repeat("100\n", LegacyWorldWidth)
+	tests := []struct {
+		name string
+		data io.Reader
+	}{
+		{"ended early", legacyWorld(2)},
+		{"bad height", strings.NewReader("abc\n")},
+		{"height above the world", strings.NewReader(fmt.Sprintf("%d\n", LegacyWorldHeight))},
+		{"negative height", strings.NewReader("-1\n")},
+		{"short entry", strings.NewReader(heights + "00000\n")},
+		{"unknown block", strings.NewReader(heights + "999000000000000000000\n")},
+		{"unknown orientation", strings.NewReader(heights + "000990000000000000000\n")},
+		{"bad darkness", strings.NewReader(heights + "00000000000000000000dark\n")},
+	}
+	for _, tt := range tests {
+		tree := NewWorldTree()
+		if err := tree.loadLegacy(tt.data, noProgress); err == nil {
+			t.Errorf("%s: loaded without an error", tt.name)
+		}
+	}
+}
@@ -1,165 +1,125 @@
 package main
 
 import (
+	"encoding/binary"
 	"fmt"
+	"hash/fnv"
 	"math/rand"
-	"rapidengine/child"
 	"rapidengine/procedural"
 	"time"
 )
 
-func initializeWorldTree() {
-	loadBlocks()
-	WorldMap = NewWorldTree()
-}
-
 var AverageWorldHeight = float32(0.5)
 
-func generateWorldTree() {
-
-	Engine.Logger.Info("Loading blocks...")
-	ProgressText.Text = "Loading blocks..."
-	ProgressBar.SetPercentage(10)
-	updateLoadingScreen()
-
-	// Randomize seed
-	randomizeSeed()
-
-	Engine.Logger.Info("Placing dirt...")
-	ProgressText.Text = "Placing dirt..."
-	ProgressBar.SetPercentage(20)
-	updateLoadingScreen()
-
-	// Generate heightmap and place grass
-	generateHeightMap()
-
-	// Fill everything underneath grass with dirt
-	generateDirt()
-
-	Engine.Logger.Info("Placing stone...")
-	ProgressText.Text = "Placing stone..."
-	ProgressBar.SetPercentage(30)
-	updateLoadingScreen()
-
-	// Generate stone based on height
-	generateStone()
-
-	// Clean up stone above ground
-	cleanStone()
-
-	Engine.Logger.Info("Generating caves...")
-	ProgressText.Text = "Generating caves..."
-	ProgressBar.SetPercentage(40)
-	updateLoadingScreen()
-
-	// Generate caves
-	generateCaves()
-
-	// Clean back dirt
-	cleanBackDirt()
-
-	Engine.Logger.Info("Growing grass...")
-	ProgressText.Text = "Growing grass..."
-	ProgressBar.SetPercentage(50)
-	updateLoadingScreen()
-
-	// Put grass and some top grass on dirt with air above it
-	growGrass()
-
-	Engine.Logger.Info("Generating nature...")
-	ProgressText.Text = "Generating nature..."
-	ProgressBar.SetPercentage(60)
-	updateLoadingScreen()
-
-	// Create clouds
-	generateClouds()
-
-	// Place flowers and pebbles above grass
-	generateNature()
-
-	Engine.Logger.Info("Generating structures...")
-	ProgressText.Text = "Generating structures..."
-	ProgressBar.SetPercentage(70)
-	updateLoadingScreen()
-
-	// Generate structure
-	generateStructures()
-	generateAllDungeons()
-
-	Engine.Logger.Info("Orienting blocks...")
-	ProgressText.Text = "Orienting blocks..."
-	ProgressBar.SetPercentage(80)
-	updateLoadingScreen()
-
-	// Fix the orientation of blocks in the world
-	orientBlocks("dirt", true)
-	orientBlocks("grass", true)
-	orientBlocks("stone", true)
-	orientBlocks("leaves", true)
-
-	// Fix backdirt
-	createAllExtraBackdirt()
-	orientBlocks("backdirt", true)
-
-	Engine.Logger.Info("Creating light...")
-	ProgressText.Text = "Creating light..."
-	ProgressBar.SetPercentage(90)
-	updateLoadingScreen()
-
-	// Light up all blocks
-	CreateLighting(WorldWidth/2, HeightMap[WorldWidth/2]+5, 0.9)
-
-	// Save world to image
-	WorldMap.writeToImage()
-
-	ProgressBar.SetPercentage(100)
-	updateLoadingScreen()
-
-	// Set player starting position
-	Player1.PlayerChild.SetPosition(float32(WorldWidth*BlockSize/2), float32((HeightMap[WorldWidth/2]+50)*BlockSize))
-	Player1.CenterX = Player1.PlayerChild.X + (Player1.PlayerChild.ScaleX / 2) - (Player1.Hitbox1.DAABB.Width / 2)
-	Player1.CenterY = Player1.PlayerChild.Y + (Player1.PlayerChild.ScaleY / 2) - (Player1.Hitbox1.LAABB.Height / 2)
-
-	Engine.SceneControl.SetCurrentScene(WorldScene)
-	HotbarScene.Activate()
-}
-
-func generateTestWorldTree() {
-	randomizeSeed()
-
-	for x := 1300; x < 1600; x++ {
-		HeightMap[x] = 500
-		createWorldBlock(x, 500, "stone")
+// generateWorld runs every pass of WorldGenPasses into WorldMap. The same
+// seed and size always generate the same world. It never touches the
+// engine, so hellion-gen runs it too (see hellion_gen.go).
+func generateWorld(seed int64, size WorldSize, progress func(text string, percent float32)) error {
+	if err := SetWorldSize(size.Width, size.Height); err != nil {
+		return err
 	}
+	Seed = seed
+	logInfo(fmt.Sprintf("Generating %s world with seed %d", size, Seed))
 
-	for x := 1300; x < 1600; x++ {
-		orientSingleBlock("stone", false, x, 500)
+	ctx := &GenContext{
+		Seed:   seed,
+		Width:  size.Width,
+		Height: size.Height,
 	}
+	WorldGenPasses.Run(ctx, &WorldMap, func(text string, percent float32) {
+		logInfo(text)
+		progress(text, percent)
+	})
+	return nil
+}
 
-	// Save world to image
-	WorldMap.writeToImage()
+// WorldGenPasses are the passes generateWorld runs, in order (see genpass.go)
+var WorldGenPasses = NewGenPipeline(
+	// Pick the biome of every column (see biome.go)
+	NewGenPass("biomes", 0.2, func(ctx *GenContext, world *WorldTree, rng *rand.Rand) {
+		generateBiomeMap(rng)
+	}),
+
+	NewGenPass("heightmap", 1, func(ctx *GenContext, world *WorldTree, rng *rand.Rand) {
+		// Generate heightmap and place the ground
+		generateHeightMap(world, rng)
+
+		// Fill everything underneath the ground
+		generateDirt(world)
+	}),
+
+	NewGenPass("stone", 1, func(ctx *GenContext, world *WorldTree, rng *rand.Rand) {
+		// Generate stone based on height
+		generateStone(world, rng)
+
+		// Clean up stone above ground
+		cleanStone(world)
+	}),
+
+	NewGenPass("caves", 2, func(ctx *GenContext, world *WorldTree, rng *rand.Rand) {
+		generateCaves(world, rng)
+
+		// Clean back dirt
+		cleanBackDirt()
+	}),
+
+	// Place ore veins in the stone left after caves (see ores.go)
+	NewGenPass("ores", 0.5, func(ctx *GenContext, world *WorldTree, rng *rand.Rand) {
+		generateOres(world, rng)
+	}),
+
+	// Dig lakes into the ground and fill dips in caves with water and lava (see liquids.go)
+	NewGenPass("lakes", 0.5, func(ctx *GenContext, world *WorldTree, rng *rand.Rand) {
+		generateLakes(world, rng)
+		generatePools(world, rng)
+	}),
+
+	// Put the surface blocks of each biome on ground with air above it
+	NewGenPass("grass", 0.5, func(ctx *GenContext, world *WorldTree, rng *rand.Rand) {
+		growGrass(world)
+	}),
+
+	// Grow trees and place the nature blocks of each biome above the ground
+	NewGenPass("nature", 0.5, func(ctx *GenContext, world *WorldTree, rng *rand.Rand) {
+		generateNature(world, rng)
+	}),
+
+	NewGenPass("structures", 0.5, func(ctx *GenContext, world *WorldTree, rng *rand.Rand) {
+		generateStructures(world, rng)
+	}),
+
+	NewGenPass("dungeons", 0.5, func(ctx *GenContext, world *WorldTree, rng *rand.Rand) {
+		generateAllDungeons(world, rng)
+	}),
 
-	CreateLighting(1500, 500, 0.9)
+	// Fix the orientation of blocks in the world
+	NewGenPass("orientation", 5, func(ctx *GenContext, world *WorldTree, rng *rand.Rand) {
+		world.orientWorldBlocks()
 
-	Player1.PlayerChild.SetPosition(float32(BlockSize)*1500, float32(BlockSize)*600)
-	Player1.CenterX = Player1.PlayerChild.X + (Player1.PlayerChild.ScaleX / 2) - (Player1.Hitbox1.DAABB.Width / 2)
-	Player1.CenterY = Player1.PlayerChild.Y + (Player1.PlayerChild.ScaleY / 2) - (Player1.Hitbox1.LAABB.Height / 2)
+		// Fix backdirt
+		world.createAllExtraBackdirt()
+		world.orientBlocks("backdirt", true)
+	}),
 
-	Engine.SceneControl.SetCurrentScene(WorldScene)
-	HotbarScene.Activate()
-}
+	// Light up all blocks
+	NewGenPass("lighting", 2, func(ctx *GenContext, world *WorldTree, rng *rand.Rand) {
+		world.CreateLighting(WorldWidth/2, HeightMap[WorldWidth/2]+5, 0.9)
+	}),
+)
 
 //  --------------------------------------------------
 //  World Generation Functions
 //  --------------------------------------------------
 
-func generateHeightMap() {
-	randomizeSeed()
-	gen := procedural.NewSimplexGenerator(0.001, 1, 0.5, 8, Seed)
+func generateHeightMap(world *WorldTree, rng *rand.Rand) {
+	gen := procedural.NewSimplexGenerator(0.001, 1, 0.5, 8, rng.Int63())
 
 	minHeight := float64(1)
 	maxHeight := float64(0)
 
+	groundLevel := blendBiomes(func(biome *Biome) float64 { return biome.GroundLevel })
+	flatness := blendBiomes(func(biome *Biome) float64 { return biome.Flatness })
+
 	for x := 0; x < WorldWidth; x++ {
 		ht := gen.Noise1D(float64(x))
 		if ht < minHeight {
@@ -168,30 +128,30 @@ func generateHeightMap() {
 		if ht > maxHeight {
 			maxHeight = ht
 		}
-		HeightMap[x] = GrassMinimum + int(Flatness*ht*float64(WorldHeight))
+		HeightMap[x] = int(groundLevel[x]*float64(WorldHeight)) + int(flatness[x]*ht*float64(WorldHeight))
+		if HeightMap[x] > WorldHeight-MinSkyHeight {
+			HeightMap[x] = WorldHeight - MinSkyHeight
+		}
 	}
 
 	AverageWorldHeight = float32(minHeight+maxHeight) / 2.0
 
 	for x := 0; x < WorldWidth; x++ {
-		createWorldBlock(x, HeightMap[x], "dirt")
+		world.createWorldBlock(x, HeightMap[x], getBiome(x).SubsurfaceBlock)
 	}
 }
 
-func generateDirt() {
+func generateDirt(world *WorldTree) {
 	for x := 0; x < WorldWidth; x++ {
-		for y := 0; y < WorldHeight-1; y++ {
-			createWorldBlock(x, y, "dirt")
-			if WorldMap.GetWorldBlockName(x, y+1) == "dirt" {
-				break
-			}
+		block := getBiome(x).SubsurfaceBlock
+		for y := 0; y < HeightMap[x]; y++ {
+			world.createWorldBlock(x, y, block)
 		}
 	}
 }
 
-func generateStone() {
-	randomizeSeed()
-	gen := procedural.NewSimplexGenerator(10, 1, 0.5, 5, Seed)
+func generateStone(world *WorldTree, rng *rand.Rand) {
+	gen := procedural.NewSimplexGenerator(10, 1, 0.5, 5, rng.Int63())
 
 	for x := 0; x < WorldWidth; x++ {
 		stoneFrequency := float64(StoneStartingFrequency)
@@ -199,7 +159,7 @@ func generateStone() {
 			n := gen.Noise2D(float64(x)/300, float64(y)/300)
 
 			if n < stoneFrequency {
-				createWorldBlock(x, y, "stone")
+				world.createWorldBlock(x, y, "stone")
 			}
 
 			stoneFrequency += StoneFrequencyDelta
@@ -210,16 +170,16 @@ func generateStone() {
 	}
 }
 
-func cleanStone() {
+func cleanStone(world *WorldTree) {
 	for x := 0; x < WorldWidth; x++ {
 		grassHeight := HeightMap[x]
-		if WorldMap.GetWorldBlockName(x, grassHeight) == "stone" {
+		if world.GetWorldBlockName(x, grassHeight) == "stone" {
 			for y := grassHeight + StoneTopDeviation; y < WorldHeight; y++ {
-				createWorldBlock(x, y, "sky")
+				world.createWorldBlock(x, y, "sky")
 			}
 		} else {
 			for y := grassHeight + 1; y < WorldHeight; y++ {
-				createWorldBlock(x, y, "sky")
+				world.createWorldBlock(x, y, "sky")
 			}
 		}
 	}
@@ -229,50 +189,48 @@ func cleanBackDirt() {
 
 }
 
-func growGrass() {
+func growGrass(world *WorldTree) {
 	for x := 0; x < WorldWidth; x++ {
+		biome := getBiome(x)
 		for y := 0; y < WorldHeight; y++ {
-			if WorldMap.GetWorldBlockName(x, y) == "dirt" && (WorldMap.GetWorldBlockName(x, y+1) == "sky" || WorldMap.GetBackBlockName(x, y+1) == "backdirt") {
-				createGrassBlock(x, y, "grasstop")
+			if world.GetWorldBlockName(x, y) == biome.SubsurfaceBlock && world.GetLiquid(x, y+1).Level == 0 &&
+				(world.GetWorldBlockName(x, y+1) == "sky" || world.GetBackBlockName(x, y+1) == "backdirt") {
+				if biome.SurfaceBlock != biome.SubsurfaceBlock {
+					world.createWorldBlock(x, y, biome.SurfaceBlock)
+				}
+				if biome.SurfaceOverlay != "" {
+					world.createGrassBlock(x, y, biome.SurfaceOverlay)
+				}
 			}
 		}
 	}
 }
 
-func generateClouds() {
-	for x := 0; x < WorldWidth; x++ {
-		if rand.Float32() < 0.4 {
-			CloudChild.AddCopy(
-				child.ChildCopy{
-					X:        float32(x * BlockSize),
-					Y:        float32((rand.Intn(20) + HeightMap[x] + 15) * BlockSize),
-					Material: cloudMaterial,
-					Darkness: 1,
-				},
-			)
-			x += 400 / BlockSize
-		}
-	}
-}
+func generateNature(world *WorldTree, rng *rand.Rand) {
+	lastTree, lastCanopy := -WorldWidth, 0
 
-func generateNature() {
 	for x := 1; x < WorldWidth-1; x++ {
-		if WorldMap.GetWorldBlockName(x, HeightMap[x]+1) == "sky" || WorldMap.GetWorldBlockName(x, HeightMap[x]+1) == "backdirt" {
-			natureRand := rand.Intn(16)
-			if natureRand == 15 && WorldMap.GetWorldBlockName(x-1, HeightMap[x]+2) != "treetrunk" {
-
-			} else if natureRand > 13 {
-				floraRand := rand.Intn(4) + 1
-				floraType := fmt.Sprintf("flower%d", floraRand)
-				if floraRand != 4 {
-					createNatureBlock(x, HeightMap[x]+1, floraType)
-				} else {
-					createNatureBlock(x, HeightMap[x]+1, "pebble")
+		if world.GetLiquid(x, HeightMap[x]+1).Level > 0 {
+			continue
+		}
+		if world.GetWorldBlockName(x, HeightMap[x]+1) == "sky" || world.GetWorldBlockName(x, HeightMap[x]+1) == "backdirt" {
+			biome := getBiome(x)
+
+			// Trees keep their canopies apart (see trees.go)
+			if len(biome.Trees) > 0 && rng.Float32() < biome.TreeChance {
+				species := biome.Trees[rng.Intn(len(biome.Trees))]
+				if x-lastTree > lastCanopy+species.CanopyWidth+TreeSpacing && world.canGrowTree(x, species) {
+					world.growTree(rng, x, species)
+					lastTree, lastCanopy = x, species.CanopyWidth
+
+					// Skip the right root
+					x++
+					continue
 				}
-			} else if natureRand > 9 {
-				grassRand := rand.Intn(3) + 1
-				grassType := fmt.Sprintf("topGrass%d", grassRand)
-				createNatureBlock(x, HeightMap[x]+1, grassType)
+			}
+
+			if block := biome.pickDecoration(rng); block != "" {
+				world.createNatureBlock(x, HeightMap[x]+1, block)
 			}
 		}
 	}
@@ -284,15 +242,20 @@ func generateNature() {
 //  --------------------------------------------------
 
 func isBackBlock(name string) bool {
-	for _, transparent := range TransparentBlocks {
-		if name == transparent {
-			return true
-		}
-	}
-	return false
+	return GetBlock(name).Transparent
+}
+
+// randomSeed picks a seed for when the player didn't type one
+func randomSeed() int64 {
+	return time.Now().UTC().UnixNano()
 }
 
-func randomizeSeed() {
-	Seed = time.Now().UTC().UnixNano()
-	rand.Seed(Seed)
+// passRand returns the random stream of a generation pass. Every
+// pass gets its own stream derived from the world seed, so changing
+// one pass doesn't change what the other passes generate.
+func passRand(pass string) *rand.Rand {
+	h := fnv.New64a()
+	binary.Write(h, binary.LittleEndian, Seed)
+	h.Write([]byte(pass))
+	return rand.New(rand.NewSource(int64(h.Sum64())))
 }
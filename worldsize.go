@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//  --------------------------------------------------
+//  Worldsize.go contains the world size presets.
+//
+//  The size of a world is picked when it is created
+//  and stored in its save. WorldWidth and WorldHeight
+//  hold the size of the current world, and are only
+//  changed through SetWorldSize.
+//  --------------------------------------------------
+
+// WorldSize is the width and height of a world in blocks
+type WorldSize struct {
+	Name   string
+	Width  int
+	Height int
+}
+
+// WorldSizes are the presets offered when creating a world
+var WorldSizes = []WorldSize{
+	{"small", 1500, 1200},
+	{"medium", 3000, 2000},
+	{"large", 6000, 2400},
+}
+
+// DefaultWorldSize is the size of worlds from before sizes were configurable
+var DefaultWorldSize = WorldSizes[1]
+
+// Limits on custom world sizes. Generation needs some room for
+// structures and dungeons, and chunk positions are saved as uint16.
+const MinWorldWidth = 1000
+const MinWorldHeight = 500
+const MaxWorldWidth = 16384
+const MaxWorldHeight = 8192
+
+// checkWorldSize returns an error if a world can't be this size
+func checkWorldSize(width, height int) error {
+	if width < MinWorldWidth || width > MaxWorldWidth || height < MinWorldHeight || height > MaxWorldHeight {
+		return fmt.Errorf("world size %dx%d is outside of %dx%d to %dx%d",
+			width, height, MinWorldWidth, MinWorldHeight, MaxWorldWidth, MaxWorldHeight)
+	}
+	return nil
+}
+
+// SetWorldSize changes the size of the current world
+func SetWorldSize(width, height int) error {
+	if err := checkWorldSize(width, height); err != nil {
+		return err
+	}
+
+	WorldWidth = width
+	WorldHeight = height
+	if len(HeightMap) != width {
+		HeightMap = make([]int, width)
+	}
+
+	// Worlds are all plains until the biome map is generated or loaded
+	BiomeMap = make([]uint8, width)
+	return nil
+}
+
+// ParseWorldSize reads a preset name like "small",
+// or custom dimensions like "4000x1500"
+func ParseWorldSize(s string) (WorldSize, error) {
+	for _, size := range WorldSizes {
+		if s == size.Name {
+			return size, nil
+		}
+	}
+
+	parts := strings.Split(s, "x")
+	if len(parts) == 2 {
+		width, errW := strconv.Atoi(parts[0])
+		height, errH := strconv.Atoi(parts[1])
+		if errW == nil && errH == nil {
+			return WorldSize{"custom", width, height}, nil
+		}
+	}
+	return WorldSize{}, fmt.Errorf("unknown world size %q", s)
+}
+
+func (size WorldSize) String() string {
+	return fmt.Sprintf("%s (%dx%d)", size.Name, size.Width, size.Height)
+}